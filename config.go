@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runConfig runs a config subcommand; "show" prints the effective config.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: ai-tui config show [--origin] [--config PATH] [--set path=value]")
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to config file")
+	origin := fs.Bool("origin", false, "Print the file, variable or flag that set each value")
+	var sets []string
+	fs.Func("set", "Set a config value as path=value (repeatable)", func(s string) error {
+		sets = append(sets, s)
+		return nil
+	})
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig(*configPath, sets...)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		if *origin {
+			fmt.Fprintf(w, "%s = %s\t# %s\n", s.Path, s.Value, s.Origin)
+		} else {
+			fmt.Fprintf(w, "%s = %s\n", s.Path, s.Value)
+		}
+	}
+	return w.Flush()
+}
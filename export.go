@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
+)
+
+// stringsFlag is a flag that may be given several times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// runExport writes sessions to files, one per session, updating the files
+// they were exported to before. Sessions are picked by ID, or read from the
+// database a page at a time when picked by --all, --since or --tag.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to config file")
+	var ids stringsFlag
+	fs.Var(&ids, "id", "Session ID to export (repeatable)")
+	all := fs.Bool("all", false, "Export every session, archived ones included")
+	since := fs.String("since", "", "Export sessions created since a date: YYYY, YYYY-MM, YYYY-MM-DD or 7d")
+	tag := fs.String("tag", "", "Export sessions with this tag")
+	format := fs.String("format", "md", "Export format: "+strings.Join(export.Formats, ", "))
+	out := fs.String("out", "", "Directory to write to (default: storage.notes_dir)")
+	tmpl := fs.String("template", "", "Markdown template file (default: export.template)")
+	fs.Parse(args)
+
+	filtered := *all || *since != "" || *tag != ""
+	if len(ids) > 0 && filtered {
+		return fmt.Errorf("--id can't be combined with --all, --since or --tag")
+	}
+	if len(ids) == 0 && !filtered {
+		return fmt.Errorf("no sessions to export (use --id, --all, --since or --tag)")
+	}
+	query, err := exportQuery(*all, *since, *tag)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	dir := *out
+	if dir == "" {
+		dir = cfg.Storage.NotesDir
+	}
+	database, err := db.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	opts := export.Options{Template: cfg.Export.Template, Sessions: database}
+	if *tmpl != "" {
+		opts.Template = *tmpl
+	}
+	e, err := export.ForFormat(*format, opts)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	write := func(session db.Session) error {
+		messages, err := database.GetSessionMessages(session.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load messages of %s: %w", session.ID, err)
+		}
+		result, err := export.Save(database, e, session, messages, dir, cfg.Export.Update == config.ExportAppend)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", session.ID, err)
+		}
+		if result.Edited != "" {
+			fmt.Fprintf(os.Stderr, "%s was edited since it was exported, so it was left as is\n", result.Edited)
+		}
+		fmt.Println(result.Path)
+		count++
+		return nil
+	}
+
+	if len(ids) > 0 {
+		for _, id := range ids {
+			session, err := database.GetSession(id)
+			if err != nil {
+				return fmt.Errorf("failed to load session %s: %w", id, err)
+			}
+			if err := write(*session); err != nil {
+				return err
+			}
+		}
+	} else if err := database.EachSession(query, write); err != nil {
+		return err
+	}
+
+	noun := "sessions"
+	if count == 1 {
+		noun = "session"
+	}
+	fmt.Fprintf(os.Stderr, "exported %d %s to %s\n", count, noun, dir)
+	return nil
+}
+
+// exportQuery selects the sessions to export from the --all, --since and
+// --tag flags, oldest first. Dates and tags are read as in the history
+// filter.
+func exportQuery(all bool, since, tag string) (db.SessionQuery, error) {
+	var filter []string
+	if since != "" {
+		filter = append(filter, "since:"+since)
+	}
+	if tag != "" {
+		filter = append(filter, "tag:"+tag)
+	}
+	query, err := db.ParseQuery(strings.Join(filter, " "))
+	if err != nil {
+		return db.SessionQuery{}, err
+	}
+	query.IncludeArchived = all
+	query.Ascending = true
+	return query, nil
+}
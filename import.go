@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/importer"
+)
+
+// runImport stores the conversations in ChatGPT or Claude.ai data exports,
+// or in ai-tui JSON exports, skipping those imported before.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to config file")
+	from := fs.String("from", "", "Export format: "+strings.Join(importer.Sources, ", "))
+	branches := fs.Bool("branches", false, "Import edited and regenerated branches as forks")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ai-tui import --from %s [flags] FILE...\n", strings.Join(importer.Sources, "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if !slices.Contains(importer.Sources, *from) {
+		return fmt.Errorf("--from must be one of %s", strings.Join(importer.Sources, ", "))
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no files to import")
+	}
+
+	// Read everything before opening the database, so a bad file imports nothing
+	opts := importer.Options{Branches: *branches}
+	var convs []importer.Conversation
+	for _, path := range fs.Args() {
+		c, err := importer.ReadFile(*from, path, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		convs = append(convs, c...)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	database, err := db.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	sum, err := importer.Import(database, *from, convs)
+	fmt.Fprintln(os.Stderr, sum)
+	return err
+}
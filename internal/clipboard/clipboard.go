@@ -0,0 +1,54 @@
+package clipboard
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds how long an external clipboard command may run
+const commandTimeout = 5 * time.Second
+
+// Copy places text on the system clipboard. When command is non-empty it is
+// run with text on stdin (e.g. ["wl-copy"]); otherwise an OSC 52 escape
+// sequence is written to the terminal, which also works over SSH.
+func Copy(text string, command []string) error {
+	if len(command) > 0 {
+		return runCommand(command, text)
+	}
+	return writeOSC52(os.Stderr, text, os.Getenv("TMUX") != "")
+}
+
+func runCommand(command []string, text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("clipboard command %s failed: %s", command[0], msg)
+	}
+	return nil
+}
+
+// writeOSC52 writes the OSC 52 sequence for text to w, wrapping it in a tmux
+// passthrough sequence when running inside tmux.
+func writeOSC52(w io.Writer, text string, tmux bool) error {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	if _, err := io.WriteString(w, seq); err != nil {
+		return fmt.Errorf("failed to write OSC 52 sequence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOSC52(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeOSC52(&buf, "hello", false); err != nil {
+		t.Fatalf("writeOSC52() error: %v", err)
+	}
+
+	want := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("hello")) + "\a"
+	if buf.String() != want {
+		t.Errorf("writeOSC52() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteOSC52_Tmux(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeOSC52(&buf, "hello", true); err != nil {
+		t.Fatalf("writeOSC52() error: %v", err)
+	}
+
+	want := "\x1bPtmux;\x1b\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("hello")) + "\a\x1b\\"
+	if buf.String() != want {
+		t.Errorf("writeOSC52() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCopyWithCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "clip.txt")
+	if err := Copy("from stdin", []string{"sh", "-c", "cat > " + out}); err != nil {
+		t.Fatalf("Copy() error: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read command output: %v", err)
+	}
+	if string(data) != "from stdin" {
+		t.Errorf("command received %q, want %q", string(data), "from stdin")
+	}
+}
+
+func TestCopyWithFailingCommand(t *testing.T) {
+	err := Copy("text", []string{"sh", "-c", "echo nope >&2; exit 1"})
+	if err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("expected error with command output, got %v", err)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
@@ -14,14 +12,40 @@ type Config struct {
 	Providers       map[string]Provider `toml:"providers"`
 	Storage         Storage             `toml:"storage"`
 	UI              UI                  `toml:"ui"`
+	Context         Context             `toml:"context"`
+	Titles          Titles              `toml:"titles"`
+	// Pricing maps model names, or prefixes of them, to their prices
+	Pricing map[string]Price `toml:"pricing"`
+	Budget  Budget           `toml:"budget"`
+	Export  Export           `toml:"export"`
+	Persona Persona          `toml:"persona"`
+
+	// origins maps the paths of settings to where they were set
+	origins map[string]string
 }
 
 type Provider struct {
-	APIKey       string `toml:"api_key"`
+	APIKey string `toml:"api_key"`
+	// APIKeyCmd is a command that prints the API key, such as
+	// ["pass", "show", "anthropic"]; it's run on the first request
+	APIKeyCmd []string `toml:"api_key_cmd"`
+	// APIKeyFile is a file holding the API key
+	APIKeyFile   string `toml:"api_key_file"`
 	BaseURL      string `toml:"base_url"`
 	Model        string `toml:"model"`
 	SystemPrompt string `toml:"system_prompt"`
 	MaxTokens    int    `toml:"max_tokens"`
+	// ContextWindow overrides the built-in context window size for the model
+	ContextWindow int `toml:"context_window"`
+	// StreamUsage asks an OpenAI-compatible API for token usage with
+	// stream_options, which stricter servers reject; it defaults to true
+	// only for api.openai.com
+	StreamUsage bool `toml:"stream_usage"`
+	// Limits guard the provider's spending before each request
+	Limits Limits `toml:"limits"`
+	// KeyError is why the configured API key couldn't be resolved, nil if
+	// it was or none is configured
+	KeyError error `toml:"-"`
 }
 
 type Storage struct {
@@ -30,10 +54,124 @@ type Storage struct {
 }
 
 type UI struct {
-	ShowTokens bool `toml:"show_tokens"`
-	MaxWidth   int  `toml:"max_width"`
+	ShowTokens     bool     `toml:"show_tokens"`
+	MaxWidth       int      `toml:"max_width"`
+	NewlineKeys    []string `toml:"newline_keys"`
+	MaxInputHeight int      `toml:"max_input_height"`
+	ClipboardCmd   []string `toml:"clipboard_cmd"`
+}
+
+// Context controls what happens when a conversation outgrows the model's
+// context window.
+type Context struct {
+	// Strategy is "truncate" (drop the oldest turns) or "summarize"
+	// (replace older turns with a summary)
+	Strategy string `toml:"strategy"`
+	// SummaryProvider writes the summaries; defaults to the active provider
+	SummaryProvider string `toml:"summary_provider"`
+}
+
+// Titles controls how new sessions are named.
+type Titles struct {
+	// Generate asks a model for a short title after the first reply;
+	// otherwise the start of the first message is used
+	Generate bool `toml:"generate"`
+	// Provider writes the titles; defaults to the active provider
+	Provider string `toml:"provider"`
 }
 
+// Export controls how conversations are exported.
+type Export struct {
+	// Template is a text/template file that lays out Markdown exports;
+	// the built-in layout is used when unset
+	Template string `toml:"template"`
+	// Update is how exporting a session again changes its earlier file:
+	// "overwrite" rewrites it, "append" adds the new messages to it
+	Update string `toml:"update"`
+	// Auto exports the conversation to Markdown after every reply
+	Auto bool `toml:"auto"`
+}
+
+// Persona is who the assistant is for a project, usually set in the
+// project's .ai-tui.toml.
+type Persona struct {
+	// Name is recorded on new sessions, to find them in the history
+	Name string `toml:"name"`
+	// SystemPrompt replaces the system prompt of every provider
+	SystemPrompt string `toml:"system_prompt"`
+}
+
+// Export update modes
+const (
+	ExportOverwrite = "overwrite"
+	ExportAppend    = "append"
+)
+
+// Price is what a model costs, in dollars per million tokens.
+type Price struct {
+	Input      float64 `toml:"input"`
+	Output     float64 `toml:"output"`
+	CacheRead  float64 `toml:"cache_read"`
+	CacheWrite float64 `toml:"cache_write"`
+}
+
+// Budget sets a spending limit that the stats warn about.
+type Budget struct {
+	// Monthly is the limit in dollars per calendar month; 0 means none
+	Monthly float64 `toml:"monthly"`
+	// WarnAt is the fraction of the limit at which warnings start
+	WarnAt float64 `toml:"warn_at"`
+}
+
+// Limits cap what requests to a provider may use. Zero values are no limit.
+type Limits struct {
+	// Daily and Monthly cap the provider's spending in dollars per calendar
+	// day and month, counting the estimated cost of the next request
+	Daily   float64 `toml:"daily"`
+	Monthly float64 `toml:"monthly"`
+	// MaxInputTokens caps the estimated input of a single request
+	MaxInputTokens int `toml:"max_input_tokens"`
+	// Action is "confirm" (ask before sending) or "block" (refuse to send)
+	// when a request would exceed a limit
+	Action string `toml:"action"`
+}
+
+// Set reports whether any limit is configured.
+func (l Limits) Set() bool {
+	return l.Daily > 0 || l.Monthly > 0 || l.MaxInputTokens > 0
+}
+
+// Limit actions
+const (
+	LimitConfirm = "confirm"
+	LimitBlock   = "block"
+)
+
+// PriceFor returns the price of model: an exact entry if there is one,
+// otherwise the longest entry the model name starts with, so "claude-sonnet-4"
+// prices every dated release of it.
+func (c *Config) PriceFor(model string) (Price, bool) {
+	if p, ok := c.Pricing[model]; ok {
+		return p, true
+	}
+	var best string
+	for name := range c.Pricing {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return c.Pricing[best], true
+}
+
+// Context strategies
+const (
+	StrategyTruncate  = "truncate"
+	StrategySummarize = "summarize"
+)
+
 // DefaultPath returns ~/.config/ai-tui/config.toml
 func DefaultPath() string {
 	home, err := os.UserHomeDir()
@@ -43,15 +181,47 @@ func DefaultPath() string {
 	return filepath.Join(home, ".config", "ai-tui", "config.toml")
 }
 
-// Load reads and parses the TOML config file, expands env vars and ~, validates, applies defaults
-func Load(path string) (*Config, error) {
+// Load reads the config for the user config file at path, or DefaultPath
+// if empty, merged with the system and project files around it (see Files),
+// and applies sets, the values of --set flags. Only a path given explicitly
+// must exist, as long as one of the files does.
+func Load(path string, sets ...string) (*Config, error) {
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("config not found at %s, %s or a %s above the working directory (run 'ai-tui install' to set up config and launcher script)", DefaultPath(), SystemPath, ProjectFile)
+	}
+	return LoadFiles(files, sets...)
+}
+
+// LoadFiles merges the config files, expands env vars and ~, validates,
+// applies defaults.
+//
+// Settings are taken from, in increasing precedence: the defaults, each of
+// files in turn, AI_TUI_* environment variables, and sets. ${VAR} and
+// ${VAR:-default} references in string settings are then replaced.
+func LoadFiles(files []string, sets ...string) (*Config, error) {
 	var cfg Config
 
-	// Parse TOML file
-	if _, err := toml.DecodeFile(path, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	// Merge TOML files
+	for _, path := range files {
+		if err := cfg.mergeFile(path); err != nil {
+			return nil, err
+		}
 	}
 
+	// Apply AI_TUI_* overrides and flags, then interpolate ${VAR} references
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+	if err := applySets(&cfg, sets); err != nil {
+		return nil, err
+	}
+	applyPersona(&cfg)
+	unset := interpolateConfig(&cfg)
+
 	// Apply defaults
 	applyDefaults(&cfg)
 
@@ -63,16 +233,25 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// Resolve API keys; providers report a missing one when used
+	resolveKeys(&cfg, unset)
+
 	return &cfg, nil
 }
 
 func applyDefaults(cfg *Config) {
-	// Apply MaxTokens default
+	// Apply MaxTokens and limit action defaults
 	for name, provider := range cfg.Providers {
 		if provider.MaxTokens == 0 {
 			provider.MaxTokens = 4096
-			cfg.Providers[name] = provider
 		}
+		if provider.Limits.Action == "" {
+			provider.Limits.Action = LimitConfirm
+		}
+		if _, set := cfg.origins["providers."+name+".stream_usage"]; !set {
+			provider.StreamUsage = strings.Contains(provider.BaseURL, "api.openai.com")
+		}
+		cfg.Providers[name] = provider
 	}
 
 	// Apply MaxWidth default
@@ -80,6 +259,31 @@ func applyDefaults(cfg *Config) {
 		cfg.UI.MaxWidth = 100
 	}
 
+	// Apply NewlineKeys default
+	if len(cfg.UI.NewlineKeys) == 0 {
+		cfg.UI.NewlineKeys = []string{"alt+enter", "ctrl+j"}
+	}
+
+	// Apply MaxInputHeight default
+	if cfg.UI.MaxInputHeight == 0 {
+		cfg.UI.MaxInputHeight = 10
+	}
+
+	// Apply context strategy default
+	if cfg.Context.Strategy == "" {
+		cfg.Context.Strategy = StrategyTruncate
+	}
+
+	// Apply budget warning default
+	if cfg.Budget.WarnAt == 0 {
+		cfg.Budget.WarnAt = 0.8
+	}
+
+	// Apply export update default
+	if cfg.Export.Update == "" {
+		cfg.Export.Update = ExportOverwrite
+	}
+
 	// Apply DBPath default
 	if cfg.Storage.DBPath == "" {
 		cfg.Storage.DBPath = "~/.local/share/ai-tui/ai-tui.db"
@@ -100,13 +304,43 @@ func expandConfig(cfg *Config) {
 				provider.APIKey = val
 				cfg.Providers[name] = provider
 			}
-			// If env var is empty, leave as-is (don't error)
+			// If env var is empty, leave as-is for resolveKeys to report
 		}
 	}
 
 	// Expand ~ in storage paths
 	cfg.Storage.DBPath = expandHome(cfg.Storage.DBPath)
 	cfg.Storage.NotesDir = expandHome(cfg.Storage.NotesDir)
+	cfg.Export.Template = expandHome(cfg.Export.Template)
+	for name, provider := range cfg.Providers {
+		provider.APIKeyFile = expandHome(provider.APIKeyFile)
+		cfg.Providers[name] = provider
+	}
+}
+
+// resolveKeys reads API keys from api_key_file, and records in KeyError
+// the keys that can't be resolved. Keys from api_key_cmd are resolved on the
+// first request instead. unset maps settings to the variable they referenced
+// that isn't set, as returned by interpolateConfig.
+func resolveKeys(cfg *Config, unset map[string]string) {
+	for name, provider := range cfg.Providers {
+		switch {
+		case provider.APIKeyFile != "":
+			data, err := os.ReadFile(provider.APIKeyFile)
+			if err != nil {
+				provider.KeyError = fmt.Errorf("failed to read api_key_file: %w", err)
+			} else if key := strings.TrimSpace(string(data)); key == "" {
+				provider.KeyError = fmt.Errorf("api_key_file %s is empty", provider.APIKeyFile)
+			} else {
+				provider.APIKey = key
+			}
+		case unset["providers."+name+".api_key"] != "":
+			provider.KeyError = fmt.Errorf("api_key: environment variable %s is not set", unset["providers."+name+".api_key"])
+		case strings.HasPrefix(provider.APIKey, "$"):
+			provider.KeyError = fmt.Errorf("api_key: environment variable %s is not set", provider.APIKey[1:])
+		}
+		cfg.Providers[name] = provider
+	}
 }
 
 func expandHome(path string) string {
@@ -145,5 +379,58 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("default_provider '%s' not found in providers", cfg.DefaultProvider)
 	}
 
+	if cfg.Context.Strategy != StrategyTruncate && cfg.Context.Strategy != StrategySummarize {
+		return fmt.Errorf("context.strategy must be '%s' or '%s', got '%s'", StrategyTruncate, StrategySummarize, cfg.Context.Strategy)
+	}
+
+	if name := cfg.Context.SummaryProvider; name != "" {
+		if _, ok := cfg.Providers[name]; !ok {
+			return fmt.Errorf("context.summary_provider '%s' not found in providers", name)
+		}
+	}
+
+	if name := cfg.Titles.Provider; name != "" {
+		if _, ok := cfg.Providers[name]; !ok {
+			return fmt.Errorf("titles.provider '%s' not found in providers", name)
+		}
+	}
+
+	for name, provider := range cfg.Providers {
+		sources := 0
+		for _, set := range []bool{provider.APIKey != "", len(provider.APIKeyCmd) > 0, provider.APIKeyFile != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("providers.%s: set only one of api_key, api_key_cmd and api_key_file", name)
+		}
+
+		l := provider.Limits
+		if l.Daily < 0 || l.Monthly < 0 || l.MaxInputTokens < 0 {
+			return fmt.Errorf("providers.%s.limits can't be negative", name)
+		}
+		if l.Action != LimitConfirm && l.Action != LimitBlock {
+			return fmt.Errorf("providers.%s.limits.action must be '%s' or '%s', got '%s'", name, LimitConfirm, LimitBlock, l.Action)
+		}
+	}
+
+	for model, p := range cfg.Pricing {
+		if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+			return fmt.Errorf("pricing for '%s' can't be negative", model)
+		}
+	}
+
+	if cfg.Export.Update != ExportOverwrite && cfg.Export.Update != ExportAppend {
+		return fmt.Errorf("export.update must be '%s' or '%s', got '%s'", ExportOverwrite, ExportAppend, cfg.Export.Update)
+	}
+
+	if cfg.Budget.Monthly < 0 {
+		return fmt.Errorf("budget.monthly can't be negative")
+	}
+	if cfg.Budget.WarnAt <= 0 || cfg.Budget.WarnAt > 1 {
+		return fmt.Errorf("budget.warn_at must be between 0 and 1, got %g", cfg.Budget.WarnAt)
+	}
+
 	return nil
 }
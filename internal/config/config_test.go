@@ -114,7 +114,7 @@ model = "gpt-4"
 			},
 		},
 		{
-			name: "env var not set leaves as-is",
+			name: "env var not set for default provider recorded",
 			content: `
 default_provider = "openai"
 
@@ -122,15 +122,84 @@ default_provider = "openai"
 api_key = "$NONEXISTENT_VAR"
 base_url = "https://api.openai.com/v1"
 model = "gpt-4"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if err := cfg.Providers["openai"].KeyError; err == nil || err.Error() != "api_key: environment variable NONEXISTENT_VAR is not set" {
+					t.Errorf("openai.KeyError = %v, want the unset variable", err)
+				}
+			},
+		},
+		{
+			name: "env var not set for other provider recorded",
+			content: `
+default_provider = "local"
+
+[providers.local]
+base_url = "http://localhost:11434/v1"
+model = "llama3"
+
+[providers.openai]
+api_key = "$NONEXISTENT_VAR"
+model = "gpt-4"
 `,
 			wantErr: false,
 			validate: func(t *testing.T, cfg *Config) {
 				openai := cfg.Providers["openai"]
-				if openai.APIKey != "$NONEXISTENT_VAR" {
-					t.Errorf("openai.APIKey = %q, want %q (env var left as-is)", openai.APIKey, "$NONEXISTENT_VAR")
+				if openai.KeyError == nil || !strings.Contains(openai.KeyError.Error(), "NONEXISTENT_VAR is not set") {
+					t.Errorf("openai.KeyError = %v, want the unset variable", openai.KeyError)
+				}
+				if local := cfg.Providers["local"]; local.KeyError != nil {
+					t.Errorf("local.KeyError = %v, want nil for a provider without a key", local.KeyError)
 				}
 			},
 		},
+		{
+			name: "api_key_cmd kept for the first request",
+			content: `
+default_provider = "claude"
+
+[providers.claude]
+api_key_cmd = ["pass", "show", "anthropic"]
+model = "claude-sonnet-4"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				claude := cfg.Providers["claude"]
+				if strings.Join(claude.APIKeyCmd, " ") != "pass show anthropic" || claude.APIKey != "" || claude.KeyError != nil {
+					t.Errorf("claude = %+v, want the command unresolved", claude)
+				}
+			},
+		},
+		{
+			name: "api_key_file missing recorded",
+			content: `
+default_provider = "claude"
+
+[providers.claude]
+api_key_file = "/nonexistent/anthropic.key"
+model = "claude-sonnet-4"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if err := cfg.Providers["claude"].KeyError; err == nil || !strings.Contains(err.Error(), "failed to read api_key_file") {
+					t.Errorf("claude.KeyError = %v, want the unreadable file", err)
+				}
+			},
+		},
+		{
+			name: "several key sources error",
+			content: `
+default_provider = "claude"
+
+[providers.claude]
+api_key = "sk-test"
+api_key_cmd = ["pass", "show", "anthropic"]
+model = "claude-sonnet-4"
+`,
+			wantErr: true,
+			errMsg:  "set only one of api_key, api_key_cmd and api_key_file",
+		},
 		{
 			name: "tilde expansion in paths",
 			content: `
@@ -178,6 +247,18 @@ model = "gpt-4"
 				if cfg.UI.MaxWidth != 100 {
 					t.Errorf("UI.MaxWidth = %d, want 100 (default)", cfg.UI.MaxWidth)
 				}
+				if len(cfg.UI.NewlineKeys) != 2 || cfg.UI.NewlineKeys[0] != "alt+enter" {
+					t.Errorf("UI.NewlineKeys = %v, want [alt+enter ctrl+j] (default)", cfg.UI.NewlineKeys)
+				}
+				if cfg.UI.MaxInputHeight != 10 {
+					t.Errorf("UI.MaxInputHeight = %d, want 10 (default)", cfg.UI.MaxInputHeight)
+				}
+				if cfg.Context.Strategy != StrategyTruncate {
+					t.Errorf("Context.Strategy = %q, want %q (default)", cfg.Context.Strategy, StrategyTruncate)
+				}
+				if cfg.Export.Update != ExportOverwrite {
+					t.Errorf("Export.Update = %q, want %q (default)", cfg.Export.Update, ExportOverwrite)
+				}
 
 				home, _ := os.UserHomeDir()
 				expectedDB := filepath.Join(home, ".local/share/ai-tui/ai-tui.db")
@@ -211,6 +292,384 @@ model = "gpt-4"
 			wantErr: true,
 			errMsg:  "default_provider 'nonexistent' not found in providers",
 		},
+		{
+			name: "context window and summarize strategy",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4"
+context_window = 32000
+
+[providers.cheap]
+api_key = "test"
+model = "gpt-4o-mini"
+
+[context]
+strategy = "summarize"
+summary_provider = "cheap"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Providers["openai"].ContextWindow != 32000 {
+					t.Errorf("openai.ContextWindow = %d, want 32000", cfg.Providers["openai"].ContextWindow)
+				}
+				if cfg.Context.Strategy != StrategySummarize || cfg.Context.SummaryProvider != "cheap" {
+					t.Errorf("Context = %+v, want summarize with cheap", cfg.Context)
+				}
+			},
+		},
+		{
+			name: "invalid context strategy error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4"
+
+[context]
+strategy = "forget"
+`,
+			wantErr: true,
+			errMsg:  "context.strategy must be",
+		},
+		{
+			name: "unknown summary provider error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4"
+
+[context]
+strategy = "summarize"
+summary_provider = "missing"
+`,
+			wantErr: true,
+			errMsg:  "context.summary_provider 'missing' not found",
+		},
+		{
+			name: "title generation",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4"
+
+[titles]
+generate = true
+provider = "openai"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if !cfg.Titles.Generate || cfg.Titles.Provider != "openai" {
+					t.Errorf("Titles = %+v, want generate with openai", cfg.Titles)
+				}
+			},
+		},
+		{
+			name: "unknown title provider error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4"
+
+[titles]
+provider = "missing"
+`,
+			wantErr: true,
+			errMsg:  "titles.provider 'missing' not found",
+		},
+		{
+			name: "pricing and budget",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[pricing."gpt-4o"]
+input = 2.5
+output = 10
+cache_read = 1.25
+
+[budget]
+monthly = 20
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				want := Price{Input: 2.5, Output: 10, CacheRead: 1.25}
+				if cfg.Pricing["gpt-4o"] != want {
+					t.Errorf("Pricing[gpt-4o] = %+v, want %+v", cfg.Pricing["gpt-4o"], want)
+				}
+				if cfg.Budget.Monthly != 20 || cfg.Budget.WarnAt != 0.8 {
+					t.Errorf("Budget = %+v, want monthly 20 warning at 0.8", cfg.Budget)
+				}
+			},
+		},
+		{
+			name: "provider limits",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[providers.openai.limits]
+daily = 2
+monthly = 30
+max_input_tokens = 50000
+
+[providers.local]
+api_key = "test"
+model = "llama3"
+
+[providers.local.limits]
+action = "block"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				want := Limits{Daily: 2, Monthly: 30, MaxInputTokens: 50000, Action: LimitConfirm}
+				if got := cfg.Providers["openai"].Limits; got != want {
+					t.Errorf("openai limits = %+v, want %+v", got, want)
+				}
+				if cfg.Providers["local"].Limits.Set() {
+					t.Error("expected no limits set on local")
+				}
+			},
+		},
+		{
+			name: "invalid limit action error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[providers.openai.limits]
+daily = 2
+action = "warn"
+`,
+			wantErr: true,
+			errMsg:  "providers.openai.limits.action must be 'confirm' or 'block'",
+		},
+		{
+			name: "negative price error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[pricing."gpt-4o"]
+input = -1
+`,
+			wantErr: true,
+			errMsg:  "pricing for 'gpt-4o' can't be negative",
+		},
+		{
+			name: "budget warn_at out of range error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[budget]
+monthly = 10
+warn_at = 1.5
+`,
+			wantErr: true,
+			errMsg:  "budget.warn_at must be between 0 and 1",
+		},
+		{
+			name: "invalid export update error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[export]
+update = "merge"
+`,
+			wantErr: true,
+			errMsg:  "export.update must be 'overwrite' or 'append'",
+		},
+		{
+			name: "stream_usage defaults to the official OpenAI API",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+base_url = "https://api.openai.com/v1"
+model = "gpt-4o"
+
+[providers.local]
+base_url = "http://localhost:11434/v1"
+model = "llama3"
+
+[providers.vllm]
+base_url = "http://localhost:8000/v1"
+model = "qwen"
+stream_usage = true
+
+[providers.proxy]
+base_url = "https://api.openai.com/v1"
+model = "gpt-4o"
+stream_usage = false
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				want := map[string]bool{"openai": true, "local": false, "vllm": true, "proxy": false}
+				for name, w := range want {
+					if got := cfg.Providers[name].StreamUsage; got != w {
+						t.Errorf("providers.%s.StreamUsage = %v, want %v", name, got, w)
+					}
+				}
+			},
+		},
+		{
+			name: "interpolation in string settings",
+			content: `
+default_provider = "local"
+
+[providers.local]
+base_url = "http://${TEST_HOST}:11434/v1"
+model = "${TEST_MODEL:-llama3}"
+system_prompt = "Use $${HOME} in shell examples"
+api_key_cmd = ["pass", "show", "${TEST_ENTRY}"]
+
+[storage]
+notes_dir = "${TEST_NOTES}/notes"
+
+[ui]
+clipboard_cmd = ["${TEST_CLIP:-wl-copy}"]
+`,
+			envVars: map[string]string{
+				"TEST_HOST":  "gpu-box",
+				"TEST_ENTRY": "ollama",
+				"TEST_NOTES": "/srv",
+				"TEST_CLIP":  "",
+			},
+			validate: func(t *testing.T, cfg *Config) {
+				local := cfg.Providers["local"]
+				if local.BaseURL != "http://gpu-box:11434/v1" {
+					t.Errorf("BaseURL = %q, want the variable interpolated", local.BaseURL)
+				}
+				if local.Model != "llama3" {
+					t.Errorf("Model = %q, want the default", local.Model)
+				}
+				if local.SystemPrompt != "Use ${HOME} in shell examples" {
+					t.Errorf("SystemPrompt = %q, want $${ kept as ${", local.SystemPrompt)
+				}
+				if got := strings.Join(local.APIKeyCmd, " "); got != "pass show ollama" {
+					t.Errorf("APIKeyCmd = %q, want the variable interpolated", got)
+				}
+				if cfg.Storage.NotesDir != "/srv/notes" {
+					t.Errorf("NotesDir = %q, want the variable interpolated", cfg.Storage.NotesDir)
+				}
+				if got := strings.Join(cfg.UI.ClipboardCmd, " "); got != "wl-copy" {
+					t.Errorf("ClipboardCmd = %q, want the default for an empty variable", got)
+				}
+			},
+		},
+		{
+			name: "interpolated api_key not set recorded",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "${NONEXISTENT_VAR}"
+model = "gpt-4"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if err := cfg.Providers["openai"].KeyError; err == nil || err.Error() != "api_key: environment variable NONEXISTENT_VAR is not set" {
+					t.Errorf("openai.KeyError = %v, want the unset variable", err)
+				}
+			},
+		},
+		{
+			name: "environment overrides",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+
+[providers.local]
+base_url = "http://localhost:11434/v1"
+model = "${TEST_MODEL:-llama3}"
+
+[pricing.gpt-4o]
+input = 2.5
+
+[ui]
+show_tokens = false
+`,
+			envVars: map[string]string{
+				"AI_TUI_DEFAULT_PROVIDER":           "local",
+				"AI_TUI_PROVIDERS_LOCAL_BASE_URL":   "http://${TEST_HOST}:11434/v1",
+				"AI_TUI_PROVIDERS_LOCAL_MAX_TOKENS": "1024",
+				"AI_TUI_PRICING_GPT_4O_INPUT":       "3",
+				"AI_TUI_UI_SHOW_TOKENS":             "true",
+				"AI_TUI_UI_NEWLINE_KEYS":            "ctrl+j, shift+enter",
+				"AI_TUI_UI_CLIPBOARD_CMD":           `["xclip", "-selection", "clipboard"]`,
+				"TEST_HOST":                         "gpu-box",
+			},
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.DefaultProvider != "local" {
+					t.Errorf("DefaultProvider = %q, want the override", cfg.DefaultProvider)
+				}
+				local := cfg.Providers["local"]
+				if local.BaseURL != "http://gpu-box:11434/v1" {
+					t.Errorf("BaseURL = %q, want the override interpolated", local.BaseURL)
+				}
+				if local.MaxTokens != 1024 {
+					t.Errorf("MaxTokens = %d, want 1024", local.MaxTokens)
+				}
+				if local.Model != "llama3" {
+					t.Errorf("Model = %q, want the file's value", local.Model)
+				}
+				if p := cfg.Pricing["gpt-4o"]; p.Input != 3 {
+					t.Errorf("gpt-4o input price = %g, want 3", p.Input)
+				}
+				if !cfg.UI.ShowTokens {
+					t.Error("ShowTokens = false, want the override")
+				}
+				if got := strings.Join(cfg.UI.NewlineKeys, " "); got != "ctrl+j shift+enter" {
+					t.Errorf("NewlineKeys = %q, want the comma-separated list", got)
+				}
+				if got := strings.Join(cfg.UI.ClipboardCmd, " "); got != "xclip -selection clipboard" {
+					t.Errorf("ClipboardCmd = %q, want the TOML array", got)
+				}
+			},
+		},
+		{
+			name: "invalid environment override error",
+			content: `
+default_provider = "openai"
+
+[providers.openai]
+api_key = "test"
+model = "gpt-4o"
+`,
+			envVars: map[string]string{
+				"AI_TUI_UI_MAX_WIDTH": "wide",
+			},
+			wantErr: true,
+			errMsg:  "AI_TUI_UI_MAX_WIDTH: invalid number 'wide'",
+		},
 		{
 			name: "missing default_provider error",
 			content: `
@@ -259,11 +718,80 @@ model = "gpt-4"
 	}
 }
 
+func TestPriceFor(t *testing.T) {
+	cfg := &Config{Pricing: map[string]Price{
+		"claude-sonnet-4":          {Input: 3, Output: 15},
+		"claude-sonnet-4-20250514": {Input: 2, Output: 10},
+		"claude":                   {Input: 1, Output: 1},
+	}}
+
+	tests := []struct {
+		model string
+		want  Price
+		ok    bool
+	}{
+		{"claude-sonnet-4-20250514", Price{Input: 2, Output: 10}, true},
+		{"claude-sonnet-4-5", Price{Input: 3, Output: 15}, true},
+		{"claude-opus-4", Price{Input: 1, Output: 1}, true},
+		{"gpt-4o", Price{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.PriceFor(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("PriceFor(%q) = %+v, %v; want %+v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 func TestLoadNonexistentFile(t *testing.T) {
 	_, err := Load("/nonexistent/path/to/config.toml")
 	if err == nil {
 		t.Fatal("Load() with nonexistent file should return error")
 	}
+	if !strings.Contains(err.Error(), "/nonexistent/path/to/config.toml not found") {
+		t.Errorf("Load() error = %q, want the explicit path reported missing", err)
+	}
+
+	// Without any config at all, point at ai-tui install
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	if _, err := os.Stat(SystemPath); err == nil {
+		t.Skipf("%s exists", SystemPath)
+	}
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "ai-tui install") {
+		t.Errorf("Load() error = %v, want the install hint", err)
+	}
+}
+
+func TestLoadWithoutUserConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectFile), []byte(`
+default_provider = "local"
+
+[providers.local]
+model = "llama3"
+`), 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+
+	files, err := Files("")
+	if err != nil {
+		t.Fatalf("Files() unexpected error: %v", err)
+	}
+	for _, file := range files {
+		if file == DefaultPath() {
+			t.Errorf("Files() = %v, want the missing user config left out", files)
+		}
+	}
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Providers["local"].Model != "llama3" {
+		t.Errorf("Providers = %+v, want the project's provider", cfg.Providers)
+	}
 }
 
 func TestLoadInvalidTOML(t *testing.T) {
@@ -274,6 +802,192 @@ func TestLoadInvalidTOML(t *testing.T) {
 	}
 }
 
+func TestLoadAPIKeyFile(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "anthropic.key")
+	if err := os.WriteFile(keyPath, []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	path := writeTempConfig(t, `
+default_provider = "claude"
+
+[providers.claude]
+api_key_file = "`+keyPath+`"
+model = "claude-sonnet-4"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if got := cfg.Providers["claude"].APIKey; got != "sk-from-file" {
+		t.Errorf("APIKey = %q, want the trimmed file contents", got)
+	}
+
+	if err := os.WriteFile(keyPath, []byte("\n"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if err := cfg.Providers["claude"].KeyError; err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("KeyError with an empty key file = %v, want it reported empty", err)
+	}
+}
+
+func TestLoadFilesLayers(t *testing.T) {
+	system := writeTempConfig(t, `
+default_provider = "claude"
+
+[providers.claude]
+api_key = "sk-system"
+base_url = "https://api.anthropic.com/v1"
+model = "claude-sonnet-4"
+
+[pricing.claude-sonnet-4]
+input = 3
+output = 15
+`)
+	user := writeTempConfig(t, `
+[providers.claude]
+model = "claude-opus-4"
+system_prompt = "Be concise."
+
+[providers.local]
+base_url = "http://localhost:11434/v1"
+model = "llama3"
+
+[ui]
+newline_keys = ["ctrl+j"]
+`)
+	project := filepath.Join(t.TempDir(), ProjectFile)
+	if err := os.WriteFile(project, []byte(`
+default_provider = "local"
+
+[persona]
+name = "go-reviewer"
+system_prompt = "Review Go code."
+
+[providers.local]
+model = "qwen2.5-coder"
+`), 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+	t.Setenv("AI_TUI_PROVIDERS_LOCAL_MAX_TOKENS", "1024")
+
+	cfg, err := LoadFiles([]string{system, user, project}, "pricing.claude-sonnet-4.output=12")
+	if err != nil {
+		t.Fatalf("LoadFiles() unexpected error: %v", err)
+	}
+
+	if cfg.DefaultProvider != "local" {
+		t.Errorf("DefaultProvider = %q, want the project's", cfg.DefaultProvider)
+	}
+	claude := cfg.Providers["claude"]
+	if claude.APIKey != "sk-system" || claude.BaseURL != "https://api.anthropic.com/v1" || claude.Model != "claude-opus-4" {
+		t.Errorf("claude = %+v, want the system provider with the user's model", claude)
+	}
+	local := cfg.Providers["local"]
+	if local.BaseURL != "http://localhost:11434/v1" || local.Model != "qwen2.5-coder" || local.MaxTokens != 1024 {
+		t.Errorf("local = %+v, want the user's provider with the project's model and the env max_tokens", local)
+	}
+	for name, p := range cfg.Providers {
+		if p.SystemPrompt != "Review Go code." {
+			t.Errorf("providers.%s.system_prompt = %q, want the persona's", name, p.SystemPrompt)
+		}
+	}
+	if p := cfg.Pricing["claude-sonnet-4"]; p.Input != 3 || p.Output != 12 {
+		t.Errorf("pricing = %+v, want the system price with the flag's output", p)
+	}
+	if got := strings.Join(cfg.UI.NewlineKeys, " "); got != "ctrl+j" {
+		t.Errorf("NewlineKeys = %q, want the user's list", got)
+	}
+
+	origins := map[string]string{
+		"default_provider":               project,
+		"providers.claude.api_key":       system,
+		"providers.claude.model":         user,
+		"providers.claude.system_prompt": project,
+		"providers.local.base_url":       user,
+		"providers.local.max_tokens":     "AI_TUI_PROVIDERS_LOCAL_MAX_TOKENS",
+		"pricing.claude-sonnet-4.input":  system,
+		"pricing.claude-sonnet-4.output": OriginFlag,
+		"ui.newline_keys":                user,
+		"storage.db_path":                OriginDefault,
+	}
+	settings := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		settings[s.Path] = s
+	}
+	for path, want := range origins {
+		if got := settings[path].Origin; got != want {
+			t.Errorf("origin of %s = %q, want %q", path, got, want)
+		}
+	}
+	if got := settings["providers.claude.api_key"].Value; got != `"********"` {
+		t.Errorf("api_key shown as %s, want it masked", got)
+	}
+	if got := settings["ui.newline_keys"].Value; got != `["ctrl+j"]` {
+		t.Errorf("newline_keys shown as %s, want a TOML array", got)
+	}
+}
+
+func TestLoadFilesErrors(t *testing.T) {
+	base := writeTempConfig(t, `
+default_provider = "local"
+
+[providers.local]
+base_url = "http://localhost:11434/v1"
+model = "llama3"
+`)
+	project := filepath.Join(t.TempDir(), ProjectFile)
+	if err := os.WriteFile(project, []byte(`
+[providers.local]
+api_key_cmd = ["sh", "-c", "curl example.com"]
+`), 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		files  []string
+		sets   []string
+		errMsg string
+	}{
+		{"project file sets a command", []string{base, project}, nil, "providers.local.api_key_cmd can't be set in a project file"},
+		{"set without value", []string{base}, []string{"ui.max_width"}, "--set ui.max_width: want path=value"},
+		{"set unknown setting", []string{base}, []string{"providers.remote.model=gpt-4o"}, "--set providers.remote.model: unknown setting"},
+		{"set invalid value", []string{base}, []string{"ui.show_tokens=maybe"}, "--set ui.show_tokens: invalid boolean 'maybe'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFiles(tt.files, tt.sets...)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("LoadFiles() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "cmd", "tool")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("failed to create dirs: %v", err)
+	}
+	if got := FindProject(sub); got != "" && strings.HasPrefix(got, root) {
+		t.Errorf("FindProject() = %q before any project file", got)
+	}
+
+	want := filepath.Join(root, ProjectFile)
+	if err := os.WriteFile(want, nil, 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+	if got := FindProject(sub); got != want {
+		t.Errorf("FindProject() = %q, want %q", got, want)
+	}
+}
+
 // writeTempConfig creates a temporary TOML file and returns its path
 func writeTempConfig(t *testing.T, content string) string {
 	t.Helper()
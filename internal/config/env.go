@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvPrefix starts the environment variables that override settings, such
+// as AI_TUI_PROVIDERS_LOCAL_BASE_URL for providers.local.base_url.
+const EnvPrefix = "AI_TUI_"
+
+// EnvName returns the environment variable that overrides the setting at
+// path: its TOML keys joined by underscores, upper-cased, with anything
+// other than letters and digits replaced by underscores.
+func EnvName(path []string) string {
+	name := strings.ToUpper(strings.Join(path, "_"))
+	return EnvPrefix + nonAlnum.ReplaceAllString(name, "_")
+}
+
+var nonAlnum = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// applyEnvOverrides sets each setting that has an AI_TUI_* variable to its
+// value. Only providers and prices the config defines can be overridden.
+func applyEnvOverrides(cfg *Config) error {
+	return walkFields(reflect.ValueOf(cfg).Elem(), nil, func(path []string, v reflect.Value) error {
+		name := EnvName(path)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		if err := setString(v, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		cfg.setOrigin(path, name)
+		return nil
+	})
+}
+
+// setString sets a setting from its text in an environment variable.
+// Lists are TOML arrays, or their elements separated by commas.
+func setString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid number '%s'", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number '%s'", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid boolean '%s'", s)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		if strings.HasPrefix(strings.TrimSpace(s), "[") {
+			var doc struct{ V []string }
+			if _, err := toml.Decode("V = "+s, &doc); err != nil {
+				return fmt.Errorf("invalid list '%s'", s)
+			}
+			list = doc.V
+		} else {
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("can't be set from the environment")
+	}
+	return nil
+}
+
+// reference matches ${VAR} and ${VAR:-default}, and $${ for a literal ${.
+var reference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces the ${VAR} and ${VAR:-default} references in s with
+// the variable's value, or the default when it's unset or empty. It also
+// returns the variables referenced without a default that aren't set.
+func interpolate(s string) (string, []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var unset []string
+	out := reference.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := reference.FindStringSubmatch(ref)
+		name, def := m[1], m[2]
+		value := os.Getenv(name)
+		switch {
+		case value != "":
+			return value
+		case strings.Contains(ref, ":-"):
+			return def
+		}
+		if _, ok := os.LookupEnv(name); !ok {
+			unset = append(unset, name)
+		}
+		return ""
+	})
+	return out, unset
+}
+
+// interpolateConfig interpolates every string setting, and returns the
+// settings that referenced unset variables, by path, with the first one.
+func interpolateConfig(cfg *Config) map[string]string {
+	unset := make(map[string]string)
+	walkFields(reflect.ValueOf(cfg).Elem(), nil, func(path []string, v reflect.Value) error {
+		var missing []string
+		switch {
+		case v.Kind() == reflect.String:
+			s, m := interpolate(v.String())
+			v.SetString(s)
+			missing = m
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < v.Len(); i++ {
+				s, m := interpolate(v.Index(i).String())
+				v.Index(i).SetString(s)
+				missing = append(missing, m...)
+			}
+		}
+		if len(missing) > 0 {
+			unset[strings.Join(path, ".")] = missing[0]
+		}
+		return nil
+	})
+	return unset
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// walkFields calls fn with each setting in v and its TOML path, such as
+// ["providers", "local", "base_url"]. Settings are strings, numbers, bools
+// and lists; maps are walked by their keys, so map entries that aren't
+// defined have no settings. fn may change the setting through its value.
+func walkFields(v reflect.Value, path []string, fn func(path []string, v reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			if err := walkFields(v.Field(i), appendPath(path, name), fn); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Map entries can't be changed in place, so walk a copy and
+			// store it back
+			key := reflect.ValueOf(k)
+			entry := reflect.New(v.Type().Elem()).Elem()
+			entry.Set(v.MapIndex(key))
+			if err := walkFields(entry, appendPath(path, k), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, entry)
+		}
+		return nil
+	}
+	return fn(path, v)
+}
+
+// setPath sets the setting at path in v to value, adding the map entries
+// on the way that don't exist yet.
+func setPath(v reflect.Value, path []string, value reflect.Value) {
+	if len(path) == 0 {
+		v.Set(value)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ","); name == path[0] {
+				setPath(v.Field(i), path[1:], value)
+				return
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(path[0])
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			entry.Set(existing)
+		}
+		setPath(entry, path[1:], value)
+		v.SetMapIndex(key, entry)
+	}
+}
+
+// appendPath extends path without sharing its backing array.
+func appendPath(path []string, name string) []string {
+	return append(path[:len(path):len(path)], name)
+}
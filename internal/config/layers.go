@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SystemPath is the system-wide config, merged beneath the user's.
+const SystemPath = "/etc/ai-tui/config.toml"
+
+// ProjectFile is the name of a project's config, merged over the user's when
+// ai-tui is started in its directory or below.
+const ProjectFile = ".ai-tui.toml"
+
+// Origins of settings that weren't set by a file or an environment variable
+const (
+	OriginDefault = "default"
+	OriginFlag    = "--set"
+)
+
+// Setting is an effective config value and where it came from.
+type Setting struct {
+	Path   string // e.g. "providers.local.base_url"
+	Value  string // formatted as in TOML
+	Origin string // the file or AI_TUI_* variable that set it, OriginFlag or OriginDefault
+}
+
+// Files returns the config files Load merges for the user config at path,
+// lowest precedence first: SystemPath if it exists, path, and the nearest
+// project file above the working directory if there is one. An empty path
+// stands for DefaultPath, which may be missing; any other path must exist.
+func Files(path string) ([]string, error) {
+	var files []string
+	ok, err := exists(SystemPath)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		files = append(files, SystemPath)
+	}
+
+	required := path != ""
+	if !required {
+		path = DefaultPath()
+	}
+	ok, err = exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		files = append(files, path)
+	} else if required {
+		return nil, fmt.Errorf("config file %s not found", path)
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if project := FindProject(wd); project != "" && project != path {
+			files = append(files, project)
+		}
+	}
+	return files, nil
+}
+
+// exists reports whether the config file at path exists. Errors other than
+// its absence, such as missing permissions, are returned.
+func exists(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("config file %s is a directory", path)
+	}
+	return true, nil
+}
+
+// FindProject returns the ProjectFile in dir or the nearest directory above
+// it, or "" if there is none.
+func FindProject(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// mergeFile sets each setting the file at path defines, over those of the
+// files merged before it. Maps are merged by key, so a file can change one
+// setting of a provider defined in another; lists are replaced whole.
+func (c *Config) mergeFile(path string) error {
+	var layer Config
+	md, err := toml.DecodeFile(path, &layer)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	project := filepath.Base(path) == ProjectFile
+	return walkFields(reflect.ValueOf(&layer).Elem(), nil, func(p []string, v reflect.Value) error {
+		if !md.IsDefined(p...) {
+			return nil
+		}
+		if project && projectDenied(p) {
+			return fmt.Errorf("%s: %s can't be set in a project file", path, strings.Join(p, "."))
+		}
+		setPath(reflect.ValueOf(c).Elem(), p, v)
+		c.setOrigin(p, path)
+		return nil
+	})
+}
+
+// projectDenied reports whether a project file may not set the setting at
+// path. A repository someone else wrote could use these to run commands or
+// to send API keys elsewhere.
+func projectDenied(path []string) bool {
+	switch path[len(path)-1] {
+	case "api_key", "api_key_cmd", "api_key_file", "base_url", "clipboard_cmd":
+		return true
+	}
+	return false
+}
+
+// applySets applies --set flags, each "path=value" with the value as in an
+// AI_TUI_* variable, e.g. "providers.local.model=llama3".
+func applySets(cfg *Config, sets []string) error {
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("--set %s: want path=value", set)
+		}
+		found := false
+		err := walkFields(reflect.ValueOf(cfg).Elem(), nil, func(p []string, v reflect.Value) error {
+			if strings.Join(p, ".") != strings.TrimSpace(key) {
+				return nil
+			}
+			found = true
+			if err := setString(v, value); err != nil {
+				return fmt.Errorf("--set %s: %w", key, err)
+			}
+			cfg.setOrigin(p, OriginFlag)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("--set %s: unknown setting", key)
+		}
+	}
+	return nil
+}
+
+// applyPersona gives every provider the persona's system prompt, if it has
+// one.
+func applyPersona(cfg *Config) {
+	if cfg.Persona.SystemPrompt == "" {
+		return
+	}
+	origin := cfg.origins["persona.system_prompt"]
+	for name, provider := range cfg.Providers {
+		provider.SystemPrompt = cfg.Persona.SystemPrompt
+		cfg.Providers[name] = provider
+		cfg.setOrigin([]string{"providers", name, "system_prompt"}, origin)
+	}
+}
+
+func (c *Config) setOrigin(path []string, origin string) {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[strings.Join(path, ".")] = origin
+}
+
+// Origin returns the file or AI_TUI_* variable that set the setting at
+// path, such as "providers.local.base_url", OriginFlag if a --set flag did,
+// or OriginDefault if none did.
+func (c *Config) Origin(path string) string {
+	if origin, ok := c.origins[path]; ok {
+		return origin
+	}
+	return OriginDefault
+}
+
+// Settings returns every effective setting, in the order of the config
+// file, with maps sorted by key. API keys are masked.
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	walkFields(reflect.ValueOf(c).Elem(), nil, func(p []string, v reflect.Value) error {
+		path := strings.Join(p, ".")
+		value := formatValue(v)
+		if p[len(p)-1] == "api_key" && v.String() != "" {
+			value = `"********"`
+		}
+		settings = append(settings, Setting{Path: path, Value: value, Origin: c.Origin(path)})
+		return nil
+	})
+	return settings
+}
+
+// formatValue formats a setting as in TOML.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = strconv.Quote(v.Index(i).String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -37,6 +37,76 @@ CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
 CREATE INDEX IF NOT EXISTS idx_sessions_created ON sessions(created_at DESC);
 `
 
+// migrations are schema changes applied in order on top of migrationSQL.
+// PRAGMA user_version records how many have run, so entries must only be appended.
+var migrations = []string{
+	`ALTER TABLE sessions ADD COLUMN parent_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE messages ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE messages ADD COLUMN summarized INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE sessions ADD COLUMN persona TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN project TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+)`,
+	`CREATE TABLE session_tags (
+    session_id TEXT NOT NULL REFERENCES sessions(id),
+    tag_id INTEGER NOT NULL REFERENCES tags(id),
+    PRIMARY KEY (session_id, tag_id)
+)`,
+	`CREATE INDEX idx_session_tags_tag ON session_tags(tag_id)`,
+	`ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX idx_sessions_listing ON sessions(pinned, datetime(created_at), id)`,
+	// No foreign key, so spending outlives deleted sessions
+	`CREATE TABLE usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+    estimated INTEGER NOT NULL DEFAULT 0
+)`,
+	`CREATE INDEX idx_usage_created ON usage(datetime(created_at))`,
+	// Like usage, kept as an audit trail when the session is deleted
+	`CREATE TABLE budget_decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    estimated_cost REAL NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL,
+    decision TEXT NOT NULL
+)`,
+	`CREATE INDEX idx_budget_decisions_session ON budget_decisions(session_id)`,
+	// No foreign key: the file outlives a deleted session, and undoing the
+	// deletion finds it again
+	`CREATE TABLE exports (
+    session_id TEXT NOT NULL,
+    format TEXT NOT NULL,
+    path TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    exported_at TEXT NOT NULL,
+    PRIMARY KEY (session_id, format)
+)`,
+	// No foreign key either: deleting an imported session shouldn't bring
+	// it back on the next import
+	`CREATE TABLE imports (
+    source TEXT NOT NULL,
+    source_id TEXT NOT NULL,
+    session_id TEXT NOT NULL,
+    imported_at TEXT NOT NULL,
+    PRIMARY KEY (source, source_id)
+)`,
+	`ALTER TABLE exports ADD COLUMN messages_hash TEXT NOT NULL DEFAULT ''`,
+}
+
 // Open opens (or creates) the SQLite database at path, enables WAL mode, runs migrations.
 // Auto-creates parent directories.
 func Open(path string) (*DB, error) {
@@ -77,10 +147,42 @@ func Open(path string) (*DB, error) {
 		sqlDB.Close()
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
+	if err := migrate(sqlDB); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 
 	return &DB{db: sqlDB}, nil
 }
 
+// migrate applies the migrations that haven't run yet, each in its own transaction.
+func migrate(sqlDB *sql.DB) error {
+	var version int
+	if err := sqlDB.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := sqlDB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
 // Close closes the database connection.
 func (d *DB) Close() error {
 	return d.db.Close()
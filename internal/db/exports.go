@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetExport returns where a session was last exported in format, or nil if
+// it never was.
+func (d *DB) GetExport(sessionID, format string) (*Export, error) {
+	var e Export
+	var exportedAt string
+	err := d.db.QueryRow(`
+		SELECT session_id, format, path, hash, message_count, messages_hash, exported_at
+		FROM exports WHERE session_id = ? AND format = ?
+	`, sessionID, format).Scan(&e.SessionID, &e.Format, &e.Path, &e.Hash, &e.MessageCount, &e.MessagesHash, &exportedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export: %w", err)
+	}
+	e.ExportedAt, err = time.Parse(time.RFC3339, exportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse exported_at: %w", err)
+	}
+	return &e, nil
+}
+
+// SaveExport records an export, replacing the earlier record for the same
+// session and format.
+func (d *DB) SaveExport(e *Export) error {
+	_, err := d.db.Exec(`
+		INSERT INTO exports (session_id, format, path, hash, message_count, messages_hash, exported_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id, format) DO UPDATE SET
+			path = excluded.path,
+			hash = excluded.hash,
+			message_count = excluded.message_count,
+			messages_hash = excluded.messages_hash,
+			exported_at = excluded.exported_at
+	`,
+		e.SessionID,
+		e.Format,
+		e.Path,
+		e.Hash,
+		e.MessageCount,
+		e.MessagesHash,
+		e.ExportedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveAndGetExport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	got, err := db.GetExport("s1", "md")
+	if err != nil {
+		t.Fatalf("GetExport failed: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected no export yet, got %+v", got)
+	}
+
+	exportedAt := time.Date(2026, 9, 14, 9, 0, 0, 0, time.UTC)
+	e := &Export{SessionID: "s1", Format: "md", Path: "/notes/a.md", Hash: "abc", MessageCount: 2, ExportedAt: exportedAt}
+	if err := db.SaveExport(e); err != nil {
+		t.Fatalf("SaveExport failed: %v", err)
+	}
+	// Another format is tracked separately
+	if err := db.SaveExport(&Export{SessionID: "s1", Format: "json", Path: "/notes/a.json", Hash: "def", ExportedAt: exportedAt}); err != nil {
+		t.Fatalf("SaveExport failed: %v", err)
+	}
+
+	// Saving again replaces the record
+	e.Path = "/notes/b.md"
+	e.Hash = "ghi"
+	e.MessageCount = 4
+	e.MessagesHash = "jkl"
+	e.ExportedAt = exportedAt.Add(time.Hour)
+	if err := db.SaveExport(e); err != nil {
+		t.Fatalf("SaveExport failed: %v", err)
+	}
+
+	got, err = db.GetExport("s1", "md")
+	if err != nil {
+		t.Fatalf("GetExport failed: %v", err)
+	}
+	if got == nil || *got != *e {
+		t.Errorf("GetExport = %+v, want %+v", got, e)
+	}
+	other, err := db.GetExport("s1", "json")
+	if err != nil || other == nil || other.Path != "/notes/a.json" {
+		t.Errorf("GetExport(json) = %+v, %v; want the JSON export", other, err)
+	}
+}
@@ -0,0 +1,316 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortKey orders session listings.
+type SortKey string
+
+const (
+	SortCreated  SortKey = "created"
+	SortUpdated  SortKey = "updated"
+	SortMessages SortKey = "messages"
+	SortTokens   SortKey = "tokens"
+)
+
+// messageCountColumn and tokenCountColumn total a session's messages.
+const (
+	messageCountColumn = "(SELECT COUNT(*) FROM messages WHERE messages.session_id = sessions.id)"
+	tokenCountColumn   = "(SELECT COALESCE(SUM(tokens), 0) FROM messages WHERE messages.session_id = sessions.id)"
+)
+
+// sortColumns maps sort keys to the expressions they order by.
+var sortColumns = map[SortKey]string{
+	SortCreated:  "datetime(created_at)",
+	SortUpdated:  "datetime(updated_at)",
+	SortMessages: messageCountColumn,
+	SortTokens:   tokenCountColumn,
+}
+
+// SessionQuery filters and orders session listings. Zero values match
+// everything; the default order is pinned sessions, then newest first.
+type SessionQuery struct {
+	Provider        string
+	Model           string
+	Persona         string
+	Project         string
+	Tag             string    // as returned by NormalizeTag
+	Since           time.Time // created at or after
+	Until           time.Time // created before
+	MinMessages     int
+	Text            string // matched against titles and message content
+	IncludeArchived bool
+	Sort            SortKey
+	Ascending       bool
+}
+
+// ParseQuery parses a filter such as "provider:claude since:2026-09 rust".
+// Words without a key are matched against titles and message content.
+// Dates are YYYY, YYYY-MM, YYYY-MM-DD or a number of days back like 7d;
+// until: is exclusive of the whole period it names.
+func ParseQuery(s string) (SessionQuery, error) {
+	return parseQuery(s, time.Now())
+}
+
+func parseQuery(s string, now time.Time) (SessionQuery, error) {
+	var q SessionQuery
+	var text []string
+
+	for _, word := range strings.Fields(s) {
+		key, value, ok := strings.Cut(word, ":")
+		if !ok || value == "" {
+			text = append(text, word)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "provider":
+			q.Provider = value
+		case "model":
+			q.Model = value
+		case "persona":
+			q.Persona = value
+		case "since":
+			start, _, err := parseDate(value, now)
+			if err != nil {
+				return SessionQuery{}, err
+			}
+			q.Since = start
+		case "until":
+			_, end, err := parseDate(value, now)
+			if err != nil {
+				return SessionQuery{}, err
+			}
+			q.Until = end
+		case "min":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return SessionQuery{}, fmt.Errorf("min: expects a number of messages, got %q", value)
+			}
+			q.MinMessages = n
+		case "sort":
+			key := SortKey(strings.ToLower(value))
+			switch key {
+			case SortCreated, SortUpdated, SortMessages, SortTokens:
+				q.Sort = key
+			default:
+				return SessionQuery{}, fmt.Errorf("unknown sort key %q", value)
+			}
+		case "order":
+			switch strings.ToLower(value) {
+			case "asc":
+				q.Ascending = true
+			case "desc":
+				q.Ascending = false
+			default:
+				return SessionQuery{}, fmt.Errorf("order: expects asc or desc, got %q", value)
+			}
+		case "project":
+			q.Project = value
+		case "tag":
+			tag, err := NormalizeTag(value)
+			if err != nil {
+				return SessionQuery{}, err
+			}
+			q.Tag = tag
+		default:
+			text = append(text, word)
+		}
+	}
+
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// parseDate returns the start and end of the period a date names.
+func parseDate(value string, now time.Time) (time.Time, time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			y, mo, d := now.Date()
+			start := time.Date(y, mo, d, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -n)
+			return start, now, nil
+		}
+	}
+
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	}
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l.layout, value, now.Location()); err == nil {
+			return t, t.AddDate(l.years, l.months, l.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: use YYYY, YYYY-MM, YYYY-MM-DD or 7d", value)
+}
+
+// where builds the WHERE clause and its arguments for the query's filters.
+func (q SessionQuery) where() (string, []any) {
+	var conds []string
+	var args []any
+
+	if !q.IncludeArchived {
+		conds = append(conds, "archived = 0")
+	}
+	if q.Provider != "" {
+		conds = append(conds, "provider = ?")
+		args = append(args, q.Provider)
+	}
+	if q.Model != "" {
+		conds = append(conds, "model = ?")
+		args = append(args, q.Model)
+	}
+	if q.Persona != "" {
+		conds = append(conds, "persona = ?")
+		args = append(args, q.Persona)
+	}
+	if q.Project != "" {
+		conds = append(conds, "project = ?")
+		args = append(args, q.Project)
+	}
+	if q.Tag != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM session_tags
+			JOIN tags ON tags.id = session_tags.tag_id
+			WHERE session_tags.session_id = sessions.id AND tags.name = ?)`)
+		args = append(args, q.Tag)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, "datetime(created_at) >= datetime(?)")
+		args = append(args, q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		conds = append(conds, "datetime(created_at) < datetime(?)")
+		args = append(args, q.Until.Format(time.RFC3339))
+	}
+	if q.MinMessages > 0 {
+		conds = append(conds, messageCountColumn+" >= ?")
+		args = append(args, q.MinMessages)
+	}
+	if q.Text != "" {
+		pattern := "%" + escapeLike(q.Text) + "%"
+		conds = append(conds, `(title LIKE ? ESCAPE '\' OR EXISTS (
+			SELECT 1 FROM messages WHERE messages.session_id = sessions.id AND content LIKE ? ESCAPE '\'))`)
+		args = append(args, pattern, pattern)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// sortKey returns the query's sort key, checking that it is known.
+func (q SessionQuery) sortKey() (SortKey, error) {
+	key := q.Sort
+	if key == "" {
+		key = SortCreated
+	}
+	if _, ok := sortColumns[key]; !ok {
+		return "", fmt.Errorf("unknown sort key: %s", key)
+	}
+	return key, nil
+}
+
+// orderBy builds the ORDER BY clause. Pinned sessions come first whatever the
+// sort, and ties are broken by ID so paging is stable.
+func (q SessionQuery) orderBy(key SortKey) string {
+	dir := "DESC"
+	if q.Ascending {
+		dir = "ASC"
+	}
+	return fmt.Sprintf(" ORDER BY pinned DESC, %s %s, id %s", sortColumns[key], dir, dir)
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// QuerySessions returns all the sessions matching q, with their message and
+// token totals.
+func (d *DB) QuerySessions(q SessionQuery) ([]Session, error) {
+	sessions, _, err := d.querySessions(q, nil, 0)
+	return sessions, err
+}
+
+// querySessions returns the sessions matching q that sort after the cursor,
+// at most limit of them when limit > 0. It also returns the sort value of
+// each session for building the next cursor.
+func (d *DB) querySessions(q SessionQuery, after *pageCursor, limit int) ([]Session, []string, error) {
+	key, err := q.sortKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	where, args := q.where()
+	if after != nil {
+		cond, condArgs, err := after.condition(q, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+		args = append(args, condArgs...)
+	}
+
+	query := "SELECT " + sessionColumns + ", " + messageCountColumn + ", " + tokenCountColumn + ", " +
+		sortColumns[key] + " FROM sessions"
+	if limit > 0 {
+		// Pick the page's IDs first so the per-session subqueries only run
+		// for the sessions returned rather than for every row sorted
+		query += " WHERE id IN (SELECT id FROM sessions" + where + q.orderBy(key) + " LIMIT ?)" + q.orderBy(key)
+		args = append(args, limit)
+	} else {
+		query += where + q.orderBy(key)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	var values []string
+	for rows.Next() {
+		var messages, tokens int
+		var value string
+		s, err := scanSession(rows, &messages, &tokens, &value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		s.MessageCount = messages
+		s.TokenCount = tokens
+		sessions = append(sessions, s)
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, values, nil
+}
+
+// CountSessions returns how many sessions match q.
+func (d *DB) CountSessions(q SessionQuery) (int, error) {
+	where, args := q.where()
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM sessions"+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+
+	q, err := parseQuery("provider:claude model:sonnet persona:coder project:ai-tui tag:#Work min:3 sort:tokens order:asc rust lifetimes", now)
+	if err != nil {
+		t.Fatalf("parseQuery failed: %v", err)
+	}
+	want := SessionQuery{
+		Provider:    "claude",
+		Model:       "sonnet",
+		Persona:     "coder",
+		Project:     "ai-tui",
+		Tag:         "work",
+		MinMessages: 3,
+		Sort:        SortTokens,
+		Ascending:   true,
+		Text:        "rust lifetimes",
+	}
+	if q != want {
+		t.Errorf("expected %+v, got %+v", want, q)
+	}
+}
+
+func TestParseQueryDates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		input string
+		since time.Time
+		until time.Time
+	}{
+		{"since:2026-09", day(2026, 9, 1), time.Time{}},
+		{"until:2026-09", time.Time{}, day(2026, 10, 1)},
+		{"since:2025 until:2025", day(2025, 1, 1), day(2026, 1, 1)},
+		{"since:2026-09-30 until:2026-09-30", day(2026, 9, 30), day(2026, 10, 1)},
+		{"since:7d", day(2026, 10, 11), time.Time{}},
+	}
+	for _, tt := range tests {
+		q, err := parseQuery(tt.input, now)
+		if err != nil {
+			t.Fatalf("parseQuery(%q) failed: %v", tt.input, err)
+		}
+		if !q.Since.Equal(tt.since) || !q.Until.Equal(tt.until) {
+			t.Errorf("parseQuery(%q): expected %v..%v, got %v..%v", tt.input, tt.since, tt.until, q.Since, q.Until)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, input := range []string{"since:yesterday", "min:many", "sort:size", "order:up"} {
+		if _, err := ParseQuery(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestParseQueryKeepsUnknownKeysAsText(t *testing.T) {
+	q, err := ParseQuery("http://example.com note:")
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if q.Text != "http://example.com note:" {
+		t.Errorf("expected the words as text, got %q", q.Text)
+	}
+}
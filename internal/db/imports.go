@@ -0,0 +1,50 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ImportedSession returns the ID of the session a conversation from source
+// was imported as, or "" if it never was.
+func (d *DB) ImportedSession(source, sourceID string) (string, error) {
+	var sessionID string
+	err := d.db.QueryRow(
+		"SELECT session_id FROM imports WHERE source = ? AND source_id = ?",
+		source, sourceID,
+	).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get import: %w", err)
+	}
+	return sessionID, nil
+}
+
+// ImportSession inserts a session and its messages, and records that it was
+// imported from the conversation sourceID of source, in one transaction.
+func (d *DB) ImportSession(source, sourceID string, s *Session, messages []Message) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertSessionWithMessages(tx, s, messages); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO imports (source, source_id, session_id, imported_at)
+		VALUES (?, ?, ?, ?)
+	`, source, sourceID, s.ID, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record import: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
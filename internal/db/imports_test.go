@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImportSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.ImportedSession("chatgpt", "conv-1")
+	if err != nil {
+		t.Fatalf("ImportedSession failed: %v", err)
+	}
+	if id != "" {
+		t.Fatalf("expected no import yet, got %q", id)
+	}
+
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	s := &Session{ID: "s1", Title: "Imported", Provider: "openai", Model: "gpt-4o", CreatedAt: now, UpdatedAt: now}
+	msgs := []Message{
+		{Role: "user", Content: "Hi", CreatedAt: now},
+		{Role: "assistant", Content: "Hello", CreatedAt: now},
+	}
+	if err := db.ImportSession("chatgpt", "conv-1", s, msgs); err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+
+	id, err = db.ImportedSession("chatgpt", "conv-1")
+	if err != nil || id != "s1" {
+		t.Errorf("ImportedSession = %q, %v; want s1", id, err)
+	}
+	// The same ID from another source is a different conversation
+	if id, _ := db.ImportedSession("claude", "conv-1"); id != "" {
+		t.Errorf("ImportedSession(claude) = %q, want none", id)
+	}
+	got, err := db.GetSessionMessages("s1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("GetSessionMessages = %d messages, %v; want 2", len(got), err)
+	}
+
+	// A failed insert doesn't record the import
+	dup := &Session{ID: "s1", CreatedAt: now, UpdatedAt: now}
+	if err := db.ImportSession("chatgpt", "conv-2", dup, nil); err == nil {
+		t.Fatal("expected an error importing a duplicate session ID")
+	}
+	if id, _ := db.ImportedSession("chatgpt", "conv-2"); id != "" {
+		t.Errorf("ImportedSession(conv-2) = %q after a failed import, want none", id)
+	}
+
+	// The record outlives the session, so a deleted session stays deleted
+	if err := db.DeleteSession("s1"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if id, _ := db.ImportedSession("chatgpt", "conv-1"); id != "s1" {
+		t.Errorf("ImportedSession after delete = %q, want s1", id)
+	}
+}
@@ -10,13 +10,85 @@ type Session struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Archived  bool
+	ParentID  string // session this one was forked from, "" if none
+	Persona   string // persona the session was started with, "" for none
+	Project   string // project the session is filed under, "" for none
+	Pinned    bool   // listed before other sessions whatever the sort
+	Tags      []string
+
+	// Totals over the session's messages, filled in by QuerySessions
+	MessageCount int
+	TokenCount   int
 }
 
 type Message struct {
-	ID        int64
-	SessionID string
-	Role      string // "user", "assistant", "system"
-	Content   string
-	CreatedAt time.Time
-	Tokens    int
+	ID         int64
+	SessionID  string
+	Role       string // "user", "assistant", "system"
+	Content    string
+	CreatedAt  time.Time
+	Tokens     int
+	Pinned     bool // always sent to the model, e.g. a summary of older turns
+	Summarized bool // replaced by a pinned summary and no longer sent
+}
+
+// Usage is the token accounting for one request to a provider.
+type Usage struct {
+	ID               int64
+	SessionID        string
+	Provider         string
+	Model            string
+	CreatedAt        time.Time
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Estimated        bool // counted locally because the provider reported none
+}
+
+// UsageTotal sums the usage of one model on one day.
+type UsageTotal struct {
+	Day              string // YYYY-MM-DD in the time zone the requests were made in
+	Provider         string
+	Model            string
+	Requests         int
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Estimated        int // requests whose counts are estimates
+}
+
+// Budget decisions
+const (
+	DecisionBlocked   = "blocked"   // refused by a blocking limit
+	DecisionConfirmed = "confirmed" // sent anyway after a warning
+	DecisionDeclined  = "declined"  // not sent after a warning
+)
+
+// BudgetDecision records what happened to a request that would have
+// exceeded a provider's limits.
+type BudgetDecision struct {
+	ID            int64
+	SessionID     string
+	Provider      string
+	Model         string
+	CreatedAt     time.Time
+	InputTokens   int     // estimated input of the request
+	EstimatedCost float64 // estimated cost of the request in dollars
+	Reason        string  // the limits it would exceed
+	Decision      string  // DecisionBlocked, DecisionConfirmed or DecisionDeclined
+}
+
+// Export records the file a session was last exported to in one format.
+type Export struct {
+	SessionID    string
+	Format       string // the exporter's file extension, e.g. "md"
+	Path         string
+	Hash         string // SHA-256 of the file as written, to notice later edits
+	MessageCount int    // messages in the export
+	// MessagesHash identifies the exported messages, to notice when the
+	// conversation was changed before them; "" for older records
+	MessagesHash string
+	ExportedAt   time.Time
 }
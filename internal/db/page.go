@@ -0,0 +1,138 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SessionPage is one page of a session listing.
+type SessionPage struct {
+	Sessions []Session
+	Next     string // cursor for the following page, "" on the last page
+	Total    int    // sessions matching the filter across all pages
+}
+
+// pageCursor marks the last session of a page by its position in the sort
+// order, so the next page starts after it even when rows were added since.
+type pageCursor struct {
+	Sort      SortKey `json:"s"`
+	Ascending bool    `json:"a,omitempty"`
+	Pinned    bool    `json:"p,omitempty"`
+	Value     string  `json:"v"`
+	ID        string  `json:"i"`
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page cursor: %w", err)
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid page cursor: %w", err)
+	}
+	return &c, nil
+}
+
+// condition builds the WHERE condition selecting the sessions that sort
+// after the cursor in q's order.
+func (c pageCursor) condition(q SessionQuery, key SortKey) (string, []any, error) {
+	if c.Sort != key || c.Ascending != q.Ascending {
+		return "", nil, fmt.Errorf("page cursor doesn't match the query's sort order")
+	}
+
+	var value any = c.Value
+	if key == SortMessages || key == SortTokens {
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid page cursor: %w", err)
+		}
+		value = n
+	}
+
+	column := sortColumns[key]
+	if !q.Ascending {
+		// A row value comparison lets SQLite seek the listing index
+		return fmt.Sprintf("(pinned, %s, id) < (?, ?, ?)", column), []any{c.Pinned, value, c.ID}, nil
+	}
+	// Pinned sessions still come first when ascending, so the order is mixed
+	cond := fmt.Sprintf("(pinned < ? OR (pinned = ? AND (%s > ? OR (%s = ? AND id > ?))))", column, column)
+	return cond, []any{c.Pinned, c.Pinned, value, value, c.ID}, nil
+}
+
+// ListSessionsPage returns up to limit sessions matching q, starting after
+// cursor, or from the top when cursor is empty. Pages follow q's sort order
+// and are read with keyset pagination, so each page costs the same however
+// deep it is.
+func (d *DB) ListSessionsPage(cursor string, limit int, q SessionQuery) (SessionPage, error) {
+	if limit <= 0 {
+		return SessionPage{}, fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+	key, err := q.sortKey()
+	if err != nil {
+		return SessionPage{}, err
+	}
+
+	var after *pageCursor
+	if cursor != "" {
+		if after, err = decodeCursor(cursor); err != nil {
+			return SessionPage{}, err
+		}
+	}
+
+	// Read one extra row to learn whether another page follows
+	sessions, values, err := d.querySessions(q, after, limit+1)
+	if err != nil {
+		return SessionPage{}, err
+	}
+	total, err := d.CountSessions(q)
+	if err != nil {
+		return SessionPage{}, err
+	}
+
+	page := SessionPage{Sessions: sessions, Total: total}
+	if len(sessions) > limit {
+		page.Sessions = sessions[:limit]
+		last := page.Sessions[limit-1]
+		page.Next = pageCursor{
+			Sort:      key,
+			Ascending: q.Ascending,
+			Pinned:    last.Pinned,
+			Value:     values[limit-1],
+			ID:        last.ID,
+		}.encode()
+	}
+	return page, nil
+}
+
+// eachPageSize is how many sessions EachSession reads at a time
+const eachPageSize = 100
+
+// EachSession calls fn with every session matching q, in q's order, reading
+// them a page at a time so large listings aren't held in memory. It stops at
+// the first error fn returns.
+func (d *DB) EachSession(q SessionQuery, fn func(Session) error) error {
+	cursor := ""
+	for {
+		page, err := d.ListSessionsPage(cursor, eachPageSize, q)
+		if err != nil {
+			return err
+		}
+		for _, s := range page.Sessions {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		if page.Next == "" {
+			return nil
+		}
+		cursor = page.Next
+	}
+}
@@ -6,10 +6,63 @@ import (
 	"time"
 )
 
+// sessionColumns lists the sessions columns in the order scanSession reads them,
+// ending with the session's tags joined by commas.
+const sessionColumns = "id, title, provider, model, created_at, updated_at, archived, parent_id, persona, project, pinned, " + tagsColumn
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession reads a session row selected with sessionColumns, followed by
+// any extra columns into extra.
+func scanSession(row rowScanner, extra ...any) (Session, error) {
+	var s Session
+	var createdAt, updatedAt string
+	var archived, pinned int
+	var tags sql.NullString
+
+	dest := []any{
+		&s.ID,
+		&s.Title,
+		&s.Provider,
+		&s.Model,
+		&createdAt,
+		&updatedAt,
+		&archived,
+		&s.ParentID,
+		&s.Persona,
+		&s.Project,
+		&pinned,
+		&tags,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return s, err
+	}
+
+	var err error
+	s.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+
+	s.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+
+	s.Archived = archived != 0
+	s.Pinned = pinned != 0
+	s.Tags = splitTags(tags.String)
+
+	return s, nil
+}
+
 func (d *DB) CreateSession(s *Session) error {
 	query := `
-		INSERT INTO sessions (id, title, provider, model, created_at, updated_at, archived)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO sessions (id, title, provider, model, created_at, updated_at, archived, parent_id, persona, project, pinned)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := d.db.Exec(query,
 		s.ID,
@@ -19,6 +72,10 @@ func (d *DB) CreateSession(s *Session) error {
 		s.CreatedAt.Format(time.RFC3339),
 		s.UpdatedAt.Format(time.RFC3339),
 		s.Archived,
+		s.ParentID,
+		s.Persona,
+		s.Project,
+		s.Pinned,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -27,24 +84,9 @@ func (d *DB) CreateSession(s *Session) error {
 }
 
 func (d *DB) GetSession(id string) (*Session, error) {
-	query := `
-		SELECT id, title, provider, model, created_at, updated_at, archived
-		FROM sessions
-		WHERE id = ?
-	`
-	var s Session
-	var createdAt, updatedAt string
-	var archived int
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id = ?"
 
-	err := d.db.QueryRow(query, id).Scan(
-		&s.ID,
-		&s.Title,
-		&s.Provider,
-		&s.Model,
-		&createdAt,
-		&updatedAt,
-		&archived,
-	)
+	s, err := scanSession(d.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("session not found: %s", id)
@@ -52,72 +94,34 @@ func (d *DB) GetSession(id string) (*Session, error) {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	s.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse created_at: %w", err)
-	}
-
-	s.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-	}
-
-	s.Archived = archived != 0
-
 	return &s, nil
 }
 
 func (d *DB) ListSessions(includeArchived bool) ([]Session, error) {
-	query := `
-		SELECT id, title, provider, model, created_at, updated_at, archived
-		FROM sessions
-	`
-	if !includeArchived {
-		query += " WHERE archived = 0"
-	}
-	query += " ORDER BY created_at DESC"
+	return d.QuerySessions(SessionQuery{IncludeArchived: includeArchived})
+}
 
-	rows, err := d.db.Query(query)
+// ListForks returns the sessions forked from parentID, oldest first.
+func (d *DB) ListForks(parentID string) ([]Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE parent_id = ? ORDER BY created_at ASC"
+
+	rows, err := d.db.Query(query, parentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list sessions: %w", err)
+		return nil, fmt.Errorf("failed to list forks: %w", err)
 	}
 	defer rows.Close()
 
 	var sessions []Session
 	for rows.Next() {
-		var s Session
-		var createdAt, updatedAt string
-		var archived int
-
-		if err := rows.Scan(
-			&s.ID,
-			&s.Title,
-			&s.Provider,
-			&s.Model,
-			&createdAt,
-			&updatedAt,
-			&archived,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan session: %w", err)
-		}
-
-		s.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse created_at: %w", err)
-		}
-
-		s.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt)
+		s, err := scanSession(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+			return nil, fmt.Errorf("failed to scan session: %w", err)
 		}
-
-		s.Archived = archived != 0
-
 		sessions = append(sessions, s)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating sessions: %w", err)
+		return nil, fmt.Errorf("error iterating forks: %w", err)
 	}
 
 	return sessions, nil
@@ -145,6 +149,26 @@ func (d *DB) UpdateSessionTitle(id, title string) error {
 	return nil
 }
 
+// ReplaceSessionTitle sets a session's title only if it is still current,
+// reporting whether it did. A title someone set in the meantime is kept.
+func (d *DB) ReplaceSessionTitle(id, current, title string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET title = ?, updated_at = ?
+		WHERE id = ? AND title = ?
+	`
+	result, err := d.db.Exec(query, title, time.Now().Format(time.RFC3339), id, current)
+	if err != nil {
+		return false, fmt.Errorf("failed to update session title: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
 func (d *DB) ArchiveSession(id string) error {
 	query := `
 		UPDATE sessions
@@ -167,6 +191,24 @@ func (d *DB) ArchiveSession(id string) error {
 	return nil
 }
 
+// SetSessionPinned pins a session to the top of listings, or unpins it.
+func (d *DB) SetSessionPinned(id string, pinned bool) error {
+	result, err := d.db.Exec("UPDATE sessions SET pinned = ? WHERE id = ?", pinned, id)
+	if err != nil {
+		return fmt.Errorf("failed to pin session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	return nil
+}
+
 func (d *DB) UnarchiveSession(id string) error {
 	query := `
 		UPDATE sessions
@@ -191,8 +233,8 @@ func (d *DB) UnarchiveSession(id string) error {
 
 func (d *DB) AddMessage(m *Message) error {
 	query := `
-		INSERT INTO messages (session_id, role, content, created_at, tokens)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO messages (session_id, role, content, created_at, tokens, pinned, summarized)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := d.db.Exec(query,
 		m.SessionID,
@@ -200,6 +242,8 @@ func (d *DB) AddMessage(m *Message) error {
 		m.Content,
 		m.CreatedAt.Format(time.RFC3339),
 		m.Tokens,
+		m.Pinned,
+		m.Summarized,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to add message: %w", err)
@@ -210,16 +254,22 @@ func (d *DB) AddMessage(m *Message) error {
 		return fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
+	// A new message counts as activity on the session
+	if _, err := d.db.Exec("UPDATE sessions SET updated_at = ? WHERE id = ? AND updated_at < ?",
+		m.CreatedAt.Format(time.RFC3339), m.SessionID, m.CreatedAt.Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
 	m.ID = id
 	return nil
 }
 
 func (d *DB) GetSessionMessages(sessionID string) ([]Message, error) {
 	query := `
-		SELECT id, session_id, role, content, created_at, tokens
+		SELECT id, session_id, role, content, created_at, tokens, pinned, summarized
 		FROM messages
 		WHERE session_id = ?
-		ORDER BY created_at ASC
+		ORDER BY created_at ASC, id ASC
 	`
 	rows, err := d.db.Query(query, sessionID)
 	if err != nil {
@@ -239,6 +289,8 @@ func (d *DB) GetSessionMessages(sessionID string) ([]Message, error) {
 			&m.Content,
 			&createdAt,
 			&m.Tokens,
+			&m.Pinned,
+			&m.Summarized,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
@@ -258,12 +310,117 @@ func (d *DB) GetSessionMessages(sessionID string) ([]Message, error) {
 	return messages, nil
 }
 
+// CreateSessionWithMessages inserts a session and its messages in one
+// transaction, setting each message's ID and SessionID.
+func (d *DB) CreateSessionWithMessages(s *Session, messages []Message) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertSessionWithMessages(tx, s, messages); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session: %w", err)
+	}
+	return nil
+}
+
+// insertSessionWithMessages inserts a session, its tags and its messages in
+// tx, setting each message's ID and SessionID.
+func insertSessionWithMessages(tx *sql.Tx, s *Session, messages []Message) error {
+	_, err := tx.Exec(`
+		INSERT INTO sessions (id, title, provider, model, created_at, updated_at, archived, parent_id, persona, project, pinned)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		s.ID,
+		s.Title,
+		s.Provider,
+		s.Model,
+		s.CreatedAt.Format(time.RFC3339),
+		s.UpdatedAt.Format(time.RFC3339),
+		s.Archived,
+		s.ParentID,
+		s.Persona,
+		s.Project,
+		s.Pinned,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	for _, tag := range s.Tags {
+		if err := addTag(tx, s.ID, tag); err != nil {
+			return err
+		}
+	}
+
+	for i := range messages {
+		m := &messages[i]
+		m.SessionID = s.ID
+		result, err := tx.Exec(`
+			INSERT INTO messages (session_id, role, content, created_at, tokens, pinned, summarized)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, m.SessionID, m.Role, m.Content, m.CreatedAt.Format(time.RFC3339), m.Tokens, m.Pinned, m.Summarized)
+		if err != nil {
+			return fmt.Errorf("failed to add message: %w", err)
+		}
+		if m.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+	}
+	return nil
+}
+
+// MarkSummarized flags messages as replaced by a summary, so they are kept
+// for display but no longer sent to the model.
+func (d *DB) MarkSummarized(ids []int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE messages SET summarized = 1 WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to mark message summarized: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit summarized messages: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) DeleteMessage(id int64) error {
+	result, err := d.db.Exec("DELETE FROM messages WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("message not found: %d", id)
+	}
+
+	return nil
+}
+
 func (d *DB) DeleteSession(id string) error {
-	// Delete messages first (foreign key constraint)
+	// Delete messages and tags first (foreign key constraint)
 	_, err := d.db.Exec("DELETE FROM messages WHERE session_id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete session messages: %w", err)
 	}
+	if _, err := d.db.Exec("DELETE FROM session_tags WHERE session_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete session tags: %w", err)
+	}
 
 	// Delete session
 	result, err := d.db.Exec("DELETE FROM sessions WHERE id = ?", id)
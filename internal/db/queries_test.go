@@ -1,6 +1,9 @@
 package db
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -255,6 +258,31 @@ func TestUpdateSessionTitle(t *testing.T) {
 	}
 }
 
+func TestReplaceSessionTitle(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	session := &Session{ID: "test-session", Title: "hello there", Provider: "openai", CreatedAt: now, UpdatedAt: now}
+	if err := db.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	replaced, err := db.ReplaceSessionTitle(session.ID, "stale title", "Generated")
+	if err != nil || replaced {
+		t.Errorf("ReplaceSessionTitle() = %v, %v; want false for a title that changed", replaced, err)
+	}
+	replaced, err = db.ReplaceSessionTitle(session.ID, "hello there", "Generated")
+	if err != nil || !replaced {
+		t.Errorf("ReplaceSessionTitle() = %v, %v; want true for the current title", replaced, err)
+	}
+
+	retrieved, err := db.GetSession(session.ID)
+	if err != nil || retrieved.Title != "Generated" {
+		t.Errorf("expected the generated title stored, got %+v (err %v)", retrieved, err)
+	}
+}
+
 func TestArchiveAndUnarchiveSession(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -422,6 +450,86 @@ func TestDeleteSessionRemovesMessages(t *testing.T) {
 	}
 }
 
+func TestDeleteMessage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	session := &Session{
+		ID:        "test-session",
+		Title:     "Test Session",
+		Provider:  "openai",
+		Model:     "gpt-4",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := db.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	first := &Message{SessionID: session.ID, Role: "user", Content: "Hello!", CreatedAt: now}
+	second := &Message{SessionID: session.ID, Role: "assistant", Content: "Hi!", CreatedAt: now}
+	for _, m := range []*Message{first, second} {
+		if err := db.AddMessage(m); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	if err := db.DeleteMessage(second.ID); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+
+	messages, err := db.GetSessionMessages(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != first.ID {
+		t.Errorf("expected only the first message to remain, got %+v", messages)
+	}
+
+	if err := db.DeleteMessage(second.ID); err == nil {
+		t.Error("expected error when deleting a missing message")
+	}
+}
+
+func TestMarkSummarizedAndPinned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	session := &Session{ID: "test-session", Provider: "openai", Model: "gpt-4", CreatedAt: now, UpdatedAt: now}
+	if err := db.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	old := &Message{SessionID: session.ID, Role: "user", Content: "Hello!", CreatedAt: now}
+	summary := &Message{SessionID: session.ID, Role: "system", Content: "Greetings", CreatedAt: now, Pinned: true}
+	for _, m := range []*Message{old, summary} {
+		if err := db.AddMessage(m); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	if err := db.MarkSummarized([]int64{old.ID}); err != nil {
+		t.Fatalf("failed to mark summarized: %v", err)
+	}
+
+	messages, err := db.GetSessionMessages(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session messages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if !messages[0].Summarized || messages[0].Pinned {
+		t.Errorf("expected first message summarized, got %+v", messages[0])
+	}
+	if messages[1].Summarized || !messages[1].Pinned {
+		t.Errorf("expected summary pinned, got %+v", messages[1])
+	}
+}
+
 func TestMultipleMessagesPerSession(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -491,3 +599,442 @@ func TestMultipleMessagesPerSession(t *testing.T) {
 		t.Errorf("expected third message to be 'Third message', got %s", retrieved[2].Content)
 	}
 }
+
+func TestCreateSessionWithMessagesAndListForks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	parent := &Session{ID: "parent", Title: "Parent", Provider: "openai", Model: "gpt-4", CreatedAt: now, UpdatedAt: now}
+	if err := db.CreateSession(parent); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	fork := &Session{
+		ID:        "fork",
+		Title:     "Parent (fork)",
+		Provider:  "openai",
+		Model:     "gpt-4",
+		CreatedAt: now,
+		UpdatedAt: now,
+		ParentID:  parent.ID,
+	}
+	messages := []Message{
+		{Role: "user", Content: "Hello!", CreatedAt: now},
+		{Role: "assistant", Content: "Hi!", CreatedAt: now},
+	}
+	if err := db.CreateSessionWithMessages(fork, messages); err != nil {
+		t.Fatalf("failed to create fork: %v", err)
+	}
+	if messages[0].ID == 0 || messages[1].ID == 0 || messages[0].SessionID != "fork" {
+		t.Errorf("expected message IDs and session ID to be set, got %+v", messages)
+	}
+
+	stored, err := db.GetSessionMessages(fork.ID)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(stored) != 2 || stored[1].Content != "Hi!" {
+		t.Errorf("expected both messages stored in order, got %+v", stored)
+	}
+
+	forks, err := db.ListForks(parent.ID)
+	if err != nil {
+		t.Fatalf("failed to list forks: %v", err)
+	}
+	if len(forks) != 1 || forks[0].ID != "fork" || forks[0].ParentID != parent.ID {
+		t.Errorf("expected the fork to be listed, got %+v", forks)
+	}
+}
+
+func TestOpenRecordsSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Opening twice must not re-run migrations
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() #%d error: %v", i+1, err)
+		}
+
+		var version int
+		if err := db.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			t.Fatalf("failed to read user_version: %v", err)
+		}
+		if version != len(migrations) {
+			t.Errorf("user_version = %d, want %d", version, len(migrations))
+		}
+		db.Close()
+	}
+}
+
+func TestAddMessageUpdatesSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	created := time.Now().Add(-time.Hour).Round(time.Second)
+	session := &Session{ID: "test-session", Provider: "openai", Model: "gpt-4", CreatedAt: created, UpdatedAt: created}
+	if err := db.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	now := time.Now().Round(time.Second)
+	if err := db.AddMessage(&Message{SessionID: session.ID, Role: "user", Content: "Hello!", CreatedAt: now}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+
+	got, err := db.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if !got.UpdatedAt.Equal(now) {
+		t.Errorf("UpdatedAt = %v, want %v", got.UpdatedAt, now)
+	}
+}
+
+func TestQuerySessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	base := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{ID: "a", Title: "Rust lifetimes", Provider: "claude", Model: "sonnet", CreatedAt: base, UpdatedAt: base},
+		{ID: "b", Title: "Go generics", Provider: "openai", Model: "gpt-4o", CreatedAt: base.AddDate(0, 1, 0), UpdatedAt: base.AddDate(0, 1, 0)},
+		{ID: "c", Title: "Dinner ideas", Provider: "claude", Model: "haiku", Persona: "chef", CreatedAt: base.AddDate(0, 0, 1), UpdatedAt: base.AddDate(0, 2, 0)},
+	}
+	for i := range sessions {
+		if err := db.CreateSession(&sessions[i]); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	for i, content := range []string{"what is 'a", "borrow checker", "and 100% more"} {
+		msg := &Message{SessionID: "a", Role: "user", Content: content, Tokens: 10 * (i + 1), CreatedAt: base}
+		if err := db.AddMessage(msg); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+	if err := db.AddMessage(&Message{SessionID: "b", Role: "user", Content: "type sets", Tokens: 100, CreatedAt: base}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	if err := db.ArchiveSession("b"); err != nil {
+		t.Fatalf("failed to archive session: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query SessionQuery
+		want  []string
+	}{
+		{"default excludes archived", SessionQuery{}, []string{"c", "a"}},
+		{"include archived", SessionQuery{IncludeArchived: true}, []string{"b", "c", "a"}},
+		{"provider", SessionQuery{Provider: "claude"}, []string{"c", "a"}},
+		{"model", SessionQuery{Model: "haiku"}, []string{"c"}},
+		{"persona", SessionQuery{Persona: "chef"}, []string{"c"}},
+		{"since", SessionQuery{Since: base.Add(time.Hour), IncludeArchived: true}, []string{"b", "c"}},
+		{"until is exclusive", SessionQuery{Until: base.AddDate(0, 0, 1)}, []string{"a"}},
+		{"min messages", SessionQuery{MinMessages: 1, IncludeArchived: true}, []string{"b", "a"}},
+		{"text in title", SessionQuery{Text: "dinner"}, []string{"c"}},
+		{"text in content", SessionQuery{Text: "borrow", IncludeArchived: true}, []string{"a"}},
+		{"text escapes wildcards", SessionQuery{Text: "100%"}, []string{"a"}},
+		{"sort by updated", SessionQuery{Sort: SortUpdated, IncludeArchived: true}, []string{"c", "b", "a"}},
+		{"sort by messages", SessionQuery{Sort: SortMessages, IncludeArchived: true}, []string{"a", "b", "c"}},
+		{"sort by tokens", SessionQuery{Sort: SortTokens, IncludeArchived: true}, []string{"b", "a", "c"}},
+		{"ascending", SessionQuery{Ascending: true}, []string{"a", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.QuerySessions(tt.query)
+			if err != nil {
+				t.Fatalf("QuerySessions failed: %v", err)
+			}
+			var ids []string
+			for _, s := range got {
+				ids = append(ids, s.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, ids)
+			}
+		})
+	}
+
+	got, err := db.QuerySessions(SessionQuery{Provider: "claude", Model: "sonnet"})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if got[0].MessageCount != 3 || got[0].TokenCount != 60 {
+		t.Errorf("expected 3 messages and 60 tokens, got %d and %d", got[0].MessageCount, got[0].TokenCount)
+	}
+
+	if _, err := db.QuerySessions(SessionQuery{Sort: "size"}); err == nil {
+		t.Error("expected an error for an unknown sort key")
+	}
+}
+
+func TestPinnedSessionsListedFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	base := time.Now().Round(time.Second)
+	for i, id := range []string{"old", "mid", "new"} {
+		created := base.Add(time.Duration(i) * time.Hour)
+		if err := db.CreateSession(&Session{ID: id, Provider: "claude", CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	if err := db.SetSessionPinned("old", true); err != nil {
+		t.Fatalf("SetSessionPinned failed: %v", err)
+	}
+	if err := db.SetSessionPinned("missing", true); err == nil {
+		t.Error("expected an error for a missing session")
+	}
+
+	for _, q := range []SessionQuery{{}, {Ascending: true}, {Sort: SortUpdated}} {
+		sessions, err := db.QuerySessions(q)
+		if err != nil {
+			t.Fatalf("QuerySessions failed: %v", err)
+		}
+		if sessions[0].ID != "old" || !sessions[0].Pinned {
+			t.Errorf("expected the pinned session first for %+v, got %s", q, sessions[0].ID)
+		}
+	}
+
+	if err := db.SetSessionPinned("old", false); err != nil {
+		t.Fatalf("SetSessionPinned failed: %v", err)
+	}
+	s, err := db.GetSession("old")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if s.Pinned {
+		t.Error("session should be unpinned")
+	}
+}
+
+func TestListSessionsPage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	base := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		// Pairs of sessions share a timestamp so ties are broken by ID
+		created := base.Add(time.Duration(i/2) * time.Hour)
+		s := &Session{ID: fmt.Sprintf("s%02d", i), Provider: "claude", CreatedAt: created, UpdatedAt: created, Pinned: i == 3}
+		if err := db.CreateSession(s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		for j := 0; j < i%4; j++ {
+			if err := db.AddMessage(&Message{SessionID: s.ID, Role: "user", Content: "hi", Tokens: i, CreatedAt: created}); err != nil {
+				t.Fatalf("failed to add message: %v", err)
+			}
+		}
+	}
+
+	queries := []SessionQuery{
+		{},
+		{Ascending: true},
+		{Sort: SortMessages},
+		{Sort: SortTokens, Ascending: true},
+		{Sort: SortUpdated, MinMessages: 1},
+	}
+	for _, q := range queries {
+		want, err := db.QuerySessions(q)
+		if err != nil {
+			t.Fatalf("QuerySessions failed: %v", err)
+		}
+
+		var got []string
+		cursor := ""
+		pages := 0
+		for {
+			page, err := db.ListSessionsPage(cursor, 7, q)
+			if err != nil {
+				t.Fatalf("ListSessionsPage(%+v) failed: %v", q, err)
+			}
+			if page.Total != len(want) {
+				t.Errorf("expected total %d, got %d", len(want), page.Total)
+			}
+			for _, s := range page.Sessions {
+				got = append(got, s.ID)
+			}
+			pages++
+			if page.Next == "" {
+				break
+			}
+			cursor = page.Next
+		}
+
+		var wantIDs []string
+		for _, s := range want {
+			wantIDs = append(wantIDs, s.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(wantIDs, ",") {
+			t.Errorf("pages for %+v:\nexpected %v\n     got %v", q, wantIDs, got)
+		}
+		if wantPages := (len(want) + 6) / 7; pages != wantPages {
+			t.Errorf("expected %d pages for %+v, got %d", wantPages, q, pages)
+		}
+	}
+}
+
+func TestEachSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	base := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	n := eachPageSize*2 + 5
+	for i := 0; i < n; i++ {
+		created := base.Add(time.Duration(i) * time.Minute)
+		s := &Session{ID: fmt.Sprintf("s%03d", i), Provider: "claude", CreatedAt: created, UpdatedAt: created, Archived: i%10 == 0}
+		if err := db.CreateSession(s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+
+	var ids []string
+	err := db.EachSession(SessionQuery{IncludeArchived: true, Ascending: true}, func(s Session) error {
+		ids = append(ids, s.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachSession failed: %v", err)
+	}
+	if len(ids) != n || ids[0] != "s000" || ids[n-1] != fmt.Sprintf("s%03d", n-1) {
+		t.Errorf("EachSession visited %d sessions from %v, want all %d in order", len(ids), ids[:1], n)
+	}
+
+	// Errors from fn stop the walk
+	stop := fmt.Errorf("stop")
+	count := 0
+	err = db.EachSession(SessionQuery{}, func(s Session) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 3 {
+		t.Errorf("EachSession = %v after %d sessions, want stop after 3", err, count)
+	}
+}
+
+func TestListSessionsPageErrors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	for _, id := range []string{"a", "b"} {
+		if err := db.CreateSession(&Session{ID: id, Provider: "claude", CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	page, err := db.ListSessionsPage("", 1, SessionQuery{})
+	if err != nil {
+		t.Fatalf("ListSessionsPage failed: %v", err)
+	}
+
+	if _, err := db.ListSessionsPage(page.Next, 1, SessionQuery{Sort: SortTokens}); err == nil {
+		t.Error("expected an error for a cursor from another sort order")
+	}
+	if _, err := db.ListSessionsPage("not a cursor", 1, SessionQuery{}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+	if _, err := db.ListSessionsPage("", 0, SessionQuery{}); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
+
+// seedSessions inserts n sessions with two messages each for benchmarks, in
+// one transaction to keep setup fast.
+func seedSessions(b *testing.B, db *DB, n int) {
+	b.Helper()
+	tx, err := db.db.Begin()
+	if err != nil {
+		b.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("session-%06d", i)
+		created := base.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+		_, err := tx.Exec(`INSERT INTO sessions (id, title, provider, model, created_at, updated_at, pinned)
+			VALUES (?, ?, 'claude', 'sonnet', ?, ?, ?)`, id, fmt.Sprintf("Session %d", i), created, created, i%1000 == 0)
+		if err != nil {
+			b.Fatalf("failed to create session: %v", err)
+		}
+		for _, m := range []struct {
+			role   string
+			tokens int
+		}{{"user", 10}, {"assistant", 40 + i%7}} {
+			_, err := tx.Exec(`INSERT INTO messages (session_id, role, content, created_at, tokens)
+				VALUES (?, ?, 'text', ?, ?)`, id, m.role, created, m.tokens)
+			if err != nil {
+				b.Fatalf("failed to add message: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatalf("failed to commit: %v", err)
+	}
+}
+
+func benchmarkDB(b *testing.B) *DB {
+	b.Helper()
+	db, err := Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	seedSessions(b, db, 20000)
+	return db
+}
+
+func BenchmarkListSessionsPageFirst(b *testing.B) {
+	db := benchmarkDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ListSessionsPage("", 50, SessionQuery{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListSessionsPageDeep(b *testing.B) {
+	db := benchmarkDB(b)
+	// Walk to a page deep in the listing
+	cursor := ""
+	for i := 0; i < 200; i++ {
+		page, err := db.ListSessionsPage(cursor, 50, SessionQuery{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		cursor = page.Next
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ListSessionsPage(cursor, 50, SessionQuery{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListSessionsPageByTokens(b *testing.B) {
+	db := benchmarkDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ListSessionsPage("", 50, SessionQuery{Sort: SortTokens}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQuerySessionsAll(b *testing.B) {
+	db := benchmarkDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.QuerySessions(SessionQuery{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
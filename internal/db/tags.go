@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tagsColumn selects a session's tags joined by commas, for sessionColumns.
+const tagsColumn = `(SELECT group_concat(tags.name, ',') FROM session_tags
+	JOIN tags ON tags.id = session_tags.tag_id
+	WHERE session_tags.session_id = sessions.id)`
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// NormalizeTag trims a tag and its leading '#' and lowercases it. Tags can't
+// contain whitespace or commas.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" {
+		return "", fmt.Errorf("tag is empty")
+	}
+	if strings.ContainsAny(tag, ", \t\n") {
+		return "", fmt.Errorf("tag %q can't contain spaces or commas", tag)
+	}
+	return tag, nil
+}
+
+// splitTags parses the tagsColumn value into sorted tags.
+func splitTags(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+	tags := strings.Split(joined, ",")
+	sort.Strings(tags)
+	return tags
+}
+
+// addTag attaches tag to a session, creating the tag if needed.
+func addTag(e execer, sessionID, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	if _, err := e.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	_, err = e.Exec(`
+		INSERT OR IGNORE INTO session_tags (session_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?
+	`, sessionID, tag)
+	if err != nil {
+		return fmt.Errorf("failed to tag session: %w", err)
+	}
+	return nil
+}
+
+// TagSession attaches tag to a session.
+func (d *DB) TagSession(sessionID, tag string) error {
+	return addTag(d.db, sessionID, tag)
+}
+
+// UntagSession removes tag from a session.
+func (d *DB) UntagSession(sessionID, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`
+		DELETE FROM session_tags
+		WHERE session_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)
+	`, sessionID, tag)
+	if err != nil {
+		return fmt.Errorf("failed to untag session: %w", err)
+	}
+	return nil
+}
+
+// ListTags returns the tags in use by any session, sorted by name.
+func (d *DB) ListTags() ([]string, error) {
+	return d.listNames(`
+		SELECT name FROM tags
+		WHERE id IN (SELECT tag_id FROM session_tags)
+		ORDER BY name
+	`)
+}
+
+// SetSessionProject files a session under project, or under none when
+// project is empty.
+func (d *DB) SetSessionProject(sessionID, project string) error {
+	result, err := d.db.Exec("UPDATE sessions SET project = ? WHERE id = ?", strings.TrimSpace(project), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to set session project: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return nil
+}
+
+// ListProjects returns the projects sessions are filed under, sorted by name.
+func (d *DB) ListProjects() ([]string, error) {
+	return d.listNames("SELECT DISTINCT project FROM sessions WHERE project != '' ORDER BY project")
+}
+
+// listNames runs a query selecting a single text column.
+func (d *DB) listNames(query string) ([]string, error) {
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan name: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating names: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	for _, id := range []string{"a", "b"} {
+		if err := db.CreateSession(&Session{ID: id, Provider: "claude", CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+
+	for _, tag := range []string{"work", "#Rust", "work"} {
+		if err := db.TagSession("a", tag); err != nil {
+			t.Fatalf("TagSession failed: %v", err)
+		}
+	}
+	if err := db.TagSession("b", "home"); err != nil {
+		t.Fatalf("TagSession failed: %v", err)
+	}
+	if err := db.TagSession("a", "two words"); err == nil {
+		t.Error("expected an error for a tag with a space")
+	}
+
+	s, err := db.GetSession("a")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if !reflect.DeepEqual(s.Tags, []string{"rust", "work"}) {
+		t.Errorf("expected tags [rust work], got %v", s.Tags)
+	}
+
+	tagged, err := db.QuerySessions(SessionQuery{Tag: "work"})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != "a" {
+		t.Errorf("expected only session a tagged work, got %v", tagged)
+	}
+
+	if err := db.UntagSession("a", "work"); err != nil {
+		t.Fatalf("UntagSession failed: %v", err)
+	}
+	if err := db.DeleteSession("b"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	tags, err := db.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"rust"}) {
+		t.Errorf("expected only tags in use, got %v", tags)
+	}
+}
+
+func TestSessionProjects(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	s := &Session{ID: "a", Provider: "claude", Project: "ai-tui", Tags: []string{"go"}, CreatedAt: now, UpdatedAt: now}
+	if err := db.CreateSessionWithMessages(s, nil); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := db.CreateSession(&Session{ID: "b", Provider: "claude", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := db.SetSessionProject("b", " garden "); err != nil {
+		t.Fatalf("SetSessionProject failed: %v", err)
+	}
+	if err := db.SetSessionProject("missing", "x"); err == nil {
+		t.Error("expected an error for a missing session")
+	}
+
+	projects, err := db.ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if !reflect.DeepEqual(projects, []string{"ai-tui", "garden"}) {
+		t.Errorf("expected [ai-tui garden], got %v", projects)
+	}
+
+	got, err := db.QuerySessions(SessionQuery{Project: "ai-tui"})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "a" || !reflect.DeepEqual(got[0].Tags, []string{"go"}) {
+		t.Errorf("expected session a with its tags, got %+v", got)
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordUsage stores the token usage of a request and sets its ID.
+func (d *DB) RecordUsage(u *Usage) error {
+	result, err := d.db.Exec(`
+		INSERT INTO usage (session_id, provider, model, created_at,
+			input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, estimated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		u.SessionID,
+		u.Provider,
+		u.Model,
+		u.CreatedAt.Format(time.RFC3339),
+		u.InputTokens,
+		u.OutputTokens,
+		u.CacheReadTokens,
+		u.CacheWriteTokens,
+		u.Estimated,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	u.ID = id
+	return nil
+}
+
+// AggregateUsage sums the usage recorded in [since, until) by day, provider
+// and model, ordered by day and then provider and model. Zero times leave
+// that end open. Days are taken from the stored timestamps, so each request
+// counts towards the local day it was made on.
+func (d *DB) AggregateUsage(since, until time.Time) ([]UsageTotal, error) {
+	query := `
+		SELECT substr(created_at, 1, 10) AS day, provider, model, count(*),
+			sum(input_tokens), sum(output_tokens), sum(cache_read_tokens),
+			sum(cache_write_tokens), sum(estimated)
+		FROM usage
+		WHERE 1 = 1`
+	var args []any
+	if !since.IsZero() {
+		query += " AND datetime(created_at) >= datetime(?)"
+		args = append(args, since.Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		query += " AND datetime(created_at) < datetime(?)"
+		args = append(args, until.Format(time.RFC3339))
+	}
+	query += " GROUP BY day, provider, model ORDER BY day, provider, model"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var t UsageTotal
+		if err := rows.Scan(
+			&t.Day,
+			&t.Provider,
+			&t.Model,
+			&t.Requests,
+			&t.InputTokens,
+			&t.OutputTokens,
+			&t.CacheReadTokens,
+			&t.CacheWriteTokens,
+			&t.Estimated,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage: %w", err)
+	}
+	return totals, nil
+}
+
+// RecordBudgetDecision stores a decision about a request over a provider's
+// limits and sets its ID.
+func (d *DB) RecordBudgetDecision(b *BudgetDecision) error {
+	result, err := d.db.Exec(`
+		INSERT INTO budget_decisions (session_id, provider, model, created_at,
+			input_tokens, estimated_cost, reason, decision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		b.SessionID,
+		b.Provider,
+		b.Model,
+		b.CreatedAt.Format(time.RFC3339),
+		b.InputTokens,
+		b.EstimatedCost,
+		b.Reason,
+		b.Decision,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record budget decision: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	b.ID = id
+	return nil
+}
+
+// ListBudgetDecisions returns the budget decisions made in a session, oldest
+// first.
+func (d *DB) ListBudgetDecisions(sessionID string) ([]BudgetDecision, error) {
+	rows, err := d.db.Query(`
+		SELECT id, session_id, provider, model, created_at, input_tokens,
+			estimated_cost, reason, decision
+		FROM budget_decisions
+		WHERE session_id = ?
+		ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budget decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var decisions []BudgetDecision
+	for rows.Next() {
+		var b BudgetDecision
+		var createdAt string
+		if err := rows.Scan(
+			&b.ID,
+			&b.SessionID,
+			&b.Provider,
+			&b.Model,
+			&createdAt,
+			&b.InputTokens,
+			&b.EstimatedCost,
+			&b.Reason,
+			&b.Decision,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan budget decision: %w", err)
+		}
+		b.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse created_at: %w", err)
+		}
+		decisions = append(decisions, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating budget decisions: %w", err)
+	}
+	return decisions, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateUsage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	records := []Usage{
+		{SessionID: "a", Provider: "claude", Model: "sonnet", CreatedAt: day, InputTokens: 100, OutputTokens: 10},
+		{SessionID: "b", Provider: "claude", Model: "sonnet", CreatedAt: day.Add(time.Hour), InputTokens: 50, CacheReadTokens: 5},
+		{SessionID: "a", Provider: "local", Model: "llama3", CreatedAt: day, InputTokens: 7, Estimated: true},
+		{SessionID: "a", Provider: "claude", Model: "sonnet", CreatedAt: day.AddDate(0, 0, 1), OutputTokens: 20},
+	}
+	for i := range records {
+		if err := db.RecordUsage(&records[i]); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+		if records[i].ID == 0 {
+			t.Error("expected RecordUsage to set the ID")
+		}
+	}
+
+	totals, err := db.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	want := []UsageTotal{
+		{Day: "2026-10-01", Provider: "claude", Model: "sonnet", Requests: 2, InputTokens: 150, OutputTokens: 10, CacheReadTokens: 5},
+		{Day: "2026-10-01", Provider: "local", Model: "llama3", Requests: 1, InputTokens: 7, Estimated: 1},
+		{Day: "2026-10-02", Provider: "claude", Model: "sonnet", Requests: 1, OutputTokens: 20},
+	}
+	if !reflect.DeepEqual(totals, want) {
+		t.Errorf("AggregateUsage = %+v, want %+v", totals, want)
+	}
+
+	since, err := db.AggregateUsage(day.AddDate(0, 0, 1), time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(since) != 1 || since[0].Day != "2026-10-02" {
+		t.Errorf("expected only 2026-10-02 since then, got %+v", since)
+	}
+
+	until, err := db.AggregateUsage(time.Time{}, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(until) != 2 {
+		t.Errorf("expected the two totals of 2026-10-01, got %+v", until)
+	}
+}
+
+func TestBudgetDecisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now().Round(time.Second)
+	decisions := []BudgetDecision{
+		{SessionID: "a", Provider: "claude", Model: "sonnet", CreatedAt: now, InputTokens: 900, EstimatedCost: 0.25, Reason: "daily limit", Decision: DecisionBlocked},
+		{SessionID: "b", Provider: "claude", Model: "sonnet", CreatedAt: now, Reason: "daily limit", Decision: DecisionDeclined},
+		{SessionID: "a", Provider: "claude", Model: "sonnet", CreatedAt: now, Reason: "daily limit", Decision: DecisionConfirmed},
+	}
+	for i := range decisions {
+		if err := db.RecordBudgetDecision(&decisions[i]); err != nil {
+			t.Fatalf("RecordBudgetDecision failed: %v", err)
+		}
+	}
+
+	got, err := db.ListBudgetDecisions("a")
+	if err != nil {
+		t.Fatalf("ListBudgetDecisions failed: %v", err)
+	}
+	want := []BudgetDecision{decisions[0], decisions[2]}
+	for i := range got {
+		got[i].CreatedAt = got[i].CreatedAt.Local()
+		want[i].CreatedAt = want[i].CreatedAt.Local()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListBudgetDecisions = %+v, want %+v", got, want)
+	}
+}
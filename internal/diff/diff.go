@@ -0,0 +1,193 @@
+// Package diff compares texts line by line.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is what a line of a comparison does.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Insert Op = '+'
+	Delete Op = '-'
+)
+
+// Line is a line of a comparison.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the table used to compare two texts. Larger texts are
+// shown as replaced outright.
+const maxCells = 4_000_000
+
+// Lines compares a and b, returning the lines of a with those deleted and
+// inserted to make b, in a longest common subsequence.
+func Lines(a, b string) []Line {
+	as, bs := split(a), split(b)
+	n, m := len(as), len(bs)
+
+	// Skip the common start and end, which is most of a typical edit
+	start := 0
+	for start < n && start < m && as[start] == bs[start] {
+		start++
+	}
+	end := 0
+	for end < n-start && end < m-start && as[n-1-end] == bs[m-1-end] {
+		end++
+	}
+
+	var out []Line
+	for _, s := range as[:start] {
+		out = append(out, Line{Equal, s})
+	}
+	out = append(out, compare(as[start:n-end], bs[start:m-end])...)
+	for _, s := range as[n-end:] {
+		out = append(out, Line{Equal, s})
+	}
+	return out
+}
+
+// compare diffs the lines that differ with a longest common subsequence.
+func compare(as, bs []string) []Line {
+	n, m := len(as), len(bs)
+	var out []Line
+	if (n+1)*(m+1) > maxCells {
+		for _, s := range as {
+			out = append(out, Line{Delete, s})
+		}
+		for _, s := range bs {
+			out = append(out, Line{Insert, s})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the length of the common subsequence of as[i:] and bs[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case as[i] == bs[j]:
+			out = append(out, Line{Equal, as[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, as[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, bs[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, Line{Delete, as[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, Line{Insert, bs[j]})
+	}
+	return out
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Stat counts the inserted and deleted lines.
+func Stat(lines []Line) (inserted, deleted int) {
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// Unified lays out the changes as unified diff hunks, each under an
+// "@@ -a,b +c,d @@" header with up to context unchanged lines around it.
+func Unified(lines []Line, context int) []string {
+	var out []string
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		// The hunk runs until more than twice the context is unchanged
+		start := max(i-context, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = run
+		}
+
+		aStart, bStart := 1, 1
+		for _, l := range lines[:start] {
+			if l.Op != Insert {
+				aStart++
+			}
+			if l.Op != Delete {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, l := range lines[start:end] {
+			if l.Op != Insert {
+				aLen++
+			}
+			if l.Op != Delete {
+				bLen++
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%s +%s @@", span(aStart, aLen), span(bStart, bLen)))
+		for _, l := range lines[start:end] {
+			out = append(out, string(l.Op)+l.Text)
+		}
+		i = end
+	}
+	return out
+}
+
+// span formats a hunk's range of lines; an empty range names the line
+// before it.
+func span(start, n int) string {
+	if n == 0 {
+		start--
+	}
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
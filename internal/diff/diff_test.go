@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	a := "package main\n\nfunc main() {\n\tprintln(1)\n}\n"
+	b := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1)\n}\n"
+
+	var got []string
+	for _, l := range Lines(a, b) {
+		got = append(got, string(l.Op)+l.Text)
+	}
+	want := []string{
+		" package main",
+		" ",
+		"+import \"fmt\"",
+		"+",
+		" func main() {",
+		"-\tprintln(1)",
+		"+\tfmt.Println(1)",
+		" }",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Lines =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if ins, del := Stat(Lines(a, b)); ins != 3 || del != 1 {
+		t.Errorf("Stat = +%d -%d, want +3 -1", ins, del)
+	}
+}
+
+func TestLines_Empty(t *testing.T) {
+	lines := Lines("", "a\nb\n")
+	if len(lines) != 2 || lines[0].Op != Insert || lines[1].Op != Insert {
+		t.Errorf("Lines from empty = %+v, want two insertions", lines)
+	}
+	if lines := Lines("same\n", "same\n"); len(lines) != 1 || lines[0].Op != Equal {
+		t.Errorf("Lines of equal texts = %+v", lines)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, string(rune('a'+i-1)))
+	}
+	b = append(b, a...)
+	b[1] = "B"  // line 2
+	b[17] = "R" // line 18
+
+	got := Unified(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")), 2)
+	want := []string{
+		"@@ -1,4 +1,4 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		"@@ -16,5 +16,5 @@",
+		" p",
+		" q",
+		"-r",
+		"+R",
+		" s",
+		" t",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unified =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Changes close together share a hunk
+	b[3] = "D"
+	if got := Unified(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")), 2); got[0] != "@@ -1,6 +1,6 @@" {
+		t.Errorf("first hunk header = %q, want the two changes in one hunk", got[0])
+	}
+
+	if got := Unified(Lines("x", "x"), 3); len(got) != 0 {
+		t.Errorf("Unified of equal texts = %q, want nothing", got)
+	}
+}
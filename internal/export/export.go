@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// Exporter writes a session in one file format.
+type Exporter interface {
+	// Extension is the file extension, without the dot
+	Extension() string
+	// Write writes the session and its messages to w
+	Write(w io.Writer, session db.Session, messages []db.Message) error
+}
+
+// Formats lists the export format names accepted by ForFormat.
+var Formats = []string{"md", "json", "jsonl", "html"}
+
+// Options configure the exporters ForFormat returns.
+type Options struct {
+	// Template is the path of a Markdown template; "" uses DefaultTemplate
+	Template string
+	// Sessions looks up forks and parents for the links in Markdown notes
+	Sessions Sessions
+}
+
+// ForFormat returns the exporter for a format name: "md" (or "markdown"),
+// "json", "jsonl" or "html".
+func ForFormat(format string, opts Options) (Exporter, error) {
+	switch strings.ToLower(format) {
+	case "md", "markdown":
+		e := Markdown{Sessions: opts.Sessions}
+		if opts.Template != "" {
+			tmpl, err := LoadTemplate(opts.Template)
+			if err != nil {
+				return nil, err
+			}
+			e.Template = tmpl
+		}
+		return e, nil
+	case "json":
+		return JSON{}, nil
+	case "jsonl":
+		return JSONL{}, nil
+	case "html":
+		return HTML{}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(Formats, ", "))
+}
+
+// ToFile exports a session and its messages with e to a new file in dir,
+// named after the session's date and title. Returns the full path of the
+// created file.
+func ToFile(e Exporter, session db.Session, messages []db.Message, dir string) (string, error) {
+	// Render before creating the file so a failure leaves nothing behind
+	content, err := render(e, session, messages)
+	if err != nil {
+		return "", err
+	}
+	path, err := newPath(e, session, dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return path, nil
+}
+
+// render returns the export of a session.
+func render(e Exporter, session db.Session, messages []db.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.Write(&buf, session, messages); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newPath returns an unused path in dir for exporting session with e,
+// creating dir if needed.
+func newPath(e Exporter, session db.Session, dir string) (string, error) {
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Generate filename
+	name := NoteName(session)
+	filename := name + "." + e.Extension()
+
+	// Handle duplicate filenames
+	counter := 1
+	for {
+		if _, err := os.Stat(filepath.Join(dir, filename)); os.IsNotExist(err) {
+			break
+		}
+		filename = fmt.Sprintf("%s-%d.%s", name, counter, e.Extension())
+		counter++
+	}
+
+	fullPath := filepath.Join(dir, filename)
+
+	// Return absolute path
+	absPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		return fullPath, nil // fallback to relative path if abs fails
+	}
+
+	return absPath, nil
+}
@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// HTML exports sessions as a single self-contained page: inline CSS,
+// messages rendered from Markdown with highlighted code, and a light and
+// dark theme. System instructions, summarized messages and the thinking
+// some models wrap in <think> tags are collapsed.
+type HTML struct{}
+
+func (HTML) Extension() string { return "html" }
+
+//go:embed page.html.tmpl
+var pageTemplateText string
+
+var pageTemplate = template.Must(template.New("page").Parse(pageTemplateText))
+
+// Chroma styles for the two themes
+const (
+	lightStyle = "github"
+	darkStyle  = "github-dark"
+)
+
+// codeFormatter highlights code with CSS classes, so the theme can switch.
+var codeFormatter = chromahtml.New(chromahtml.WithClasses(true))
+
+// markdownHTML renders messages. Raw HTML in them is left out, since
+// conversations may hold anything a model wrote.
+var markdownHTML = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+		util.Prioritized(codeBlockRenderer{}, 100),
+	)),
+)
+
+// thinkPattern matches the reasoning some models put before their answer.
+var thinkPattern = regexp.MustCompile(`(?s)<(think|thinking)>(.*?)</(?:think|thinking)>`)
+
+// pageData is what the page template is executed with.
+type pageData struct {
+	Session  db.Session
+	Messages []pageMessage
+	CSS      template.CSS
+}
+
+type pageMessage struct {
+	Role       string // CSS class: user, assistant or system
+	Label      string
+	Thinking   []template.HTML
+	Body       template.HTML
+	Collapsed  bool // shown folded, with Label as the summary
+	Summarized bool
+}
+
+func (HTML) Write(w io.Writer, session db.Session, messages []db.Message) error {
+	css, err := themeCSS()
+	if err != nil {
+		return err
+	}
+	data := pageData{Session: session, CSS: css}
+	for _, m := range messages {
+		pm, err := renderMessage(m)
+		if err != nil {
+			return err
+		}
+		data.Messages = append(data.Messages, pm)
+	}
+	if err := pageTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
+
+// renderMessage renders a message's Markdown, splitting off its thinking.
+func renderMessage(m db.Message) (pageMessage, error) {
+	pm := pageMessage{Role: m.Role, Summarized: m.Summarized}
+	switch m.Role {
+	case "user":
+		pm.Label = "You"
+	case "assistant":
+		pm.Label = "Assistant"
+	default:
+		pm.Role = "system"
+		pm.Label = "System instruction"
+		pm.Collapsed = true
+	}
+	if m.Summarized {
+		pm.Label += " (summarized)"
+		pm.Collapsed = true
+	}
+
+	content := m.Content
+	if m.Role == "assistant" {
+		for _, match := range thinkPattern.FindAllStringSubmatch(content, -1) {
+			thinking, err := renderMarkdown(strings.TrimSpace(match[2]))
+			if err != nil {
+				return pageMessage{}, err
+			}
+			pm.Thinking = append(pm.Thinking, thinking)
+		}
+		content = strings.TrimSpace(thinkPattern.ReplaceAllString(content, ""))
+	}
+
+	body, err := renderMarkdown(content)
+	if err != nil {
+		return pageMessage{}, err
+	}
+	pm.Body = body
+	return pm, nil
+}
+
+func renderMarkdown(text string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := markdownHTML.Convert([]byte(text), &buf); err != nil {
+		return "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// themeCSS returns the highlighting rules of both themes, the dark ones
+// scoped to the page's dark mode.
+func themeCSS() (template.CSS, error) {
+	var buf bytes.Buffer
+	if err := codeFormatter.WriteCSS(&buf, styles.Get(lightStyle)); err != nil {
+		return "", fmt.Errorf("failed to write highlighting CSS: %w", err)
+	}
+	var dark bytes.Buffer
+	if err := codeFormatter.WriteCSS(&dark, styles.Get(darkStyle)); err != nil {
+		return "", fmt.Errorf("failed to write highlighting CSS: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(dark.String()), "\n") {
+		// Rules read "/* Token */ .chroma .k { ... }"
+		buf.WriteString(strings.Replace(line, "*/ ", `*/ [data-theme="dark"] `, 1))
+		buf.WriteString("\n")
+	}
+	return template.CSS(buf.String()), nil
+}
+
+// codeBlockRenderer renders fenced code blocks highlighted by chroma.
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (codeBlockRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		code.Write(seg.Value(source))
+	}
+
+	var lexer chroma.Lexer
+	if lang := n.Language(source); lang != nil {
+		lexer = lexers.Get(string(lang))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("failed to highlight code: %w", err)
+	}
+	if err := codeFormatter.Format(w, styles.Get(lightStyle), iterator); err != nil {
+		return ast.WalkStop, fmt.Errorf("failed to highlight code: %w", err)
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func renderHTML(t *testing.T, messages []db.Message) string {
+	t.Helper()
+	session := db.Session{
+		ID:        "s1",
+		Title:     "Sharing <this>",
+		Provider:  "claude",
+		Model:     "claude-sonnet-4",
+		Tags:      []string{"go"},
+		CreatedAt: time.Date(2026, 9, 14, 9, 5, 0, 0, time.UTC),
+	}
+	var sb strings.Builder
+	if err := (HTML{}).Write(&sb, session, messages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return sb.String()
+}
+
+func TestHTML_Page(t *testing.T) {
+	page := renderHTML(t, []db.Message{
+		{Role: "user", Content: "Show me **bold** code"},
+		{Role: "assistant", Content: "Here:\n\n```go\nfunc main() {}\n```\n"},
+	})
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>Sharing &lt;this&gt;</title>",
+		"September 14, 2026 9:05 AM · claude · claude-sonnet-4",
+		`<span class="tag">#go</span>`,
+		`<section class="message user">`,
+		"<strong>bold</strong>",
+		`<pre class="chroma">`,
+		// Keywords are highlighted, with rules for both themes inline
+		`<span class="kd">func</span>`,
+		".chroma .kd {",
+		`[data-theme="dark"] .chroma .kd {`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the page to contain %q", want)
+		}
+	}
+	// The page must not depend on anything outside the file
+	for _, external := range []string{"<link", "src=", "@import"} {
+		if strings.Contains(page, external) {
+			t.Errorf("expected a self-contained page, found %q", external)
+		}
+	}
+}
+
+func TestHTML_CollapsesSections(t *testing.T) {
+	page := renderHTML(t, []db.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "old question", Summarized: true},
+		{Role: "assistant", Content: "<think>\nThe user wants a *short* answer.\n</think>\n\nShort answer."},
+	})
+
+	for _, want := range []string{
+		"<details class=\"message system\">\n<summary>System instruction</summary>\n<p>Be brief.</p>",
+		"<details class=\"message user\">\n<summary>You (summarized)</summary>",
+		"<details class=\"thinking\"><summary>Thinking</summary>\n<p>The user wants a <em>short</em> answer.</p>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the page to contain %q", want)
+		}
+	}
+	if strings.Contains(page, "&lt;think&gt;") || !strings.Contains(page, "<p>Short answer.</p>") {
+		t.Error("the thinking should be taken out of the answer")
+	}
+}
+
+func TestHTML_LeavesOutRawHTML(t *testing.T) {
+	page := renderHTML(t, []db.Message{
+		{Role: "assistant", Content: "<script>alert(1)</script>\n\nsafe"},
+	})
+	if strings.Contains(page, "alert(1)") {
+		t.Error("raw HTML in messages should not reach the page")
+	}
+}
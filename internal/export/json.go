@@ -0,0 +1,134 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// jsonVersion is the version of the JSON export document
+const jsonVersion = 1
+
+// JSON exports sessions as a document holding everything stored about them,
+// which ReadJSON reads back.
+type JSON struct{}
+
+func (JSON) Extension() string { return "json" }
+
+// jsonDocument is the JSON export of a session.
+type jsonDocument struct {
+	Version  int           `json:"version"`
+	Session  jsonSession   `json:"session"`
+	Messages []jsonMessage `json:"messages"`
+}
+
+type jsonSession struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Archived  bool      `json:"archived,omitempty"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+	Project   string    `json:"project,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+type jsonMessage struct {
+	Role       string    `json:"role"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+	Tokens     int       `json:"tokens,omitempty"`
+	Pinned     bool      `json:"pinned,omitempty"`
+	Summarized bool      `json:"summarized,omitempty"`
+}
+
+func (JSON) Write(w io.Writer, session db.Session, messages []db.Message) error {
+	doc := jsonDocument{
+		Version: jsonVersion,
+		Session: jsonSession{
+			ID:        session.ID,
+			Title:     session.Title,
+			Provider:  session.Provider,
+			Model:     session.Model,
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
+			Archived:  session.Archived,
+			ParentID:  session.ParentID,
+			Persona:   session.Persona,
+			Project:   session.Project,
+			Pinned:    session.Pinned,
+			Tags:      session.Tags,
+		},
+		Messages: make([]jsonMessage, 0, len(messages)),
+	}
+	for _, m := range messages {
+		doc.Messages = append(doc.Messages, jsonMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			CreatedAt:  m.CreatedAt,
+			Tokens:     m.Tokens,
+			Pinned:     m.Pinned,
+			Summarized: m.Summarized,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+// ReadJSON reads a session written by the JSON exporter. Messages carry
+// their session ID but no database ID until they're stored.
+func ReadJSON(r io.Reader) (db.Session, []db.Message, error) {
+	var doc jsonDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return db.Session{}, nil, fmt.Errorf("failed to read JSON export: %w", err)
+	}
+	if doc.Version != jsonVersion {
+		return db.Session{}, nil, fmt.Errorf("unsupported JSON export version %d", doc.Version)
+	}
+	if doc.Session.ID == "" {
+		return db.Session{}, nil, fmt.Errorf("JSON export has no session id")
+	}
+
+	s := doc.Session
+	session := db.Session{
+		ID:           s.ID,
+		Title:        s.Title,
+		Provider:     s.Provider,
+		Model:        s.Model,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+		Archived:     s.Archived,
+		ParentID:     s.ParentID,
+		Persona:      s.Persona,
+		Project:      s.Project,
+		Pinned:       s.Pinned,
+		Tags:         s.Tags,
+		MessageCount: len(doc.Messages),
+	}
+	messages := make([]db.Message, 0, len(doc.Messages))
+	for _, m := range doc.Messages {
+		session.TokenCount += m.Tokens
+		messages = append(messages, db.Message{
+			SessionID:  s.ID,
+			Role:       m.Role,
+			Content:    m.Content,
+			CreatedAt:  m.CreatedAt,
+			Tokens:     m.Tokens,
+			Pinned:     m.Pinned,
+			Summarized: m.Summarized,
+		})
+	}
+	return session, messages, nil
+}
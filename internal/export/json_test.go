@@ -0,0 +1,171 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func testConversation() (db.Session, []db.Message) {
+	createdAt := time.Date(2026, 9, 14, 9, 5, 0, 0, time.UTC)
+	session := db.Session{
+		ID:        "session-json",
+		Title:     "Round trip",
+		Provider:  "claude",
+		Model:     "claude-sonnet-4",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt.Add(time.Hour),
+		Archived:  true,
+		ParentID:  "session-parent",
+		Persona:   "reviewer",
+		Project:   "ai-tui",
+		Pinned:    true,
+		Tags:      []string{"go", "work"},
+	}
+	messages := []db.Message{
+		{Role: "system", Content: "Be brief.", CreatedAt: createdAt},
+		{Role: "user", Content: "Explain \"defer\"\nin Go", CreatedAt: createdAt.Add(time.Minute), Tokens: 6, Summarized: true},
+		{Role: "assistant", Content: "It runs a call when the function returns.", CreatedAt: createdAt.Add(2 * time.Minute), Tokens: 11, Pinned: true},
+	}
+	return session, messages
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	session, messages := testConversation()
+
+	var buf bytes.Buffer
+	if err := (JSON{}).Write(&buf, session, messages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, gotMessages, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+
+	session.MessageCount = 3
+	session.TokenCount = 17
+	if !reflect.DeepEqual(got, session) {
+		t.Errorf("session = %+v, want %+v", got, session)
+	}
+	for i := range messages {
+		messages[i].SessionID = session.ID
+	}
+	if !reflect.DeepEqual(gotMessages, messages) {
+		t.Errorf("messages = %+v, want %+v", gotMessages, messages)
+	}
+}
+
+func TestJSON_ReimportIntoDatabase(t *testing.T) {
+	src, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer src.Close()
+	session, messages := testConversation()
+	if err := src.CreateSessionWithMessages(&session, messages); err != nil {
+		t.Fatalf("CreateSessionWithMessages failed: %v", err)
+	}
+	want, _ := src.GetSession(session.ID)
+	wantMessages, _ := src.GetSessionMessages(session.ID)
+
+	var buf bytes.Buffer
+	if err := (JSON{}).Write(&buf, *want, wantMessages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	imported, importedMessages, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+
+	dst, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer dst.Close()
+	if err := dst.CreateSessionWithMessages(&imported, importedMessages); err != nil {
+		t.Fatalf("re-import failed: %v", err)
+	}
+	got, err := dst.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	gotMessages, err := dst.GetSessionMessages(session.ID)
+	if err != nil {
+		t.Fatalf("GetSessionMessages failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("re-imported session = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(gotMessages, wantMessages) {
+		t.Errorf("re-imported messages = %+v, want %+v", gotMessages, wantMessages)
+	}
+}
+
+func TestReadJSON_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not json":        "hello",
+		"unknown version": `{"version": 99, "session": {"id": "x"}}`,
+		"no session id":   `{"version": 1, "session": {}}`,
+	}
+	for name, input := range tests {
+		if _, _, err := ReadJSON(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestJSONL_Format(t *testing.T) {
+	session, messages := testConversation()
+
+	var buf bytes.Buffer
+	if err := (JSONL{}).Write(&buf, session, messages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// The summarized message is left out, as it was for the model
+	want := `{"messages":[{"role":"system","content":"Be brief."},{"role":"assistant","content":"It runs a call when the function returns."}]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("JSONL = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSONL_EmptySessionWritesNothing(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONL{}).Write(&buf, db.Session{ID: "empty"}, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestForFormat(t *testing.T) {
+	for format, want := range map[string]string{"md": "md", "Markdown": "md", "json": "json", "JSONL": "jsonl"} {
+		e, err := ForFormat(format, Options{})
+		if err != nil {
+			t.Fatalf("ForFormat(%q) failed: %v", format, err)
+		}
+		if e.Extension() != want {
+			t.Errorf("ForFormat(%q) extension = %q, want %q", format, e.Extension(), want)
+		}
+	}
+	if _, err := ForFormat("pdf", Options{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestToFile_Extension(t *testing.T) {
+	session, messages := testConversation()
+	path, err := ToFile(JSON{}, session, messages, t.TempDir())
+	if err != nil {
+		t.Fatalf("ToFile failed: %v", err)
+	}
+	if !strings.HasSuffix(path, "2026-09-14-round-trip.json") {
+		t.Errorf("path = %s, want a .json file named after the session", path)
+	}
+}
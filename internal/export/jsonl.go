@@ -0,0 +1,44 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// JSONL exports sessions in the OpenAI fine-tuning format: each line is one
+// conversation as {"messages": [{"role": ..., "content": ...}, ...]}, so
+// exports of several sessions can be concatenated into one training file.
+// Messages replaced by a summary are left out, as they were for the model.
+type JSONL struct{}
+
+func (JSONL) Extension() string { return "jsonl" }
+
+type jsonlLine struct {
+	Messages []jsonlMessage `json:"messages"`
+}
+
+type jsonlMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (JSONL) Write(w io.Writer, session db.Session, messages []db.Message) error {
+	line := jsonlLine{Messages: make([]jsonlMessage, 0, len(messages))}
+	for _, m := range messages {
+		if m.Summarized {
+			continue
+		}
+		line.Messages = append(line.Messages, jsonlMessage{Role: m.Role, Content: m.Content})
+	}
+	if len(line.Messages) == 0 {
+		return nil
+	}
+	// Encode writes the line with its newline
+	if err := json.NewEncoder(w).Encode(line); err != nil {
+		return fmt.Errorf("failed to write JSONL: %w", err)
+	}
+	return nil
+}
@@ -1,57 +1,158 @@
 package export
 
 import (
+	_ "embed"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/mg/ai-tui/internal/db"
 )
 
-// ToMarkdown exports a session and its messages to a markdown file in dir.
-// Returns the full path of the created file.
-func ToMarkdown(session db.Session, messages []db.Message, dir string) (string, error) {
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+// Sessions looks up the sessions a note links to. *db.DB implements it.
+type Sessions interface {
+	GetSession(id string) (*db.Session, error)
+	ListForks(parentID string) ([]db.Session, error)
+}
+
+// Markdown exports sessions as Markdown notes laid out by a text/template.
+type Markdown struct {
+	// Template lays out the note; DefaultTemplate is used when nil
+	Template *template.Template
+	// Sessions looks up the parent and forks of a session so the note can
+	// link to theirs; without it notes have no links
+	Sessions Sessions
+}
+
+// TemplateData is what Markdown templates are executed with.
+type TemplateData struct {
+	Session  db.Session
+	Messages []db.Message // including system messages
+	Tokens   int          // sum of the messages' tokens
+	Parent   *db.Session  // session this one was forked from, nil if none
+	Forks    []db.Session // sessions forked from this one
+}
+
+// templateFuncs are the functions available to Markdown templates.
+var templateFuncs = template.FuncMap{
+	// yaml quotes a string for a YAML value
+	"yaml": strconv.Quote,
+	// quote prefixes each line with "> ", for callouts and blockquotes
+	"quote": quote,
+	// wikilink links to the note of a session
+	"wikilink": wikiLink,
+	// note is the file name, without extension, a session is exported to
+	"note": NoteName,
+}
+
+//go:embed default.md.tmpl
+var defaultTemplateText string
+
+// DefaultTemplate is the built-in layout: YAML front matter, then each
+// message under a callout header, then links to the session's forks.
+// Messages are laid out by its "message" template, which Append uses too.
+var DefaultTemplate = template.Must(template.New("default").Funcs(templateFuncs).Parse(defaultTemplateText))
+
+// LoadTemplate parses the Markdown template in the file at path.
+func LoadTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
+	return tmpl, nil
+}
 
-	// Generate filename
-	datePrefix := session.CreatedAt.Format("2006-01-02")
-	sanitizedTitle := sanitizeTitle(session.Title)
-	baseFilename := fmt.Sprintf("%s-%s.md", datePrefix, sanitizedTitle)
-
-	// Handle duplicate filenames
-	filename := baseFilename
-	counter := 1
-	for {
-		fullPath := filepath.Join(dir, filename)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			break
+func (Markdown) Extension() string { return "md" }
+
+func (e Markdown) Write(w io.Writer, session db.Session, messages []db.Message) error {
+	data := TemplateData{Session: session, Messages: messages}
+	for _, m := range messages {
+		data.Tokens += m.Tokens
+	}
+	if e.Sessions != nil {
+		if session.ParentID != "" {
+			// A deleted parent leaves the fork without a link
+			if parent, err := e.Sessions.GetSession(session.ParentID); err == nil {
+				data.Parent = parent
+			}
+		}
+		forks, err := e.Sessions.ListForks(session.ID)
+		if err != nil {
+			return err
 		}
-		filename = fmt.Sprintf("%s-%s-%d.md", datePrefix, sanitizedTitle, counter)
-		counter++
+		data.Forks = forks
 	}
 
-	fullPath := filepath.Join(dir, filename)
+	tmpl := e.Template
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write markdown: %w", err)
+	}
+	return nil
+}
 
-	// Build markdown content
-	content := buildMarkdownContent(session, messages)
+// Append writes messages with the template's "message" template, for
+// adding them to an earlier export. Templates without one can't append.
+func (e Markdown) Append(w io.Writer, session db.Session, messages []db.Message) error {
+	tmpl := e.Template
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	message := tmpl.Lookup("message")
+	if message == nil {
+		return ErrCannotAppend
+	}
+	for _, m := range messages {
+		if err := message.Execute(w, m); err != nil {
+			return fmt.Errorf("failed to write markdown: %w", err)
+		}
+	}
+	return nil
+}
+
+// NoteName is the file name, without extension, that a session is exported
+// to: its date and title. Wiki-links use it to refer to the note.
+func NoteName(session db.Session) string {
+	return session.CreatedAt.Format("2006-01-02") + "-" + sanitizeTitle(session.Title)
+}
 
-	// Write file
-	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+// wikiLink links to the note of session, shown as its title.
+func wikiLink(session db.Session) string {
+	title := strings.NewReplacer("[", "(", "]", ")", "|", "-").Replace(session.Title)
+	if title == "" {
+		title = "Untitled"
 	}
+	return "[[" + NoteName(session) + "|" + title + "]]"
+}
 
-	// Return absolute path
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil {
-		return fullPath, nil // fallback to relative path if abs fails
+// quote prefixes each line of s with "> ", and blank lines with ">".
+func quote(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
 	}
+	return strings.Join(lines, "\n")
+}
 
-	return absPath, nil
+// ToMarkdown exports a session and its messages to a markdown file in dir.
+// Returns the full path of the created file.
+func ToMarkdown(session db.Session, messages []db.Message, dir string) (string, error) {
+	return ToFile(Markdown{}, session, messages, dir)
 }
 
 // sanitizeTitle converts a title into a safe filename component
@@ -88,41 +189,3 @@ func sanitizeTitle(title string) string {
 
 	return s
 }
-
-// buildMarkdownContent generates the markdown content from session and messages
-func buildMarkdownContent(session db.Session, messages []db.Message) string {
-	var sb strings.Builder
-
-	// Header
-	sb.WriteString(fmt.Sprintf("# %s\n\n", session.Title))
-
-	// Metadata
-	sb.WriteString(fmt.Sprintf("**Provider:** %s | **Model:** %s  \n", session.Provider, session.Model))
-	sb.WriteString(fmt.Sprintf("**Date:** %s\n\n", session.CreatedAt.Format("January 2, 2006 3:04 PM")))
-
-	// Messages
-	for _, msg := range messages {
-		// Skip system messages
-		if msg.Role == "system" {
-			continue
-		}
-
-		sb.WriteString("---\n\n")
-
-		// Role header
-		if msg.Role == "user" {
-			sb.WriteString("**You:**\n\n")
-		} else if msg.Role == "assistant" {
-			sb.WriteString("**Assistant:**\n\n")
-		}
-
-		// Content
-		sb.WriteString(msg.Content)
-		sb.WriteString("\n\n")
-	}
-
-	// Final separator
-	sb.WriteString("---\n")
-
-	return sb.String()
-}
@@ -1,6 +1,7 @@
 package export
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -65,13 +66,11 @@ func TestToMarkdown_Format(t *testing.T) {
 	// Verify expected content
 	expectedParts := []string{
 		"# Test Session",
-		"**Provider:** anthropic | **Model:** claude-opus-4",
-		"**Date:** February 3, 2026 2:30 PM",
-		"**You:**",
-		"Hello, how are you?",
-		"**Assistant:**",
-		"I'm doing well, thank you!",
-		"---",
+		"provider: \"anthropic\"\nmodel: \"claude-opus-4\"",
+		"created: 2026-02-03T14:30:00Z",
+		"tokens: 12",
+		"> [!user] You\n> Hello, how are you?",
+		"> [!assistant] Assistant\n> I'm doing well, thank you!",
 	}
 
 	for _, part := range expectedParts {
@@ -297,3 +296,142 @@ func TestToMarkdown_SystemMessagesSkipped(t *testing.T) {
 		t.Errorf("Assistant message should appear in output")
 	}
 }
+
+func TestToMarkdown_FrontMatter(t *testing.T) {
+	dir := t.TempDir()
+
+	session := db.Session{
+		ID:        "session-tags",
+		Title:     "Tags: a test",
+		Provider:  "anthropic",
+		Model:     "claude-opus-4",
+		Project:   "ai-tui",
+		Tags:      []string{"go", "work"},
+		CreatedAt: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC),
+	}
+
+	filePath, err := ToMarkdown(session, nil, dir)
+	if err != nil {
+		t.Fatalf("ToMarkdown failed: %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	want := `---
+id: "session-tags"
+title: "Tags: a test"
+provider: "anthropic"
+model: "claude-opus-4"
+created: 2026-02-03T10:00:00Z
+project: "ai-tui"
+tags:
+  - go
+  - work
+tokens: 0
+---
+
+# Tags: a test
+`
+	if !strings.HasPrefix(string(content), want) {
+		t.Errorf("Expected front matter:\n%s\nFull content:\n%s", want, content)
+	}
+}
+
+func TestMarkdown_CalloutQuotesEveryLine(t *testing.T) {
+	session := db.Session{ID: "s", Title: "Code", CreatedAt: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)}
+	messages := []db.Message{{Role: "assistant", Content: "Run:\n\n```sh\ngo test\n```\n"}}
+
+	var sb strings.Builder
+	if err := (Markdown{}).Write(&sb, session, messages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	want := "> [!assistant] Assistant\n> Run:\n>\n> ```sh\n> go test\n> ```\n"
+	if !strings.HasSuffix(sb.String(), want) {
+		t.Errorf("Expected the message inside the callout:\n%s\nFull content:\n%s", want, sb.String())
+	}
+}
+
+// fakeSessions serves sessions from a map for the fork links.
+type fakeSessions map[string]db.Session
+
+func (f fakeSessions) GetSession(id string) (*db.Session, error) {
+	s, ok := f[id]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	return &s, nil
+}
+
+func (f fakeSessions) ListForks(parentID string) ([]db.Session, error) {
+	var forks []db.Session
+	for _, s := range f {
+		if s.ParentID == parentID {
+			forks = append(forks, s)
+		}
+	}
+	return forks, nil
+}
+
+func TestMarkdown_WikiLinksBetweenForks(t *testing.T) {
+	day := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	parent := db.Session{ID: "parent", Title: "Plan [draft]", CreatedAt: day}
+	fork := db.Session{ID: "fork", Title: "Plan (fork)", ParentID: "parent", CreatedAt: day.AddDate(0, 0, 1)}
+	e := Markdown{Sessions: fakeSessions{"parent": parent, "fork": fork}}
+
+	var sb strings.Builder
+	if err := e.Write(&sb, parent, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.Contains(sb.String(), "## Forks\n\n- [[2026-02-04-plan-fork|Plan (fork)]]\n") {
+		t.Errorf("Expected the parent to link to its fork, got:\n%s", sb.String())
+	}
+
+	sb.Reset()
+	if err := e.Write(&sb, fork, nil); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, want := range []string{
+		`forked_from: "[[2026-02-03-plan-draft|Plan (draft)]]"`,
+		"Forked from [[2026-02-03-plan-draft|Plan (draft)]]",
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("Expected the fork to contain %q, got:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestMarkdown_UserTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.tmpl")
+	text := "{{.Session.Title}} ({{note .Session}}){{range .Messages}}\n{{.Role}}: {{.Content}}{{end}}\n"
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := ForFormat("md", Options{Template: path})
+	if err != nil {
+		t.Fatalf("ForFormat failed: %v", err)
+	}
+
+	session := db.Session{ID: "s", Title: "Mine", CreatedAt: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)}
+	var sb strings.Builder
+	if err := e.Write(&sb, session, []db.Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if want := "Mine (2026-02-03-mine)\nuser: hi\n"; sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestLoadTemplate_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Session.Title"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplate(path); err == nil {
+		t.Error("expected a parse error")
+	}
+	if _, err := LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+}
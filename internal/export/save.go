@@ -0,0 +1,188 @@
+package export
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// Store records where sessions were exported. *db.DB implements it.
+type Store interface {
+	GetExport(sessionID, format string) (*db.Export, error)
+	SaveExport(e *db.Export) error
+}
+
+// Appender is implemented by exporters that can add messages to the end of
+// an earlier export of the session.
+type Appender interface {
+	// Append writes messages that follow the ones already exported, or
+	// returns ErrCannotAppend
+	Append(w io.Writer, session db.Session, messages []db.Message) error
+}
+
+// ErrCannotAppend reports that an exporter can't append to its exports.
+var ErrCannotAppend = errors.New("export can't be appended to")
+
+// Result describes what Save did.
+type Result struct {
+	// Path is the file the session was exported to
+	Path string
+	// Edited is the earlier export, changed by the user since it was
+	// written, that was left alone in favour of the new file at Path
+	Edited string
+}
+
+// String describes the result for the status bar.
+func (r Result) String() string {
+	if r.Edited != "" {
+		return fmt.Sprintf("Exported to %s (%s was edited, so it was left as is)", r.Path, r.Edited)
+	}
+	return "Exported to " + r.Path
+}
+
+// Save exports a session with e, updating the file it was last exported to
+// in the same format and directory instead of creating another one. With appendNew, only
+// messages added since that export are appended to it, when e can append,
+// the conversation still starts with the exported messages and nothing else
+// in the export would change; otherwise the file is rewritten. A file the
+// user has edited since is never rewritten: the session is exported to a
+// new file, which later exports update. The first export, one to another
+// directory, or one whose file is gone creates a new file in dir.
+func Save(store Store, e Exporter, session db.Session, messages []db.Message, dir string, appendNew bool) (Result, error) {
+	format := e.Extension()
+	prev, err := store.GetExport(session.ID, format)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if prev != nil && !sameDir(filepath.Dir(prev.Path), dir) {
+		prev = nil
+	}
+
+	var result Result
+	if prev != nil {
+		old, err := os.ReadFile(prev.Path)
+		switch {
+		case os.IsNotExist(err):
+			// Moved or deleted, so start a new file
+		case err != nil:
+			return Result{}, fmt.Errorf("failed to read earlier export: %w", err)
+		default:
+			if appendNew && extends(prev, messages) {
+				// Appending keeps the user's edits, so they don't matter here
+				content, err := appendTo(e, old, session, messages, prev.MessageCount)
+				if err == nil {
+					return Result{Path: prev.Path}, write(store, session, format, prev.Path, content, messages)
+				}
+				if !errors.Is(err, ErrCannotAppend) {
+					return Result{}, err
+				}
+			}
+			if hash(old) == prev.Hash {
+				content, err := render(e, session, messages)
+				if err != nil {
+					return Result{}, err
+				}
+				return Result{Path: prev.Path}, write(store, session, format, prev.Path, content, messages)
+			}
+			result.Edited = prev.Path
+		}
+	}
+
+	content, err := render(e, session, messages)
+	if err != nil {
+		return Result{}, err
+	}
+	path, err := newPath(e, session, dir)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Path = path
+	return result, write(store, session, format, path, content, messages)
+}
+
+// extends reports whether messages start with the ones in the export prev,
+// which a retry, edit or regeneration since would have changed. Exports
+// recorded without a hash of their messages are trusted by their count.
+func extends(prev *db.Export, messages []db.Message) bool {
+	if len(messages) < prev.MessageCount {
+		return false
+	}
+	return prev.MessagesHash == "" || prev.MessagesHash == messagesHash(messages[:prev.MessageCount])
+}
+
+// appendTo returns old, the export of the first count messages, with the
+// rest appended by e. It returns ErrCannotAppend if e can't append, or if
+// the new messages would change more of the export than its end, such as a
+// token count in the front matter or links to forks after the messages.
+func appendTo(e Exporter, old []byte, session db.Session, messages []db.Message, count int) ([]byte, error) {
+	a, ok := e.(Appender)
+	if !ok {
+		return nil, ErrCannotAppend
+	}
+	var added bytes.Buffer
+	if err := a.Append(&added, session, messages[count:]); err != nil {
+		return nil, err
+	}
+
+	before, err := render(e, session, messages[:count])
+	if err != nil {
+		return nil, err
+	}
+	after, err := render(e, session, messages)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(append(before, added.Bytes()...), after) {
+		return nil, ErrCannotAppend
+	}
+	return append(old, added.Bytes()...), nil
+}
+
+// write writes an export of messages to path and records it.
+func write(store Store, session db.Session, format, path string, content []byte, messages []db.Message) error {
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return store.SaveExport(&db.Export{
+		SessionID:    session.ID,
+		Format:       format,
+		Path:         path,
+		Hash:         hash(content),
+		MessageCount: len(messages),
+		MessagesHash: messagesHash(messages),
+		ExportedAt:   time.Now(),
+	})
+}
+
+// sameDir reports whether paths a and b name the same directory.
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// messagesHash identifies messages by their roles and contents.
+func messagesHash(messages []db.Message) string {
+	h := sha256.New()
+	for _, m := range messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,274 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func saveTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func saveConversation() (db.Session, []db.Message) {
+	createdAt := time.Date(2026, 9, 14, 9, 0, 0, 0, time.UTC)
+	session := db.Session{ID: "s1", Title: "Notes", Provider: "claude", Model: "m", CreatedAt: createdAt, UpdatedAt: createdAt}
+	messages := []db.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+	}
+	return session, messages
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestSave_OverwritesEarlierExport(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	first, err := Save(store, Markdown{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	messages = append(messages, db.Message{Role: "user", Content: "second question"})
+	second, err := Save(store, Markdown{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if second.Path != first.Path || second.Edited != "" {
+		t.Errorf("re-export = %+v, want the same file %s", second, first.Path)
+	}
+	if !strings.Contains(readFile(t, second.Path), "second question") {
+		t.Error("the file should hold the new message")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected one file in %s, got %d", dir, len(entries))
+	}
+	record, err := store.GetExport(session.ID, "md")
+	if err != nil || record == nil || record.MessageCount != 3 {
+		t.Errorf("export record = %+v, %v; want 3 messages", record, err)
+	}
+}
+
+func TestSave_AppendsOnlyNewMessages(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	first, err := Save(store, Markdown{}, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Edits are kept, since appending doesn't touch what's there
+	edited := readFile(t, first.Path) + "\nmy own note\n"
+	if err := os.WriteFile(first.Path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	messages = append(messages, db.Message{Role: "assistant", Content: "more"})
+	second, err := Save(store, Markdown{}, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if second.Path != first.Path {
+		t.Errorf("appended to %s, want %s", second.Path, first.Path)
+	}
+	want := edited + "\n> [!assistant] Assistant\n> more\n"
+	if got := readFile(t, second.Path); got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestSave_RewritesWhenMoreThanTheEndChanges(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+	if err := store.CreateSession(&session); err != nil {
+		t.Fatal(err)
+	}
+	e := Markdown{Sessions: store}
+
+	first, err := Save(store, e, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A reply with tokens changes the front matter
+	messages = append(messages, db.Message{Role: "user", Content: "second question"}, db.Message{Role: "assistant", Content: "second answer", Tokens: 7})
+	if _, err := Save(store, e, session, messages, dir, true); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	got := readFile(t, first.Path)
+	if !strings.Contains(got, "tokens: 7") {
+		t.Errorf("expected the front matter tokens updated, got:\n%s", got)
+	}
+
+	// A fork adds links after the messages, which new messages go before
+	fork := db.Session{ID: "s2", Title: "Fork", Provider: "claude", ParentID: session.ID, CreatedAt: session.CreatedAt, UpdatedAt: session.CreatedAt}
+	if err := store.CreateSession(&fork); err != nil {
+		t.Fatal(err)
+	}
+	messages = append(messages, db.Message{Role: "user", Content: "third question"})
+	if _, err := Save(store, e, session, messages, dir, true); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	got = readFile(t, first.Path)
+	forks := strings.Index(got, "## Forks")
+	if forks < 0 || strings.Index(got, "third question") > forks {
+		t.Errorf("expected the new message before the forks, got:\n%s", got)
+	}
+}
+
+func TestSave_RewritesAfterRetry(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	first, err := Save(store, Markdown{}, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// The answer is retried: same count, different last message
+	messages[1] = db.Message{Role: "assistant", Content: "better answer", Tokens: 5}
+	second, err := Save(store, Markdown{}, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if second.Path != first.Path {
+		t.Errorf("rewrote %s, want %s", second.Path, first.Path)
+	}
+	got := readFile(t, second.Path)
+	if strings.Contains(got, "first answer") || !strings.Contains(got, "better answer") {
+		t.Errorf("expected the export rewritten with the retried answer, got:\n%s", got)
+	}
+	if !strings.Contains(got, "tokens: 5") {
+		t.Errorf("expected the front matter tokens updated, got:\n%s", got)
+	}
+
+	// A regenerated answer with a new reply after it isn't appended to the stale one
+	messages[1].Content = "regenerated answer"
+	messages = append(messages, db.Message{Role: "user", Content: "thanks"})
+	if _, err := Save(store, Markdown{}, session, messages, dir, true); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	got = readFile(t, first.Path)
+	if strings.Contains(got, "better answer") || !strings.Contains(got, "regenerated answer") || !strings.Contains(got, "thanks") {
+		t.Errorf("expected the export rewritten, got:\n%s", got)
+	}
+}
+
+func TestSave_KeepsEditedFile(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	first, err := Save(store, Markdown{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.WriteFile(first.Path, []byte("rewritten by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Save(store, Markdown{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if second.Edited != first.Path || second.Path == first.Path {
+		t.Fatalf("re-export = %+v, want a new file beside the edited %s", second, first.Path)
+	}
+	if got := readFile(t, first.Path); got != "rewritten by hand\n" {
+		t.Errorf("the edited file was changed to %q", got)
+	}
+	if !strings.Contains(second.String(), "was edited") {
+		t.Errorf("String() = %q, want it to mention the edit", second.String())
+	}
+
+	// Later exports update the new file
+	third, err := Save(store, Markdown{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if third.Path != second.Path || third.Edited != "" {
+		t.Errorf("third export = %+v, want %s", third, second.Path)
+	}
+}
+
+func TestSave_NewFileWhenMovedOrElsewhere(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	first, err := Save(store, JSON{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.Remove(first.Path); err != nil {
+		t.Fatal(err)
+	}
+	second, err := Save(store, JSON{}, session, messages, dir, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if second.Path != first.Path || second.Edited != "" {
+		t.Errorf("re-export of a deleted file = %+v, want it recreated at %s", second, first.Path)
+	}
+
+	other := filepath.Join(t.TempDir(), "elsewhere")
+	third, err := Save(store, JSON{}, session, messages, other, false)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if filepath.Dir(third.Path) != other {
+		t.Errorf("export to another directory went to %s", third.Path)
+	}
+}
+
+func TestSave_AppendFallsBackToOverwrite(t *testing.T) {
+	store := saveTestDB(t)
+	dir := t.TempDir()
+	session, messages := saveConversation()
+
+	// JSON documents can't be appended to, so they're rewritten
+	first, err := Save(store, JSON{}, session, messages, dir, true)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	messages = append(messages, db.Message{Role: "user", Content: "again"})
+	if _, err := Save(store, JSON{}, session, messages, dir, true); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	f, err := os.Open(first.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, got, err := ReadJSON(f)
+	if err != nil {
+		t.Fatalf("the rewritten export should still be valid: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d messages, want 3", len(got))
+	}
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// chatgptProvider is the provider ChatGPT conversations are stored under.
+const chatgptProvider = "openai"
+
+// chatgptConversation is a conversation in the conversations.json of a
+// ChatGPT data export. Its messages form a tree in Mapping, and CurrentNode
+// is the last message of the branch shown in ChatGPT.
+type chatgptConversation struct {
+	ID               string                 `json:"id"`
+	ConversationID   string                 `json:"conversation_id"`
+	Title            string                 `json:"title"`
+	CreateTime       float64                `json:"create_time"`
+	UpdateTime       float64                `json:"update_time"`
+	Mapping          map[string]chatgptNode `json:"mapping"`
+	CurrentNode      string                 `json:"current_node"`
+	DefaultModelSlug string                 `json:"default_model_slug"`
+}
+
+type chatgptNode struct {
+	Message *chatgptMessage `json:"message"`
+	Parent  string          `json:"parent"`
+}
+
+type chatgptMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+func readChatGPT(r io.Reader, opts Options) ([]Conversation, error) {
+	var convs []chatgptConversation
+	if err := json.NewDecoder(r).Decode(&convs); err != nil {
+		return nil, fmt.Errorf("failed to read ChatGPT export: %w", err)
+	}
+
+	var out []Conversation
+	for _, c := range convs {
+		id := c.ConversationID
+		if id == "" {
+			id = c.ID
+		}
+		if id == "" {
+			return nil, fmt.Errorf("ChatGPT conversation '%s' has no id", c.Title)
+		}
+		created := unixTime(c.CreateTime)
+		session := db.Session{
+			Title:     c.Title,
+			Provider:  chatgptProvider,
+			Model:     c.DefaultModelSlug,
+			CreatedAt: created,
+			UpdatedAt: unixTime(c.UpdateTime),
+		}
+		if session.UpdatedAt.IsZero() {
+			session.UpdatedAt = created
+		}
+
+		// Oldest first, so the newest model used names the session's model
+		ids := make([]string, 0, len(c.Mapping))
+		for nodeID := range c.Mapping {
+			ids = append(ids, nodeID)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			ti, tj := c.Mapping[ids[i]].time(), c.Mapping[ids[j]].time()
+			if ti != tj {
+				return ti < tj
+			}
+			return ids[i] < ids[j]
+		})
+
+		nodes := make([]node, 0, len(ids))
+		for _, nodeID := range ids {
+			n := c.Mapping[nodeID]
+			nd := node{id: nodeID, parent: n.Parent}
+			if m := n.Message; m != nil {
+				if content := m.text(); content != "" {
+					at := unixTime(m.CreateTime)
+					if at.IsZero() {
+						at = created
+					}
+					nd.message = &db.Message{Role: m.Author.Role, Content: content, CreatedAt: at}
+				}
+				if m.Author.Role == "assistant" && m.Metadata.ModelSlug != "" {
+					session.Model = m.Metadata.ModelSlug
+				}
+			}
+			nodes = append(nodes, nd)
+		}
+
+		out = append(out, withBranches(id, session, branches(nodes, c.CurrentNode), opts)...)
+	}
+	return out, nil
+}
+
+func (n chatgptNode) time() float64 {
+	if n.Message == nil {
+		return 0
+	}
+	return n.Message.CreateTime
+}
+
+// text returns what the message says, or "" if it isn't imported: tool
+// calls and their results, hidden instructions and non-text content.
+func (m *chatgptMessage) text() string {
+	switch m.Author.Role {
+	case "user", "assistant", "system":
+	default:
+		return ""
+	}
+	if m.Metadata.Hidden {
+		return ""
+	}
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+	default:
+		return ""
+	}
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		// Images and other attachments are objects
+		var s string
+		if json.Unmarshal(raw, &s) == nil && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// unixTime converts ChatGPT's fractional Unix seconds, 0 for none.
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
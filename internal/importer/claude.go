@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// claudeProvider is the provider Claude.ai conversations are stored under.
+const claudeProvider = "claude"
+
+// claudeConversation is a conversation in the conversations.json of a
+// Claude.ai data export. Exports that keep edited and regenerated messages
+// link each message to its parent; older ones list a single branch.
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	Model        string          `json:"model"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	UUID              string `json:"uuid"`
+	ParentMessageUUID string `json:"parent_message_uuid"`
+	Sender            string `json:"sender"`
+	Text              string `json:"text"`
+	Content           []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func readClaude(r io.Reader, opts Options) ([]Conversation, error) {
+	var convs []claudeConversation
+	if err := json.NewDecoder(r).Decode(&convs); err != nil {
+		return nil, fmt.Errorf("failed to read Claude.ai export: %w", err)
+	}
+
+	var out []Conversation
+	for _, c := range convs {
+		if c.UUID == "" {
+			return nil, fmt.Errorf("Claude.ai conversation '%s' has no uuid", c.Name)
+		}
+		session := db.Session{
+			Title:     c.Name,
+			Provider:  claudeProvider,
+			Model:     c.Model,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+		}
+		if session.UpdatedAt.IsZero() {
+			session.UpdatedAt = c.CreatedAt
+		}
+
+		linked := false
+		for _, m := range c.ChatMessages {
+			if m.ParentMessageUUID != "" {
+				linked = true
+			}
+		}
+		nodes := make([]node, 0, len(c.ChatMessages))
+		for i, m := range c.ChatMessages {
+			nd := node{id: m.UUID, parent: m.ParentMessageUUID}
+			if nd.id == "" {
+				nd.id = strconv.Itoa(i)
+			}
+			if !linked && i > 0 {
+				nd.parent = nodes[i-1].id
+			}
+			if role, content := m.role(), m.text(); role != "" && content != "" {
+				at := m.CreatedAt
+				if at.IsZero() {
+					at = c.CreatedAt
+				}
+				nd.message = &db.Message{Role: role, Content: content, CreatedAt: at}
+			}
+			nodes = append(nodes, nd)
+		}
+
+		out = append(out, withBranches(c.UUID, session, branches(nodes, ""), opts)...)
+	}
+	return out, nil
+}
+
+func (m claudeMessage) role() string {
+	switch m.Sender {
+	case "human":
+		return "user"
+	case "assistant":
+		return "assistant"
+	}
+	return ""
+}
+
+// text returns the message's text blocks, leaving out tool use and
+// thinking, or its plain text in exports without blocks.
+func (m claudeMessage) text() string {
+	var parts []string
+	for _, block := range m.Content {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	if len(parts) == 0 {
+		return strings.TrimSpace(m.Text)
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
@@ -0,0 +1,204 @@
+// Package importer reads conversations from the data exports of other chat
+// apps, and from ai-tui's own JSON exports, into the database.
+package importer
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// Import sources
+const (
+	SourceChatGPT = "chatgpt"
+	SourceClaude  = "claude"
+	SourceJSON    = "json"
+)
+
+// Sources are the formats conversations can be imported from.
+var Sources = []string{SourceChatGPT, SourceClaude, SourceJSON}
+
+// Conversation is a session read from an export, ready to be stored.
+type Conversation struct {
+	// SourceID identifies the conversation in its export, so that importing
+	// the export again skips it
+	SourceID string
+	// ParentSourceID is the SourceID of the conversation a branch was
+	// forked from, "" for the conversation itself
+	ParentSourceID string
+	// Session is stored with a new ID unless it has one
+	Session  db.Session
+	Messages []db.Message
+}
+
+// Options control how exports are read.
+type Options struct {
+	// Branches imports each branch a conversation was edited or regenerated
+	// into as a fork of it; otherwise only the branch last shown is imported
+	Branches bool
+}
+
+// Read reads the conversations in an export from source.
+func Read(source string, r io.Reader, opts Options) ([]Conversation, error) {
+	switch source {
+	case SourceChatGPT:
+		return readChatGPT(r, opts)
+	case SourceClaude:
+		return readClaude(r, opts)
+	case SourceJSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("unknown import source '%s' (want %s)", source, strings.Join(Sources, ", "))
+}
+
+// ReadFile reads the conversations in the export file at path. ChatGPT and
+// Claude.ai data exports are zip archives, whose conversations.json is read;
+// it can also be given on its own.
+func ReadFile(source, path string, opts Options) ([]Conversation, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return readZip(source, path, opts)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer f.Close()
+	return Read(source, f, opts)
+}
+
+func readZip(source, name string, opts Options) ([]Conversation, error) {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if path.Base(f.Name) != "conversations.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		defer rc.Close()
+		return Read(source, rc, opts)
+	}
+	return nil, fmt.Errorf("no conversations.json in %s", name)
+}
+
+// Store is where conversations are imported to. *db.DB implements it.
+type Store interface {
+	GetSession(id string) (*db.Session, error)
+	ImportedSession(source, sourceID string) (string, error)
+	ImportSession(source, sourceID string, s *db.Session, messages []db.Message) error
+}
+
+// Summary counts what an import did.
+type Summary struct {
+	Imported int // conversations stored
+	Messages int // messages in them
+	Existing int // conversations skipped as imported before
+	Empty    int // conversations skipped for having no messages
+}
+
+func (s Summary) String() string {
+	out := fmt.Sprintf("imported %s (%s)", plural(s.Imported, "conversation"), plural(s.Messages, "message"))
+	var skipped []string
+	if s.Existing > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d already imported", s.Existing))
+	}
+	if s.Empty > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d empty", s.Empty))
+	}
+	if len(skipped) > 0 {
+		out += ", skipped " + strings.Join(skipped, " and ")
+	}
+	return out
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Import stores conversations read from source, skipping those imported
+// before and those already in the database under their own ID. Branches
+// are linked to the session their conversation was imported as.
+func Import(store Store, source string, convs []Conversation) (Summary, error) {
+	var sum Summary
+	for _, c := range convs {
+		if len(c.Messages) == 0 {
+			sum.Empty++
+			continue
+		}
+		existing, err := store.ImportedSession(source, c.SourceID)
+		if err != nil {
+			return sum, err
+		}
+		if existing != "" {
+			sum.Existing++
+			continue
+		}
+
+		session := c.Session
+		if session.ID == "" {
+			session.ID = newUUID()
+		} else if _, err := store.GetSession(session.ID); err == nil {
+			// e.g. a JSON export of a session in this database
+			sum.Existing++
+			continue
+		}
+		if c.ParentSourceID != "" {
+			parentID, err := store.ImportedSession(source, c.ParentSourceID)
+			if err != nil {
+				return sum, err
+			}
+			session.ParentID = parentID
+		}
+
+		if err := store.ImportSession(source, c.SourceID, &session, c.Messages); err != nil {
+			return sum, fmt.Errorf("failed to import '%s': %w", session.Title, err)
+		}
+		sum.Imported++
+		sum.Messages += len(c.Messages)
+	}
+	return sum, nil
+}
+
+// withBranches makes the conversations of an export's conversation: its
+// first branch, then, with opts.Branches, a fork for each other branch.
+func withBranches(sourceID string, session db.Session, bs []branch, opts Options) []Conversation {
+	if len(bs) == 0 {
+		return []Conversation{{SourceID: sourceID, Session: session}}
+	}
+	out := []Conversation{{SourceID: sourceID, Session: session, Messages: bs[0].messages}}
+	if !opts.Branches {
+		return out
+	}
+	for i, b := range bs[1:] {
+		fork := session
+		fork.Title = fmt.Sprintf("%s (branch %d)", session.Title, i+2)
+		out = append(out, Conversation{
+			SourceID:       sourceID + "/" + b.leaf,
+			ParentSourceID: sourceID,
+			Session:        fork,
+			Messages:       b.messages,
+		})
+	}
+	return out
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
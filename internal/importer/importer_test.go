@@ -0,0 +1,318 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
+)
+
+// chatgptExport has one conversation whose first answer was regenerated,
+// and which holds a hidden system message and a tool call, plus an empty
+// conversation.
+const chatgptExport = `[
+  {
+    "id": "conv-1",
+    "conversation_id": "conv-1",
+    "title": "Sorting in Go",
+    "create_time": 1740823200.5,
+    "update_time": 1740823500.0,
+    "current_node": "a2",
+    "default_model_slug": "gpt-4o",
+    "mapping": {
+      "root": {"id": "root", "message": null, "parent": null, "children": ["sys"]},
+      "sys": {"id": "sys", "parent": "root", "children": ["u1"], "message": {
+        "author": {"role": "system"}, "create_time": null,
+        "content": {"content_type": "text", "parts": [""]},
+        "metadata": {"is_visually_hidden_from_conversation": true}}},
+      "u1": {"id": "u1", "parent": "sys", "children": ["a1", "tool", "a2"], "message": {
+        "author": {"role": "user"}, "create_time": 1740823210,
+        "content": {"content_type": "text", "parts": ["How do I sort a slice?"]},
+        "metadata": {}}},
+      "a1": {"id": "a1", "parent": "u1", "children": [], "message": {
+        "author": {"role": "assistant"}, "create_time": 1740823220,
+        "content": {"content_type": "text", "parts": ["Use sort.Slice."]},
+        "metadata": {"model_slug": "gpt-4o"}}},
+      "tool": {"id": "tool", "parent": "u1", "children": [], "message": {
+        "author": {"role": "tool"}, "create_time": 1740823225,
+        "content": {"content_type": "execution_output", "text": "ok"},
+        "metadata": {}}},
+      "a2": {"id": "a2", "parent": "u1", "children": [], "message": {
+        "author": {"role": "assistant"}, "create_time": 1740823230,
+        "content": {"content_type": "multimodal_text", "parts": [{"asset_pointer": "file-1"}, "Use slices.Sort."]},
+        "metadata": {"model_slug": "o3"}}}
+    }
+  },
+  {
+    "id": "conv-2",
+    "title": "Nothing",
+    "create_time": 1740823200,
+    "current_node": "root",
+    "mapping": {"root": {"id": "root", "message": null, "parent": null, "children": []}}
+  }
+]`
+
+// claudeExport has a linear conversation from an older export and one
+// whose answer was regenerated.
+const claudeExport = `[
+  {
+    "uuid": "c-1",
+    "name": "Haiku",
+    "created_at": "2025-03-01T10:00:00.000000Z",
+    "updated_at": "2025-03-01T10:05:00.000000Z",
+    "chat_messages": [
+      {"uuid": "m1", "sender": "human", "text": "Write a haiku", "content": [], "created_at": "2025-03-01T10:00:01.000000Z"},
+      {"uuid": "m2", "sender": "assistant", "text": "", "content": [
+        {"type": "thinking", "thinking": "hmm"},
+        {"type": "text", "text": "Autumn moonlight"}
+      ], "created_at": "2025-03-01T10:00:05.000000Z"}
+    ]
+  },
+  {
+    "uuid": "c-2",
+    "name": "Retry",
+    "created_at": "2025-03-02T10:00:00Z",
+    "updated_at": "2025-03-02T10:05:00Z",
+    "chat_messages": [
+      {"uuid": "n1", "parent_message_uuid": "00000000-0000-4000-8000-000000000000", "sender": "human", "text": "Hi", "created_at": "2025-03-02T10:00:01Z"},
+      {"uuid": "n2", "parent_message_uuid": "n1", "sender": "assistant", "text": "Hello", "created_at": "2025-03-02T10:00:02Z"},
+      {"uuid": "n3", "parent_message_uuid": "n1", "sender": "assistant", "text": "Hey there", "created_at": "2025-03-02T10:00:03Z"}
+    ]
+  }
+]`
+
+func contents(messages []db.Message) string {
+	var parts []string
+	for _, m := range messages {
+		parts = append(parts, m.Role+": "+m.Content)
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestReadChatGPT_CurrentBranch(t *testing.T) {
+	convs, err := Read(SourceChatGPT, strings.NewReader(chatgptExport), Options{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(convs) != 2 {
+		t.Fatalf("expected 2 conversations, got %d", len(convs))
+	}
+
+	c := convs[0]
+	if c.SourceID != "conv-1" || c.Session.ID != "" {
+		t.Errorf("SourceID = %q, ID = %q; want conv-1 and a new ID", c.SourceID, c.Session.ID)
+	}
+	s := c.Session
+	if s.Title != "Sorting in Go" || s.Provider != "openai" || s.Model != "o3" {
+		t.Errorf("session = %q %q %q, want the title, openai and the newest model", s.Title, s.Provider, s.Model)
+	}
+	if want := time.Unix(1740823200, 5e8); !s.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", s.CreatedAt, want)
+	}
+	if want := time.Unix(1740823500, 0); !s.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", s.UpdatedAt, want)
+	}
+
+	// The branch shown in ChatGPT, without the hidden system message, the
+	// tool call or the image
+	if got, want := contents(c.Messages), "user: How do I sort a slice? | assistant: Use slices.Sort."; got != want {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if want := time.Unix(1740823230, 0); !c.Messages[1].CreatedAt.Equal(want) {
+		t.Errorf("message CreatedAt = %v, want %v", c.Messages[1].CreatedAt, want)
+	}
+
+	if len(convs[1].Messages) != 0 {
+		t.Errorf("expected the empty conversation to have no messages, got %d", len(convs[1].Messages))
+	}
+}
+
+func TestReadChatGPT_Branches(t *testing.T) {
+	convs, err := Read(SourceChatGPT, strings.NewReader(chatgptExport), Options{Branches: true})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	// The tool call's branch reads as the start of the others, so it isn't
+	// a branch of its own
+	if len(convs) != 3 {
+		t.Fatalf("expected the conversation, one branch and the empty one, got %d", len(convs))
+	}
+	b := convs[1]
+	if b.SourceID != "conv-1/a1" || b.ParentSourceID != "conv-1" {
+		t.Errorf("branch SourceID = %q, ParentSourceID = %q", b.SourceID, b.ParentSourceID)
+	}
+	if b.Session.Title != "Sorting in Go (branch 2)" {
+		t.Errorf("branch title = %q", b.Session.Title)
+	}
+	if got, want := contents(b.Messages), "user: How do I sort a slice? | assistant: Use sort.Slice."; got != want {
+		t.Errorf("branch messages = %q, want %q", got, want)
+	}
+}
+
+func TestReadClaude(t *testing.T) {
+	convs, err := Read(SourceClaude, strings.NewReader(claudeExport), Options{Branches: true})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(convs) != 3 {
+		t.Fatalf("expected 2 conversations and a branch, got %d", len(convs))
+	}
+
+	c := convs[0]
+	if c.SourceID != "c-1" || c.Session.Title != "Haiku" || c.Session.Provider != "claude" {
+		t.Errorf("session = %+v", c.Session)
+	}
+	if want := time.Date(2025, 3, 1, 10, 5, 0, 0, time.UTC); !c.Session.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", c.Session.UpdatedAt, want)
+	}
+	// Thinking is left out
+	if got, want := contents(c.Messages), "user: Write a haiku | assistant: Autumn moonlight"; got != want {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	// Without a current branch, the newest answer is the conversation
+	if got, want := contents(convs[1].Messages), "user: Hi | assistant: Hey there"; got != want {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if got, want := contents(convs[2].Messages), "user: Hi | assistant: Hello"; got != want {
+		t.Errorf("branch messages = %q, want %q", got, want)
+	}
+	if convs[2].ParentSourceID != "c-2" {
+		t.Errorf("branch ParentSourceID = %q, want c-2", convs[2].ParentSourceID)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	if _, err := Read("bard", strings.NewReader("[]"), Options{}); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+	if _, err := Read(SourceChatGPT, strings.NewReader("{"), Options{}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+	if _, err := Read(SourceClaude, strings.NewReader(`[{"name": "x"}]`), Options{}); err == nil {
+		t.Error("expected an error for a conversation without an id")
+	}
+}
+
+func TestReadFile_Zip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("data/conversations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(claudeExport))
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	convs, err := ReadFile(SourceClaude, path, Options{})
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(convs) != 2 {
+		t.Errorf("expected 2 conversations, got %d", len(convs))
+	}
+}
+
+func TestImport_SkipsWhatWasImported(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	convs, err := Read(SourceChatGPT, strings.NewReader(chatgptExport), Options{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	sum, err := Import(database, SourceChatGPT, convs)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if sum != (Summary{Imported: 1, Messages: 2, Empty: 1}) {
+		t.Errorf("summary = %+v", sum)
+	}
+	if got, want := sum.String(), "imported 1 conversation (2 messages), skipped 1 empty"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// Importing again with branches adds only the branch, as a fork of the
+	// session imported the first time
+	convs, err = Read(SourceChatGPT, strings.NewReader(chatgptExport), Options{Branches: true})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	sum, err = Import(database, SourceChatGPT, convs)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if sum != (Summary{Imported: 1, Messages: 2, Existing: 1, Empty: 1}) {
+		t.Errorf("summary = %+v", sum)
+	}
+
+	parentID, _ := database.ImportedSession(SourceChatGPT, "conv-1")
+	parent, err := database.GetSession(parentID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if parent.Title != "Sorting in Go" || parent.Model != "o3" {
+		t.Errorf("imported session = %+v", parent)
+	}
+	forks, err := database.ListForks(parentID)
+	if err != nil || len(forks) != 1 || forks[0].Title != "Sorting in Go (branch 2)" {
+		t.Errorf("forks = %+v, %v; want the branch", forks, err)
+	}
+	messages, _ := database.GetSessionMessages(parentID)
+	if want := time.Unix(1740823210, 0); len(messages) != 2 || !messages[0].CreatedAt.Equal(want) {
+		t.Errorf("messages = %+v, want the first at %v", messages, want)
+	}
+}
+
+func TestImport_JSONExport(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Date(2026, 9, 14, 9, 0, 0, 0, time.UTC)
+	session := db.Session{ID: "s1", Title: "Mine", Provider: "claude", CreatedAt: now, UpdatedAt: now, Tags: []string{"work"}}
+	messages := []db.Message{{Role: "user", Content: "Hi", CreatedAt: now}}
+	var buf bytes.Buffer
+	if err := (export.JSON{}).Write(&buf, session, messages); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	doc := buf.String()
+
+	convs, err := Read(SourceJSON, strings.NewReader(doc), Options{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	sum, err := Import(database, SourceJSON, convs)
+	if err != nil || sum.Imported != 1 {
+		t.Fatalf("Import = %+v, %v; want 1 imported", sum, err)
+	}
+	got, err := database.GetSession("s1")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Title != "Mine" || len(got.Tags) != 1 || got.Tags[0] != "work" {
+		t.Errorf("imported session = %+v", got)
+	}
+
+	// The session is already here, so importing it again skips it
+	convs, _ = Read(SourceJSON, strings.NewReader(doc), Options{})
+	sum, err = Import(database, SourceJSON, convs)
+	if err != nil || sum != (Summary{Existing: 1}) {
+		t.Errorf("second Import = %+v, %v; want 1 skipped", sum, err)
+	}
+}
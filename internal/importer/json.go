@@ -0,0 +1,17 @@
+package importer
+
+import (
+	"io"
+
+	"github.com/mg/ai-tui/internal/export"
+)
+
+// readJSON reads a session written by the JSON exporter. It keeps its ID, so
+// a session exported from this database is skipped rather than copied.
+func readJSON(r io.Reader) ([]Conversation, error) {
+	session, messages, err := export.ReadJSON(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Conversation{{SourceID: session.ID, Session: session, Messages: messages}}, nil
+}
@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"strings"
+
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// node is a message in a conversation tree. ChatGPT and Claude.ai keep
+// edited and regenerated messages as siblings under the same parent.
+type node struct {
+	id     string
+	parent string
+	// message is nil for nodes that aren't imported, such as tool calls
+	message *db.Message
+}
+
+// branch is the messages on the path from the root of a tree to a leaf.
+type branch struct {
+	leaf     string
+	messages []db.Message
+}
+
+// branches returns the branches of a tree, given oldest first. The branch
+// through current comes first, or without it the one to the newest leaf.
+// Branches that differ only in nodes that aren't imported, and so read the
+// same as an earlier one or as the start of another, are left out.
+func branches(nodes []node, current string) []branch {
+	byID := make(map[string]*node, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].id] = &nodes[i]
+	}
+	hasChildren := make(map[string]bool)
+	for _, n := range nodes {
+		if _, ok := byID[n.parent]; ok {
+			hasChildren[n.parent] = true
+		}
+	}
+
+	var leaves []string
+	if _, ok := byID[current]; ok {
+		leaves = append(leaves, current)
+	} else {
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if !hasChildren[nodes[i].id] {
+				leaves = append(leaves, nodes[i].id)
+				break
+			}
+		}
+	}
+	for _, n := range nodes {
+		if !hasChildren[n.id] && (len(leaves) == 0 || n.id != leaves[0]) {
+			leaves = append(leaves, n.id)
+		}
+	}
+
+	var all []branch
+	for _, leaf := range leaves {
+		if messages := pathTo(byID, leaf); len(messages) > 0 {
+			all = append(all, branch{leaf: leaf, messages: messages})
+		}
+	}
+	keys := make([]string, len(all))
+	for i, b := range all {
+		keys[i] = branchKey(b.messages)
+	}
+
+	var out []branch
+	for i, b := range all {
+		if i == 0 || !redundant(keys, i) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// redundant reports whether branch i reads the same as an earlier branch,
+// or as the start of another one.
+func redundant(keys []string, i int) bool {
+	for j, key := range keys {
+		if j < i && key == keys[i] || j != i && len(key) > len(keys[i]) && strings.HasPrefix(key, keys[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTo returns the messages from the root of the tree to leaf.
+func pathTo(byID map[string]*node, leaf string) []db.Message {
+	var messages []db.Message
+	visited := make(map[string]bool)
+	for n, ok := byID[leaf]; ok && !visited[n.id]; n, ok = byID[n.parent] {
+		visited[n.id] = true
+		if n.message != nil {
+			messages = append(messages, *n.message)
+		}
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages
+}
+
+func branchKey(messages []db.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString(m.Role)
+		b.WriteByte(0)
+		b.WriteString(m.Content)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyCmdTimeout bounds how long api_key_cmd may take, e.g. to unlock a
+// password store
+const keyCmdTimeout = 30 * time.Second
+
+// keyCommand runs a provider's api_key_cmd on the first request and keeps
+// the key it prints for later ones. A failed run is tried again next time.
+type keyCommand struct {
+	args []string
+	mu   sync.Mutex
+	key  string
+}
+
+func newKeyCommand(args []string) *keyCommand {
+	if len(args) == 0 {
+		return nil
+	}
+	return &keyCommand{args: args}
+}
+
+// get returns the key, running the command if it hasn't printed one yet.
+// The key is the first line of its output, as printed by e.g. "pass show".
+func (c *keyCommand) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key != "" {
+		return c.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, keyCmdTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("api_key_cmd timed out after %s", keyCmdTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("api_key_cmd failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("api_key_cmd failed: %w", err)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return "", fmt.Errorf("api_key_cmd printed no key")
+	}
+	c.key = key
+	return key, nil
+}
+
+// resolveKey returns the API key to send: the output of the key command if
+// there is one, otherwise the configured key. keyErr is why the configured
+// key couldn't be resolved, returned rather than sending the request.
+func resolveKey(ctx context.Context, key string, cmd *keyCommand, keyErr error) (string, error) {
+	if keyErr != nil {
+		return "", keyErr
+	}
+	if cmd != nil {
+		return cmd.get(ctx)
+	}
+	return key, nil
+}
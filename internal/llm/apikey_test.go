@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyCommandRunsOnce(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+	cmd := newKeyCommand([]string{"sh", "-c", "echo run >> " + runs + "; printf 'sk-secret\\nlogin: me\\n'"})
+
+	for i := 0; i < 2; i++ {
+		key, err := cmd.get(context.Background())
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if key != "sk-secret" {
+			t.Errorf("key = %q, want the first line of the output", key)
+		}
+	}
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatalf("failed to read runs: %v", err)
+	}
+	if n := strings.Count(string(data), "run"); n != 1 {
+		t.Errorf("command ran %d times, want once", n)
+	}
+}
+
+func TestKeyCommandErrors(t *testing.T) {
+	cmd := newKeyCommand([]string{"sh", "-c", "echo 'store is locked' >&2; exit 1"})
+	if _, err := cmd.get(context.Background()); err == nil || !strings.Contains(err.Error(), "store is locked") {
+		t.Errorf("get error = %v, want the command's message", err)
+	}
+
+	cmd = newKeyCommand([]string{"true"})
+	if _, err := cmd.get(context.Background()); err == nil || !strings.Contains(err.Error(), "printed no key") {
+		t.Errorf("get error = %v, want no key", err)
+	}
+
+	if newKeyCommand(nil) != nil {
+		t.Error("expected no key command without arguments")
+	}
+}
+
+func TestStreamUsesKeyCommand(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	p := &openaiProvider{
+		name:    "test",
+		keyCmd:  newKeyCommand([]string{"echo", "sk-from-cmd"}),
+		baseURL: server.URL,
+		model:   "gpt-4",
+		client:  &http.Client{},
+	}
+	ch, err := p.Stream(context.Background(), []ChatMessage{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	for range ch {
+	}
+	if auth != "Bearer sk-from-cmd" {
+		t.Errorf("Authorization = %q, want the command's key", auth)
+	}
+}
+
+func TestStreamUnresolvedKey(t *testing.T) {
+	p := &claudeProvider{
+		name:   "claude",
+		apiKey: "$ANTHROPIC_API_KEY",
+		keyErr: errors.New("api_key: environment variable ANTHROPIC_API_KEY is not set"),
+		client: &http.Client{},
+	}
+	_, err := p.Stream(context.Background(), []ChatMessage{{Role: "user", Content: "Hi"}})
+	if err == nil || err.Error() != "provider claude: api_key: environment variable ANTHROPIC_API_KEY is not set" {
+		t.Errorf("Stream error = %v, want the unresolved key", err)
+	}
+}
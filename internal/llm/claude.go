@@ -12,6 +12,8 @@ import (
 type claudeProvider struct {
 	name         string
 	apiKey       string
+	keyCmd       *keyCommand // runs api_key_cmd, nil without one
+	keyErr       error       // why the configured key couldn't be resolved
 	baseURL      string
 	model        string
 	systemPrompt string
@@ -24,15 +26,35 @@ func (p *claudeProvider) Name() string {
 }
 
 func (p *claudeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-chan StreamChunk, error) {
+	// The Messages API only accepts a top-level system prompt, so system
+	// messages from the conversation are appended to the configured one
+	system := p.systemPrompt
+	chatMessages := make([]ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == "system" {
+			if system != "" {
+				system += "\n\n"
+			}
+			system += m.Content
+			continue
+		}
+		chatMessages = append(chatMessages, m)
+	}
+
 	// Build request body
 	reqBody := map[string]interface{}{
 		"model":      p.model,
 		"max_tokens": p.maxTokens,
 		"stream":     true,
-		"messages":   messages,
+		"messages":   chatMessages,
 	}
-	if p.systemPrompt != "" {
-		reqBody["system"] = p.systemPrompt
+	if system != "" {
+		reqBody["system"] = system
+	}
+
+	apiKey, err := resolveKey(ctx, p.apiKey, p.keyCmd, p.keyErr)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", p.name, err)
 	}
 
 	bodyBytes, err := json.Marshal(reqBody)
@@ -47,7 +69,7 @@ func (p *claudeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 	req.Header.Set("content-type", "application/json")
 
@@ -71,6 +93,10 @@ func (p *claudeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 		defer close(ch)
 		defer resp.Body.Close()
 
+		// Usage arrives in message_start (input) and message_delta (output)
+		// and is passed on with the final chunk
+		var usage Usage
+
 		// Use ParseSSE to handle the SSE stream
 		sseChannel := ParseSSE(ctx, resp.Body, func(data []byte) (StreamChunk, bool) {
 			// Parse the JSON data
@@ -97,8 +123,40 @@ func (p *claudeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 				}
 				return StreamChunk{Content: text, Done: false}, false
 
+			case "message_start":
+				var start struct {
+					Message struct {
+						Usage struct {
+							InputTokens              int `json:"input_tokens"`
+							OutputTokens             int `json:"output_tokens"`
+							CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+							CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+						} `json:"usage"`
+					} `json:"message"`
+				}
+				if err := json.Unmarshal(data, &start); err == nil {
+					u := start.Message.Usage
+					usage.InputTokens = u.InputTokens
+					usage.OutputTokens = u.OutputTokens
+					usage.CacheWriteTokens = u.CacheCreationInputTokens
+					usage.CacheReadTokens = u.CacheReadInputTokens
+				}
+				return StreamChunk{}, false
+
+			case "message_delta":
+				var delta struct {
+					Usage struct {
+						OutputTokens int `json:"output_tokens"`
+					} `json:"usage"`
+				}
+				if err := json.Unmarshal(data, &delta); err == nil && delta.Usage.OutputTokens > 0 {
+					usage.OutputTokens = delta.Usage.OutputTokens
+				}
+				return StreamChunk{}, false
+
 			case "message_stop":
-				return StreamChunk{Done: true}, true
+				final := usage
+				return StreamChunk{Done: true, Usage: &final}, true
 
 			case "error":
 				// Extract error information
@@ -111,7 +169,7 @@ func (p *claudeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 				return StreamChunk{Error: fmt.Errorf("API error: %s", errMsg)}, true
 
 			default:
-				// Ignore other event types (message_start, content_block_start, etc.)
+				// Ignore other event types (content_block_start, ping, etc.)
 				return StreamChunk{}, false
 			}
 		})
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -109,6 +110,10 @@ data: {"type":"message_stop"}
 	if lastChunk.Error != nil {
 		t.Errorf("last chunk: unexpected error: %v", lastChunk.Error)
 	}
+	// Input tokens come from message_start, output tokens from message_delta
+	if lastChunk.Usage == nil || *lastChunk.Usage != (Usage{InputTokens: 10, OutputTokens: 5}) {
+		t.Errorf("last chunk: expected usage of 10 input and 5 output tokens, got %+v", lastChunk.Usage)
+	}
 }
 
 func TestClaudeStream_ErrorResponse(t *testing.T) {
@@ -321,3 +326,48 @@ data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}
 		t.Error("expected channel to close after error")
 	}
 }
+
+func TestClaudeStream_SystemMessages(t *testing.T) {
+	var reqBody struct {
+		System   string        `json:"system"`
+		Messages []ChatMessage `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("data: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	provider := &claudeProvider{
+		name:         "test-claude",
+		apiKey:       "test-key",
+		baseURL:      server.URL,
+		model:        "claude-3-5-sonnet-20241022",
+		systemPrompt: "Be concise.",
+		maxTokens:    1024,
+		client:       &http.Client{},
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "Answer in French."},
+		{Role: "user", Content: "Hello"},
+	}
+	ch, err := provider.Stream(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("Stream() error: %v", err)
+	}
+	for range ch {
+	}
+
+	if reqBody.System != "Be concise.\n\nAnswer in French." {
+		t.Errorf("expected system messages appended to system prompt, got %q", reqBody.System)
+	}
+	if len(reqBody.Messages) != 1 || reqBody.Messages[0].Role != "user" {
+		t.Errorf("expected only the user message in messages, got %+v", reqBody.Messages)
+	}
+}
@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is the rough average used to estimate token counts. It errs
+// on the high side for English prose and code, which is the safe direction.
+const charsPerToken = 4
+
+// messageOverhead accounts for the role and framing tokens of each message.
+const messageOverhead = 4
+
+// defaultContextWindow is used for models not listed in contextWindows.
+const defaultContextWindow = 8192
+
+// contextWindows maps model name prefixes to context window sizes in tokens.
+// More specific prefixes come first.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude", 200000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"llama3", 8192},
+	{"mistral", 32768},
+}
+
+// EstimateTokens returns an approximate token count for text.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessages returns an approximate token count for a conversation.
+func EstimateMessages(messages []ChatMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m.Content) + messageOverhead
+	}
+	return total
+}
+
+// ContextWindow returns the context window size for model. A configured size
+// greater than zero takes precedence over the built-in table.
+func ContextWindow(model string, configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	model = strings.ToLower(model)
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// Fit drops the oldest messages until the conversation's estimate is within
+// budget, returning the remaining messages and how many were dropped. System
+// messages and the final message are always kept, and the conversation is
+// never left starting with an assistant reply.
+func Fit(messages []ChatMessage, budget int) ([]ChatMessage, int) {
+	total := EstimateMessages(messages)
+	if total <= budget {
+		return messages, 0
+	}
+
+	drop := make([]bool, len(messages))
+	dropped := 0
+	for i := 0; i < len(messages)-1 && total > budget; i++ {
+		if messages[i].Role == "system" {
+			continue
+		}
+		drop[i] = true
+		dropped++
+		total -= EstimateTokens(messages[i].Content) + messageOverhead
+	}
+
+	// Drop a leading reply whose prompt is gone
+	for i := 0; i < len(messages)-1; i++ {
+		if drop[i] || messages[i].Role == "system" {
+			continue
+		}
+		if messages[i].Role == "assistant" {
+			drop[i] = true
+			dropped++
+		}
+		break
+	}
+
+	kept := make([]ChatMessage, 0, len(messages)-dropped)
+	for i, m := range messages {
+		if !drop[i] {
+			kept = append(kept, m)
+		}
+	}
+	return kept, dropped
+}
+
+// Complete sends messages to p and returns the full response text, with the
+// usage the API reported for it, or nil when it reported none.
+func Complete(ctx context.Context, p Provider, messages []ChatMessage) (string, *Usage, error) {
+	ch, err := p.Stream(ctx, messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	var usage *Usage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			return "", nil, chunk.Error
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		sb.WriteString(chunk.Content)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	return sb.String(), usage, nil
+}
+
+// summaryPrompt instructs the model that condenses older turns.
+const summaryPrompt = "Summarize the conversation below so it can replace the original messages as context for continuing it. " +
+	"Keep facts, decisions, names, code identifiers and open questions. Write plain prose without preamble."
+
+// Summarize asks p to condense a conversation into a short summary. The
+// usage is the one reported by the API, or nil.
+func Summarize(ctx context.Context, p Provider, messages []ChatMessage) (string, *Usage, error) {
+	var transcript strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", m.Role, m.Content)
+	}
+
+	summary, usage, err := Complete(ctx, p, []ChatMessage{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: transcript.String()},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	return strings.TrimSpace(summary), usage, nil
+}
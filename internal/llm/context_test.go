@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeProvider streams a fixed response and records the messages it was sent.
+type fakeProvider struct {
+	chunks []StreamChunk
+	got    []ChatMessage
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Stream(ctx context.Context, messages []ChatMessage) (<-chan StreamChunk, error) {
+	p.got = messages
+	ch := make(chan StreamChunk, len(p.chunks))
+	for _, c := range p.chunks {
+		ch <- c
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("EstimateTokens(\"\") = %d, want 0", got)
+	}
+	if got := EstimateTokens("abcde"); got != 2 {
+		t.Errorf("EstimateTokens(\"abcde\") = %d, want 2", got)
+	}
+	// Runes, not bytes
+	if got := EstimateTokens("日本語です"); got != 2 {
+		t.Errorf("EstimateTokens of 5 runes = %d, want 2", got)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model      string
+		configured int
+		want       int
+	}{
+		{"claude-sonnet-4-20250514", 0, 200000},
+		{"gpt-4o-mini", 0, 128000},
+		{"gpt-4", 0, 8192},
+		{"unknown-model", 0, defaultContextWindow},
+		{"gpt-4o", 32000, 32000},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.model, tt.configured); got != tt.want {
+			t.Errorf("ContextWindow(%q, %d) = %d, want %d", tt.model, tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	long := strings.Repeat("x", 400) // 100 tokens
+	messages := []ChatMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "q"},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "last"},
+	}
+
+	// Dropping the first prompt is enough, but its reply goes with it
+	kept, dropped := Fit(messages, 230)
+	if dropped != 2 {
+		t.Fatalf("expected 2 messages dropped, got %d", dropped)
+	}
+	if kept[0].Role != "system" {
+		t.Error("system message should be kept")
+	}
+	if kept[1].Content != "q" {
+		t.Errorf("conversation should start with the next prompt, got %q", kept[1].Content)
+	}
+	if kept[len(kept)-1].Content != "last" {
+		t.Error("final message should be kept")
+	}
+
+	if _, dropped := Fit(messages, 10000); dropped != 0 {
+		t.Errorf("expected nothing dropped within budget, got %d", dropped)
+	}
+
+	// The final message is kept even when it alone exceeds the budget
+	kept, _ = Fit([]ChatMessage{{Role: "user", Content: long}}, 1)
+	if len(kept) != 1 {
+		t.Errorf("expected final message kept, got %d messages", len(kept))
+	}
+}
+
+func TestComplete(t *testing.T) {
+	p := &fakeProvider{chunks: []StreamChunk{
+		{Content: "Hello"},
+		{Content: " world", Usage: &Usage{InputTokens: 12, OutputTokens: 2}},
+		{Done: true},
+	}}
+	got, usage, err := Complete(context.Background(), p, []ChatMessage{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+	if got != "Hello world" {
+		t.Errorf("Complete() = %q, want %q", got, "Hello world")
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 2 {
+		t.Errorf("Complete() usage = %+v, want the reported one", usage)
+	}
+
+	p = &fakeProvider{chunks: []StreamChunk{{Content: "partial"}, {Error: errors.New("boom")}}}
+	if _, _, err := Complete(context.Background(), p, nil); err == nil {
+		t.Error("expected stream error to be returned")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	p := &fakeProvider{chunks: []StreamChunk{{Content: "  They said hi.  "}, {Done: true}}}
+	summary, _, err := Summarize(context.Background(), p, []ChatMessage{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Summarize() error: %v", err)
+	}
+	if summary != "They said hi." {
+		t.Errorf("Summarize() = %q", summary)
+	}
+	if len(p.got) != 2 || p.got[0].Role != "system" || !strings.Contains(p.got[1].Content, "assistant: hello") {
+		t.Errorf("unexpected summary request: %+v", p.got)
+	}
+}
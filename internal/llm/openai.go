@@ -12,10 +12,13 @@ import (
 type openaiProvider struct {
 	name         string
 	apiKey       string
+	keyCmd       *keyCommand // runs api_key_cmd, nil without one
+	keyErr       error       // why the configured key couldn't be resolved
 	baseURL      string
 	model        string
 	systemPrompt string
 	maxTokens    int
+	streamUsage  bool // ask for the usage chunk with stream_options
 	client       *http.Client
 }
 
@@ -44,7 +47,16 @@ func (p *openaiProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 		"stream":     true,
 		"messages":   reqMessages,
 	}
+	if p.streamUsage {
+		// Ask for a final chunk with the token usage
+		reqBody["stream_options"] = map[string]bool{"include_usage": true}
+	}
 	
+	apiKey, err := resolveKey(ctx, p.apiKey, p.keyCmd, p.keyErr)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", p.name, err)
+	}
+
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -57,7 +69,7 @@ func (p *openaiProvider) Stream(ctx context.Context, messages []ChatMessage) (<-
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 	
 	// Send the request
@@ -92,12 +104,30 @@ func (p *openaiProvider) parseChunk(data []byte) (StreamChunk, bool) {
 			} `json:"delta"`
 			FinishReason *string `json:"finish_reason"`
 		} `json:"choices"`
+		Usage *struct {
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+		} `json:"usage"`
 	}
 	
 	if err := json.Unmarshal(data, &response); err != nil {
 		return StreamChunk{Error: fmt.Errorf("failed to parse chunk: %w", err)}, true
 	}
 	
+	// The usage chunk comes last, with no choices
+	var usage *Usage
+	if u := response.Usage; u != nil {
+		// Cached prompt tokens are part of the prompt count
+		usage = &Usage{
+			InputTokens:     u.PromptTokens - u.PromptTokensDetails.CachedTokens,
+			OutputTokens:    u.CompletionTokens,
+			CacheReadTokens: u.PromptTokensDetails.CachedTokens,
+		}
+	}
+
 	// Extract content from the first choice
 	if len(response.Choices) > 0 {
 		content := response.Choices[0].Delta.Content
@@ -105,12 +135,12 @@ func (p *openaiProvider) parseChunk(data []byte) (StreamChunk, bool) {
 		
 		// If we have a finish_reason, this is the last content chunk
 		if finishReason != nil && *finishReason != "" {
-			return StreamChunk{Content: content, Done: false}, false
+			return StreamChunk{Content: content, Done: false, Usage: usage}, false
 		}
 		
-		return StreamChunk{Content: content}, false
+		return StreamChunk{Content: content, Usage: usage}, false
 	}
 	
-	// Empty chunk
-	return StreamChunk{}, false
+	// Empty chunk, or the usage chunk
+	return StreamChunk{Usage: usage}, false
 }
@@ -96,6 +96,78 @@ func TestOpenAIStream_Normal(t *testing.T) {
 	}
 }
 
+func TestOpenAIStream_NoStreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if _, ok := body["stream_options"]; ok {
+			t.Error("expected no stream_options without stream_usage")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := &openaiProvider{name: "local", baseURL: server.URL, model: "llama3", client: &http.Client{}}
+	ch, err := provider.Stream(context.Background(), []ChatMessage{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	for range ch {
+	}
+}
+
+func TestOpenAIStream_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if !body.StreamOptions.IncludeUsage {
+			t.Error("expected the request to ask for usage")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\n"))
+		w.Write([]byte(`data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":120,"completion_tokens":7,"prompt_tokens_details":{"cached_tokens":100}}}` + "\n\n"))
+		w.Write([]byte(`data: [DONE]` + "\n\n"))
+	}))
+	defer server.Close()
+
+	provider := &openaiProvider{
+		name:        "test",
+		apiKey:      "test-key",
+		baseURL:     server.URL,
+		model:       "gpt-4o",
+		maxTokens:   4096,
+		streamUsage: true,
+		client:      &http.Client{},
+	}
+	ch, err := provider.Stream(context.Background(), []ChatMessage{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var usage *Usage
+	for chunk := range ch {
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	// Cached tokens are split out of the prompt tokens
+	want := Usage{InputTokens: 20, OutputTokens: 7, CacheReadTokens: 100}
+	if usage == nil || *usage != want {
+		t.Errorf("expected usage %+v, got %+v", want, usage)
+	}
+}
+
 func TestOpenAIStream_ErrorResponse(t *testing.T) {
 	// Create a test server that returns 401
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -13,6 +13,15 @@ type StreamChunk struct {
 	Content string
 	Done    bool
 	Error   error
+	Usage   *Usage // token counts reported by the API, on one chunk near the end
+}
+
+// Usage is the token accounting the API reports for a request.
+type Usage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`  // input read from the prompt cache
+	CacheWriteTokens int `json:"cache_write_tokens"` // input written to the prompt cache
 }
 
 // ChatMessage represents a single message in a conversation.
@@ -41,6 +50,8 @@ func BuildProviders(providers map[string]config.Provider) map[string]Provider {
 			result[name] = &claudeProvider{
 				name:         name,
 				apiKey:       cfg.APIKey,
+				keyCmd:       newKeyCommand(cfg.APIKeyCmd),
+				keyErr:       cfg.KeyError,
 				baseURL:      cfg.BaseURL,
 				model:        cfg.Model,
 				systemPrompt: cfg.SystemPrompt,
@@ -51,10 +62,13 @@ func BuildProviders(providers map[string]config.Provider) map[string]Provider {
 			result[name] = &openaiProvider{
 				name:         name,
 				apiKey:       cfg.APIKey,
+				keyCmd:       newKeyCommand(cfg.APIKeyCmd),
+				keyErr:       cfg.KeyError,
 				baseURL:      cfg.BaseURL,
 				model:        cfg.Model,
 				systemPrompt: cfg.SystemPrompt,
 				maxTokens:    cfg.MaxTokens,
+				streamUsage:  cfg.StreamUsage,
 				client:       &http.Client{},
 			}
 		}
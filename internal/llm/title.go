@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxTitleRunes caps generated titles, which models sometimes let run long.
+const maxTitleRunes = 80
+
+// titlePrompt instructs the model that names sessions.
+const titlePrompt = "Write a title of at most six words for the conversation below. " +
+	"Reply with the title only: no quotes, no trailing punctuation."
+
+// GenerateTitle asks p for a short title for a conversation that opened with
+// prompt and was answered with reply. The usage is the one reported by the
+// API, or nil.
+func GenerateTitle(ctx context.Context, p Provider, prompt, reply string) (string, *Usage, error) {
+	transcript := fmt.Sprintf("user: %s\n\nassistant: %s", prompt, reply)
+	title, usage, err := Complete(ctx, p, []ChatMessage{
+		{Role: "system", Content: titlePrompt},
+		{Role: "user", Content: transcript},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate title: %w", err)
+	}
+
+	title = cleanTitle(title)
+	if title == "" {
+		return "", nil, fmt.Errorf("failed to generate title: empty response")
+	}
+	return title, usage, nil
+}
+
+// cleanTitle keeps the first line of a generated title without surrounding
+// quotes, markup or trailing punctuation.
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(title, " \t\"'`*#.")
+
+	if runes := []rune(title); len(runes) > maxTitleRunes {
+		title = strings.TrimSpace(string(runes[:maxTitleRunes]))
+	}
+	return title
+}
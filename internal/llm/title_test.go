@@ -0,0 +1,22 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGenerateTitle(t *testing.T) {
+	p := &fakeProvider{chunks: []StreamChunk{{Content: "\"Fixing a Go build.\"\nExtra line"}, {Done: true}}}
+	title, _, err := GenerateTitle(context.Background(), p, "my build fails", "try go mod tidy")
+	if err != nil {
+		t.Fatalf("GenerateTitle() error: %v", err)
+	}
+	if title != "Fixing a Go build" {
+		t.Errorf("GenerateTitle() = %q, want %q", title, "Fixing a Go build")
+	}
+
+	p = &fakeProvider{chunks: []StreamChunk{{Content: "  \"\" "}, {Done: true}}}
+	if _, _, err := GenerateTitle(context.Background(), p, "hi", "hello"); err == nil {
+		t.Error("expected error for an empty title")
+	}
+}
@@ -0,0 +1,131 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CodeBlock is a fenced code block found in Markdown text.
+type CodeBlock struct {
+	Info    string // full info string after the opening fence, e.g. "go title=main.go"
+	Lang    string // first word of the info string
+	Content string // code between the fences
+	// Name is the file the block holds, from a title=, file= or filename=
+	// attribute in the info string or from a line naming a file just
+	// before the block; "" if neither names one
+	Name string
+}
+
+// CodeBlocks returns the fenced code blocks in text, in order. Both ``` and
+// ~~~ fences are recognised; an unclosed block runs to the end of the text.
+func CodeBlocks(text string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var body []string
+	var fence string
+	var before string // last non-blank line since the previous block
+
+	for _, line := range strings.Split(text, "\n") {
+		if current == nil {
+			marker, info, ok := openingFence(line)
+			if !ok {
+				if strings.TrimSpace(line) != "" {
+					before = line
+				}
+				continue
+			}
+			fence = marker
+			current = &CodeBlock{Info: info, Name: blockName(info, before)}
+			if fields := strings.Fields(info); len(fields) > 0 {
+				current.Lang = fields[0]
+			}
+			body = body[:0]
+			continue
+		}
+
+		if isClosingFence(line, fence) {
+			current.Content = strings.Join(body, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			before = ""
+			continue
+		}
+		body = append(body, line)
+	}
+
+	if current != nil {
+		current.Content = strings.Join(body, "\n")
+		blocks = append(blocks, *current)
+	}
+
+	return blocks
+}
+
+// openingFence reports whether line opens a code block, returning the fence
+// marker and the trimmed info string.
+func openingFence(line string) (marker, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return "", "", false
+	}
+	for _, c := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && trimmed[n] == c {
+			n++
+		}
+		if n < 3 {
+			continue
+		}
+		info = strings.TrimSpace(trimmed[n:])
+		// Backtick fences can't have backticks in their info string
+		if c == '`' && strings.Contains(info, "`") {
+			return "", "", false
+		}
+		return trimmed[:n], info, true
+	}
+	return "", "", false
+}
+
+// isClosingFence reports whether line closes a block opened with marker.
+func isClosingFence(line, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < len(marker) {
+		return false
+	}
+	return strings.Trim(trimmed, marker[:1]) == ""
+}
+
+// nameAttr matches a file name attribute in an info string, quoted or not.
+var nameAttr = regexp.MustCompile(`(?:^|\s)(?:title|file|filename)=("[^"]*"|'[^']*'|\S+)`)
+
+// fileName matches a relative path ending in an extension, or a file
+// commonly named without one.
+var fileName = regexp.MustCompile(`^(?:[\w.-]+/)*(?:[\w.-]*\.[A-Za-z]\w*|Makefile|Dockerfile)$`)
+
+// blockName returns the file a block holds, named by its info string or by
+// the line before it.
+func blockName(info, before string) string {
+	if m := nameAttr.FindStringSubmatch(info); m != nil {
+		return strings.Trim(m[1], `"'`)
+	}
+	return lineName(before)
+}
+
+// lineName returns the file a line names on its own, such as "main.go:",
+// "**main.go**", "`cmd/app/main.go`", "### main.go" or "File: main.go",
+// or "" for any other line.
+func lineName(line string) string {
+	s := strings.TrimSpace(line)
+	s = strings.TrimSpace(strings.TrimLeft(s, "#"))
+	for _, label := range []string{"file:", "filename:", "path:"} {
+		if len(s) > len(label) && strings.EqualFold(s[:len(label)], label) {
+			s = strings.TrimSpace(s[len(label):])
+			break
+		}
+	}
+	s = strings.Trim(s, "*_`:")
+	if !fileName.MatchString(s) {
+		return ""
+	}
+	return s
+}
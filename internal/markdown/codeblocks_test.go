@@ -0,0 +1,76 @@
+package markdown
+
+import "testing"
+
+func TestCodeBlocks(t *testing.T) {
+	text := "Here you go:\n\n```go title=main.go\npackage main\n\nfunc main() {}\n```\n\nAnd a shell line:\n\n~~~sh\necho hi\n~~~\n"
+
+	blocks := CodeBlocks(text)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+
+	if blocks[0].Lang != "go" || blocks[0].Info != "go title=main.go" {
+		t.Errorf("unexpected first block info: %+v", blocks[0])
+	}
+	if blocks[0].Content != "package main\n\nfunc main() {}" {
+		t.Errorf("unexpected first block content: %q", blocks[0].Content)
+	}
+	if blocks[1].Lang != "sh" || blocks[1].Content != "echo hi" {
+		t.Errorf("unexpected second block: %+v", blocks[1])
+	}
+}
+
+func TestCodeBlocks_NestedFences(t *testing.T) {
+	text := "````md\n```go\nx := 1\n```\n````"
+
+	blocks := CodeBlocks(text)
+	if len(blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(blocks))
+	}
+	if blocks[0].Content != "```go\nx := 1\n```" {
+		t.Errorf("inner fence should be kept as content, got %q", blocks[0].Content)
+	}
+}
+
+func TestCodeBlocks_Unclosed(t *testing.T) {
+	blocks := CodeBlocks("```\npartial output")
+	if len(blocks) != 1 || blocks[0].Content != "partial output" {
+		t.Errorf("expected unclosed block to run to the end, got %+v", blocks)
+	}
+}
+
+func TestCodeBlocks_None(t *testing.T) {
+	if blocks := CodeBlocks("just `inline` code"); len(blocks) != 0 {
+		t.Errorf("expected no blocks, got %+v", blocks)
+	}
+}
+
+func TestCodeBlocks_Names(t *testing.T) {
+	text := "```go title=main.go\npackage main\n```\n" +
+		"```yaml file=\"config/app.yaml\"\nx: 1\n```\n" +
+		"**go.mod**\n\n```\nmodule x\n```\n" +
+		"Then run it:\n```sh\ngo run .\n```\n" +
+		"### `cmd/tool/main.go`\n```go\npackage main\n```\n" +
+		"File: Makefile\n```make\nall:\n```\n" +
+		"main.go:\n\nSome prose in between.\n```go\n// not main.go\n```\n"
+	want := []string{"main.go", "config/app.yaml", "go.mod", "", "cmd/tool/main.go", "Makefile", ""}
+
+	blocks := CodeBlocks(text)
+	if len(blocks) != len(want) {
+		t.Fatalf("expected %d blocks, got %d", len(want), len(blocks))
+	}
+	for i, b := range blocks {
+		if b.Name != want[i] {
+			t.Errorf("block %d name = %q, want %q", i, b.Name, want[i])
+		}
+	}
+}
+
+func TestCodeBlocks_NameLineNotReused(t *testing.T) {
+	// The line before the first block doesn't name the second
+	blocks := CodeBlocks("main.go\n```go\na\n```\n```go\nb\n```")
+	if len(blocks) != 2 || blocks[0].Name != "main.go" || blocks[1].Name != "" {
+		t.Errorf("unexpected names: %+v", blocks)
+	}
+}
@@ -0,0 +1,24 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// Render renders Markdown for the terminal, wrapped to width.
+func Render(text string, width int) (string, error) {
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(styles.DarkStyle),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return "", err
+	}
+	out, err := r.Render(text)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(out, "\n"), nil
+}
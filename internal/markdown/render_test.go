@@ -0,0 +1,24 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRender(t *testing.T) {
+	out, err := Render("# Title\n\nSome **bold** text that is long enough to wrap around.", 20)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	plain := ansi.Strip(out)
+	if !strings.Contains(plain, "Title") || strings.Contains(plain, "**") {
+		t.Errorf("expected rendered Markdown, got %q", plain)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if w := ansi.StringWidth(line); w > 20 {
+			t.Errorf("line wider than 20 columns (%d): %q", w, ansi.Strip(line))
+		}
+	}
+}
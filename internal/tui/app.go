@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
@@ -13,6 +14,7 @@ import (
 	"github.com/mg/ai-tui/internal/tui/compose"
 	"github.com/mg/ai-tui/internal/tui/history"
 	"github.com/mg/ai-tui/internal/tui/selector"
+	"github.com/mg/ai-tui/internal/tui/stats"
 )
 
 // View represents the currently active view
@@ -21,6 +23,7 @@ type View int
 const (
 	ComposeView View = iota
 	HistoryView
+	StatsView
 )
 
 // AppModel is the root model for the TUI application
@@ -29,6 +32,7 @@ type AppModel struct {
 	activeProvider string
 	compose        compose.Model
 	history        history.Model
+	stats          stats.Model
 	selector       selector.Model
 	cfg            *config.Config
 	db             *db.DB
@@ -38,21 +42,50 @@ type AppModel struct {
 	quitting       bool
 	program        *tea.Program
 	help           help.Model
+	flash          string
+	flashSeq       int
 }
 
+// flashTimeout is how long a status bar notice stays visible
+const flashTimeout = 3 * time.Second
+
+// flashExpiredMsg clears the status bar notice with the matching sequence number
+type flashExpiredMsg struct{ seq int }
+
 // NewAppModel creates a new root application model
 func NewAppModel(cfg *config.Config, database *db.DB, providers map[string]llm.Provider) AppModel {
-	return AppModel{
+	m := AppModel{
 		activeView:     ComposeView,
 		activeProvider: cfg.DefaultProvider,
-		compose:        compose.New(database, providers[cfg.DefaultProvider]),
 		history:        history.New(database, cfg.Storage.NotesDir),
+		stats:          stats.New(database, cfg),
 		selector:       selector.New(cfg.Providers),
 		cfg:            cfg,
 		db:             database,
 		providers:      providers,
 		help:           help.New(),
 	}
+	m.history.SetExportConfig(cfg.Export)
+	m.resetCompose()
+	return m
+}
+
+// resetCompose replaces the compose view with a fresh conversation for the active provider
+func (m *AppModel) resetCompose() {
+	m.compose = compose.New(m.db, m.providers[m.activeProvider])
+	m.compose.SetConfig(m.cfg)
+	if name := m.cfg.Context.SummaryProvider; name != "" {
+		m.compose.SetSummarizer(m.providers[name])
+	}
+	if m.cfg.Titles.Generate {
+		name := m.cfg.Titles.Provider
+		if name == "" {
+			name = m.activeProvider
+		}
+		m.compose.SetTitler(m.providers[name])
+	}
+	m.compose.SetProgram(m.program)
+	m.compose.SetSize(m.width, m.height-2)
 }
 
 // SetProgram sets the tea.Program reference for sending messages
@@ -73,6 +106,18 @@ func (m *AppModel) SetActiveProvider(name string) {
 	}
 }
 
+// openSession continues a saved session in the compose view, switching to
+// the provider it was held with when that provider is configured.
+func (m AppModel) openSession(session db.Session, messages []db.Message) (tea.Model, tea.Cmd) {
+	m.activeView = ComposeView
+	if _, ok := m.providers[session.Provider]; ok {
+		m.activeProvider = session.Provider
+	}
+	m.resetCompose()
+	m.compose.LoadSession(session, messages)
+	return m, nil
+}
+
 // Init initializes the application
 func (m AppModel) Init() tea.Cmd {
 	return nil
@@ -82,15 +127,41 @@ func (m AppModel) Init() tea.Cmd {
 func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case history.ResumeSessionMsg:
+		return m.openSession(msg.Session, msg.Messages)
+
+	case compose.OpenSessionMsg:
+		return m.openSession(msg.Session, msg.Messages)
+
+	case selector.ModelSelectedMsg:
+		m.activeProvider = msg.ProviderName
 		m.activeView = ComposeView
+		m.resetCompose()
 		return m, nil
 
-	case selector.ModelSelectedMsg:
+	case compose.SelectModelMsg:
+		if msg.ProviderName == "" {
+			m.selector.Toggle()
+			return m, nil
+		}
+		if _, ok := m.providers[msg.ProviderName]; !ok {
+			return m.setFlash(fmt.Sprintf("Unknown provider %q", msg.ProviderName))
+		}
 		m.activeProvider = msg.ProviderName
+		m.resetCompose()
+		return m, nil
+
+	case compose.NewChatMsg:
 		m.activeView = ComposeView
-		m.compose = compose.New(m.db, m.providers[m.activeProvider])
-		m.compose.SetProgram(m.program)
-		m.compose.SetSize(m.width, m.height-2)
+		m.resetCompose()
+		return m, nil
+
+	case compose.FlashMsg:
+		return m.setFlash(msg.Text)
+
+	case flashExpiredMsg:
+		if msg.seq == m.flashSeq {
+			m.flash = ""
+		}
 		return m, nil
 
 	case tea.WindowSizeMsg:
@@ -101,6 +172,7 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		contentHeight := msg.Height - 2
 		m.compose.SetSize(msg.Width, contentHeight)
 		m.history.SetSize(msg.Width, contentHeight)
+		m.stats.SetSize(msg.Width, contentHeight)
 		m.selector.SetSize(msg.Width, contentHeight)
 
 		return m, nil
@@ -128,11 +200,13 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmd := m.history.Init()
 			return m, cmd
 
+		case key.Matches(msg, GlobalKeys.Stats):
+			m.activeView = StatsView
+			return m, m.stats.Init()
+
 		case key.Matches(msg, GlobalKeys.NewChat):
 			m.activeView = ComposeView
-			m.compose = compose.New(m.db, m.providers[m.activeProvider])
-			m.compose.SetProgram(m.program)
-			m.compose.SetSize(m.width, m.height-2)
+			m.resetCompose()
 			return m, nil
 
 		default:
@@ -143,6 +217,8 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.compose, cmd = m.compose.Update(msg)
 			case HistoryView:
 				m.history, cmd = m.history.Update(msg)
+			case StatsView:
+				m.stats, cmd = m.stats.Update(msg)
 			}
 			return m, cmd
 		}
@@ -155,6 +231,8 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.compose, cmd = m.compose.Update(msg)
 	case HistoryView:
 		m.history, cmd = m.history.Update(msg)
+	case StatsView:
+		m.stats, cmd = m.stats.Update(msg)
 	}
 	return m, cmd
 }
@@ -184,6 +262,8 @@ func (m AppModel) View() string {
 		content = m.compose.View()
 	case HistoryView:
 		content = m.history.View()
+	case StatsView:
+		content = m.stats.View()
 	}
 
 	// Build status bar with active provider and model
@@ -192,7 +272,11 @@ func (m AppModel) View() string {
 	if provider, ok := m.cfg.Providers[providerName]; ok {
 		modelName = provider.Model
 	}
-	statusBar := StatusBarStyle.Render(fmt.Sprintf("%s > %s", providerName, modelName))
+	status := fmt.Sprintf("%s > %s", providerName, modelName)
+	if m.flash != "" {
+		status += "  " + m.flash
+	}
+	statusBar := StatusBarStyle.Render(status)
 
 	// Build help bar
 	helpView := m.help.ShortHelpView(GlobalKeys.ShortHelp())
@@ -202,3 +286,13 @@ func (m AppModel) View() string {
 	parts := []string{content, statusBar, helpBar}
 	return strings.Join(parts, "\n")
 }
+
+// setFlash shows text in the status bar until flashTimeout elapses
+func (m AppModel) setFlash(text string) (AppModel, tea.Cmd) {
+	m.flashSeq++
+	m.flash = text
+	seq := m.flashSeq
+	return m, tea.Tick(flashTimeout, func(time.Time) tea.Msg {
+		return flashExpiredMsg{seq: seq}
+	})
+}
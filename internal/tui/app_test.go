@@ -1,11 +1,14 @@
 package tui
 
 import (
+	"strings"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
 	"github.com/mg/ai-tui/internal/llm"
+	"github.com/mg/ai-tui/internal/tui/compose"
 )
 
 // Helper function to create a minimal test config
@@ -72,6 +75,22 @@ func TestAppModel_CtrlH_SwitchesToHistoryView(t *testing.T) {
 	}
 }
 
+func TestAppModel_CtrlG_SwitchesToStatsView(t *testing.T) {
+	m := NewAppModel(testConfig(), nil, map[string]llm.Provider{})
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+	updated := updatedModel.(AppModel)
+	if updated.activeView != StatsView {
+		t.Errorf("expected activeView to be StatsView, got %v", updated.activeView)
+	}
+
+	// ctrl+n leaves the dashboard for a new chat
+	updatedModel, _ = updated.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if updatedModel.(AppModel).activeView != ComposeView {
+		t.Error("expected ctrl+n to return to the compose view")
+	}
+}
+
 func TestAppModel_CtrlN_SwitchesToComposeView(t *testing.T) {
 	m := NewAppModel(testConfig(), nil, map[string]llm.Provider{})
 
@@ -119,3 +138,57 @@ func TestAppModel_WindowSizeMsg_UpdatesDimensions(t *testing.T) {
 		t.Errorf("expected height to be 40, got %d", updated.height)
 	}
 }
+
+func TestAppModel_FlashMsg_ShowsInStatusBar(t *testing.T) {
+	m := NewAppModel(testConfig(), nil, map[string]llm.Provider{})
+
+	updatedModel, cmd := m.Update(compose.FlashMsg{Text: "Copied"})
+	updated := updatedModel.(AppModel)
+
+	if !strings.Contains(updated.View(), "Copied") {
+		t.Error("expected flash text in status bar")
+	}
+	if cmd == nil {
+		t.Fatal("expected a tick command to expire the flash")
+	}
+
+	updatedModel, _ = updated.Update(flashExpiredMsg{seq: updated.flashSeq})
+	updated = updatedModel.(AppModel)
+	if updated.flash != "" {
+		t.Error("expected flash to be cleared after expiry")
+	}
+}
+
+func TestAppModel_SelectModelMsg_SwitchesProvider(t *testing.T) {
+	cfg := testConfig()
+	cfg.Providers["other"] = config.Provider{Model: "other-model"}
+	m := NewAppModel(cfg, nil, map[string]llm.Provider{"test": nil, "other": nil})
+
+	updatedModel, _ := m.Update(compose.SelectModelMsg{ProviderName: "other"})
+	updated := updatedModel.(AppModel)
+	if updated.activeProvider != "other" {
+		t.Errorf("expected active provider 'other', got %q", updated.activeProvider)
+	}
+
+	updatedModel, _ = updated.Update(compose.SelectModelMsg{})
+	updated = updatedModel.(AppModel)
+	if !updated.selector.IsActive() {
+		t.Error("expected empty provider to open the selector")
+	}
+}
+
+func TestAppModel_OpenSessionMsg_LoadsSession(t *testing.T) {
+	cfg := testConfig()
+	cfg.Providers["other"] = config.Provider{Model: "other-model"}
+	m := NewAppModel(cfg, nil, map[string]llm.Provider{"test": nil, "other": nil})
+	m.activeView = HistoryView
+
+	updatedModel, _ := m.Update(compose.OpenSessionMsg{
+		Session:  db.Session{ID: "s1", Provider: "other"},
+		Messages: []db.Message{{Role: "user", Content: "hello"}},
+	})
+	updated := updatedModel.(AppModel)
+	if updated.activeView != ComposeView || updated.activeProvider != "other" {
+		t.Errorf("expected compose view with provider 'other', got view %v provider %q", updated.activeView, updated.activeProvider)
+	}
+}
@@ -4,17 +4,23 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/clipboard"
+	"github.com/mg/ai-tui/internal/config"
 	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
 	"github.com/mg/ai-tui/internal/llm"
+	"github.com/mg/ai-tui/internal/usage"
 )
 
 // Message types for compose view
 type StreamChunkMsg struct {
 	Content string
 	Done    bool
+	Usage   *llm.Usage
 }
 
 type StreamErrMsg struct {
@@ -29,7 +35,50 @@ type SessionCreatedMsg struct {
 	Session *db.Session
 }
 
-type MessageSavedMsg struct{}
+type MessageSavedMsg struct {
+	SessionID string
+	Key       int // DisplayMessage.key of the saved message, 0 for none
+	ID        int64
+}
+
+// SelectModelMsg asks the app to switch provider, or to open the model
+// selector when ProviderName is empty.
+type SelectModelMsg struct {
+	ProviderName string
+}
+
+// NewChatMsg asks the app to start a new conversation.
+type NewChatMsg struct{}
+
+// FlashMsg asks the app to show a short-lived notice in the status bar.
+type FlashMsg struct {
+	Text string
+}
+
+// ContextSummarizedMsg carries the summary that replaces older messages when
+// the conversation outgrows the context window.
+type ContextSummarizedMsg struct {
+	Summary string
+	Indices []int
+	Usage   *db.Usage // of the summary request, once it was answered
+	Err     error
+}
+
+// TitleGeneratedMsg carries a title written by the title provider. On error,
+// or when the session was renamed meanwhile, Title is empty and the session
+// keeps its title.
+type TitleGeneratedMsg struct {
+	SessionID string
+	Title     string
+	Usage     *db.Usage // of the title request, once it was answered
+	Err       error
+}
+
+// CommandDoneMsg reports the outcome of a slash command that ran in the background.
+type CommandDoneMsg struct {
+	Flash string
+	Err   error
+}
 
 func newUUID() string {
 	b := make([]byte, 16)
@@ -37,12 +86,13 @@ func newUUID() string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
 
-func createSessionCmd(database *db.DB, provider llm.Provider) tea.Cmd {
+func createSessionCmd(database *db.DB, provider llm.Provider, persona string) tea.Cmd {
 	return func() tea.Msg {
 		now := time.Now()
 		s := &db.Session{
 			ID:        newUUID(),
 			Provider:  provider.Name(),
+			Persona:   persona,
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
@@ -51,23 +101,215 @@ func createSessionCmd(database *db.DB, provider llm.Provider) tea.Cmd {
 	}
 }
 
-func saveMessageCmd(database *db.DB, sessionID, role, content string) tea.Cmd {
+func saveMessageCmd(database *db.DB, sessionID string, dm DisplayMessage) tea.Cmd {
 	return func() tea.Msg {
 		m := &db.Message{
-			SessionID: sessionID,
-			Role:      role,
-			Content:   content,
-			CreatedAt: time.Now(),
+			SessionID:  sessionID,
+			Role:       dm.Role,
+			Content:    dm.Content,
+			CreatedAt:  time.Now(),
+			Tokens:     dm.Tokens,
+			Pinned:     dm.Pinned,
+			Summarized: dm.Summarized,
 		}
 		database.AddMessage(m)
-		return MessageSavedMsg{}
+		return MessageSavedMsg{SessionID: sessionID, Key: dm.key, ID: m.ID}
 	}
 }
 
 func updateTitleCmd(database *db.DB, sessionID, title string) tea.Cmd {
 	return func() tea.Msg {
 		database.UpdateSessionTitle(sessionID, title)
-		return MessageSavedMsg{}
+		return MessageSavedMsg{SessionID: sessionID}
+	}
+}
+
+func setTitleCmd(database *db.DB, sessionID, title string) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.UpdateSessionTitle(sessionID, title); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{Flash: "Title set"}
+	}
+}
+
+func deleteMessageCmd(database *db.DB, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.DeleteMessage(id); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{}
+	}
+}
+
+func exportCmd(database *db.DB, sessionID, notesDir, format string, opts export.Options, appendNew bool) tea.Cmd {
+	return func() tea.Msg {
+		result, err := saveExport(database, sessionID, notesDir, format, opts, appendNew)
+		if err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{Flash: result.String()}
+	}
+}
+
+// autoExportCmd updates the Markdown export of a session after a reply,
+// only flashing when an edited export had to be left alone.
+func autoExportCmd(database *db.DB, sessionID, notesDir string, opts export.Options, appendNew bool) tea.Cmd {
+	return func() tea.Msg {
+		result, err := saveExport(database, sessionID, notesDir, "md", opts, appendNew)
+		if err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		if result.Edited != "" {
+			return CommandDoneMsg{Flash: result.String()}
+		}
+		return CommandDoneMsg{}
+	}
+}
+
+// saveExport exports a stored session in format, updating its earlier export.
+func saveExport(database *db.DB, sessionID, notesDir, format string, opts export.Options, appendNew bool) (export.Result, error) {
+	e, err := export.ForFormat(format, opts)
+	if err != nil {
+		return export.Result{}, err
+	}
+	session, err := database.GetSession(sessionID)
+	if err != nil {
+		return export.Result{}, err
+	}
+	messages, err := database.GetSessionMessages(sessionID)
+	if err != nil {
+		return export.Result{}, err
+	}
+	return export.Save(database, e, *session, messages, notesDir, appendNew)
+}
+
+func markSummarizedCmd(database *db.DB, ids []int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.MarkSummarized(ids); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{}
+	}
+}
+
+// summarizeCmd condenses older messages with provider. indices are the
+// positions of the summarized messages in the conversation.
+func summarizeCmd(ctx context.Context, cfg *config.Config, provider llm.Provider, msgs []llm.ChatMessage, indices []int) tea.Cmd {
+	return func() tea.Msg {
+		summary, reported, err := llm.Summarize(ctx, provider, msgs)
+		if err != nil {
+			return ContextSummarizedMsg{Indices: indices, Err: err}
+		}
+		req, _ := requestTo(cfg, provider, msgs)
+		u := usageOf(cfg, provider, reported, req.InputTokens, summary)
+		return ContextSummarizedMsg{Summary: summary, Indices: indices, Usage: &u}
+	}
+}
+
+func copyCmd(text string, command []string, flash string) tea.Cmd {
+	return func() tea.Msg {
+		if err := clipboard.Copy(text, command); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{Flash: flash}
+	}
+}
+
+func flashCmd(text string) tea.Cmd {
+	return func() tea.Msg {
+		return FlashMsg{Text: text}
+	}
+}
+
+// maxTitleRunes is how much of the first message becomes the default title
+const maxTitleRunes = 60
+
+// titleTimeout bounds the background request for a generated title
+const titleTimeout = 30 * time.Second
+
+// defaultTitle derives a session title from the first user message, on one
+// line and cut at a rune boundary.
+func defaultTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(title); len(runes) > maxTitleRunes {
+		return string(runes[:maxTitleRunes]) + "..."
+	}
+	return title
+}
+
+// generateTitleCmd replaces the session's current title, taken from the
+// first message, with a generated one, unless it is renamed in the meantime.
+// A title isn't worth asking about, so none is generated when the request
+// would exceed one of the provider's limits.
+func generateTitleCmd(database *db.DB, cfg *config.Config, provider llm.Provider, sessionID, current, prompt, reply string) tea.Cmd {
+	return func() tea.Msg {
+		req, limited := requestTo(cfg, provider, []llm.ChatMessage{
+			{Role: "user", Content: prompt},
+			{Role: "assistant", Content: reply},
+		})
+		if limited {
+			check, err := usage.CheckRequest(database, cfg, req, time.Now())
+			if err != nil {
+				return TitleGeneratedMsg{SessionID: sessionID, Err: err}
+			}
+			if len(check.Exceeded) > 0 {
+				return nil
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		title, reported, err := llm.GenerateTitle(ctx, provider, prompt, reply)
+		if err != nil {
+			return TitleGeneratedMsg{SessionID: sessionID, Err: err}
+		}
+		u := usageOf(cfg, provider, reported, req.InputTokens, title)
+		u.SessionID = sessionID
+
+		replaced, err := database.ReplaceSessionTitle(sessionID, current, title)
+		if err != nil {
+			return TitleGeneratedMsg{SessionID: sessionID, Usage: &u, Err: err}
+		}
+		msg := TitleGeneratedMsg{SessionID: sessionID, Usage: &u}
+		if replaced {
+			msg.Title = title
+		}
+		return msg
+	}
+}
+
+func forkSessionCmd(database *db.DB, parent *db.Session, provider llm.Provider, msgs []DisplayMessage) tea.Cmd {
+	now := time.Now()
+	fork := db.Session{ID: newUUID(), CreatedAt: now, UpdatedAt: now}
+	if parent != nil {
+		fork.Provider = parent.Provider
+		fork.Model = parent.Model
+		fork.ParentID = parent.ID
+		fork.Persona = parent.Persona
+		fork.Project = parent.Project
+		fork.Tags = parent.Tags
+		if parent.Title != "" {
+			fork.Title = parent.Title + " (fork)"
+		}
+	} else if provider != nil {
+		fork.Provider = provider.Name()
+	}
+
+	messages := make([]db.Message, 0, len(msgs))
+	for _, dm := range msgs {
+		messages = append(messages, db.Message{Role: dm.Role, Content: dm.Content, CreatedAt: now, Tokens: dm.Tokens, Pinned: dm.Pinned, Summarized: dm.Summarized})
+		if fork.Title == "" && dm.Role == "user" {
+			fork.Title = defaultTitle(dm.Content)
+		}
+	}
+
+	return func() tea.Msg {
+		if err := database.CreateSessionWithMessages(&fork, messages); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return SessionForkedMsg{Session: &fork, Messages: messages}
 	}
 }
 
@@ -87,7 +329,7 @@ func streamCmd(provider llm.Provider, msgs []llm.ChatMessage, p *tea.Program) te
 					p.Send(StreamErrMsg{Err: chunk.Error})
 					return
 				}
-				p.Send(StreamChunkMsg{Content: chunk.Content, Done: chunk.Done})
+				p.Send(StreamChunkMsg{Content: chunk.Content, Done: chunk.Done, Usage: chunk.Usage})
 			}
 		}()
 
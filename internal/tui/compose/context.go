@@ -0,0 +1,279 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/llm"
+)
+
+// summaryLabel introduces a pinned summary in the conversation
+const summaryLabel = "Summary of earlier messages: "
+
+// SetSummarizer sets the provider that condenses older messages when the
+// "summarize" context strategy is used. The active provider is used when unset.
+func (m *Model) SetSummarizer(p llm.Provider) {
+	m.summarizer = p
+}
+
+// providerConfig returns the config of the active provider, if known.
+func (m *Model) providerConfig() (config.Provider, bool) {
+	if m.cfg == nil || m.provider == nil {
+		return config.Provider{}, false
+	}
+	pc, ok := m.cfg.Providers[m.provider.Name()]
+	return pc, ok
+}
+
+// contextWindow returns the active model's context window in tokens, or 0
+// when there is no configured provider.
+func (m *Model) contextWindow() int {
+	pc, ok := m.providerConfig()
+	if !ok {
+		return 0
+	}
+	return llm.ContextWindow(pc.Model, pc.ContextWindow)
+}
+
+// contextBudget returns how many tokens the conversation may use, leaving
+// room for the system prompt and the response. 0 means unlimited.
+func (m *Model) contextBudget() int {
+	pc, ok := m.providerConfig()
+	if !ok {
+		return 0
+	}
+	window := llm.ContextWindow(pc.Model, pc.ContextWindow)
+	budget := window - pc.MaxTokens - llm.EstimateTokens(pc.SystemPrompt)
+	if budget < window/2 {
+		budget = window / 2
+	}
+	return budget
+}
+
+// requestIndices returns the positions of the messages sent to the model:
+// pinned notes first, then the conversation without summarized messages.
+func (m *Model) requestIndices() []int {
+	var pinned, rest []int
+	for i, dm := range m.messages {
+		switch {
+		case dm.Summarized:
+		case dm.Pinned:
+			pinned = append(pinned, i)
+		default:
+			rest = append(rest, i)
+		}
+	}
+	return append(pinned, rest...)
+}
+
+// chatMessages converts the messages at indices for the provider.
+func (m *Model) chatMessages(indices []int) []llm.ChatMessage {
+	msgs := make([]llm.ChatMessage, 0, len(indices))
+	for _, i := range indices {
+		msgs = append(msgs, llm.ChatMessage{Role: m.messages[i].Role, Content: m.messages[i].Content})
+	}
+	return msgs
+}
+
+// contextUsage returns the estimated tokens of the next request and the
+// context window, for the usage indicator.
+func (m Model) contextUsage() (used, window int) {
+	window = m.contextWindow()
+	if window == 0 {
+		return 0, 0
+	}
+	used = llm.EstimateMessages(m.chatMessages(m.requestIndices()))
+	if pc, ok := m.providerConfig(); ok {
+		used += llm.EstimateTokens(pc.SystemPrompt)
+	}
+	return used, window
+}
+
+// contextIndicator renders the share of the context window in use.
+func (m Model) contextIndicator() string {
+	used, window := m.contextUsage()
+	if window == 0 {
+		return ""
+	}
+	return fmt.Sprintf("ctx %d%%", used*100/window)
+}
+
+// summaryPlan picks the messages to replace with a summary: everything
+// except the most recent messages that fit in half the budget, plus any
+// earlier summary. It returns nil when there is nothing worth summarizing.
+func (m *Model) summaryPlan(budget int) []int {
+	indices := m.requestIndices()
+
+	var candidates []int
+	var pinned []int
+	for _, i := range indices {
+		switch {
+		case m.messages[i].Pinned:
+			pinned = append(pinned, i)
+		case m.messages[i].Role != "system":
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) < 2 {
+		return nil
+	}
+
+	// Keep recent messages while they fit, always keeping the last one
+	keep := len(candidates) - 1
+	used := llm.EstimateTokens(m.messages[candidates[keep]].Content)
+	for keep > 0 {
+		next := llm.EstimateTokens(m.messages[candidates[keep-1]].Content)
+		if used+next > budget/2 {
+			break
+		}
+		used += next
+		keep--
+	}
+	// Don't leave a reply at the start of the kept messages
+	for keep < len(candidates)-1 && m.messages[candidates[keep]].Role == "assistant" {
+		keep++
+	}
+	if keep == 0 {
+		return nil
+	}
+	return append(pinned, candidates[:keep]...)
+}
+
+// fitContext applies the configured strategy when the conversation doesn't
+// fit the context window. It returns the command that streams the response,
+// possibly after summarizing older messages first.
+func (m *Model) fitContext() tea.Cmd {
+	indices := m.requestIndices()
+	msgs := m.chatMessages(indices)
+	budget := m.contextBudget()
+	if budget == 0 || llm.EstimateMessages(msgs) <= budget {
+		return m.stream(msgs)
+	}
+
+	if m.cfg.Context.Strategy == config.StrategySummarize {
+		summarizer := m.summarizer
+		if summarizer == nil {
+			summarizer = m.provider
+		}
+		if plan := m.summaryPlan(budget); plan != nil && summarizer != nil {
+			m.summarizing = true
+			return m.summarize(&summaryRequest{provider: summarizer, indices: plan})
+		}
+	}
+
+	return m.truncated(msgs, budget)
+}
+
+// summaryRequest is a summary of older messages, made before the reply.
+type summaryRequest struct {
+	provider llm.Provider
+	indices  []int // positions of the summarized messages
+}
+
+// summarize condenses older messages as sr describes, checking the request
+// against the summarizer's limits first. The summary is by design larger
+// than the context budget, so it's what max_input_tokens is there to stop.
+func (m *Model) summarize(sr *summaryRequest) tea.Cmd {
+	msgs := m.chatMessages(sr.indices)
+	if req, limited := requestTo(m.cfg, sr.provider, msgs); limited && m.db != nil {
+		return checkLimitsCmd(m.db, m.cfg, req, msgs, sr)
+	}
+	return m.startSummary(msgs, sr)
+}
+
+// startSummary condenses msgs once the request has passed any limits.
+func (m *Model) startSummary(msgs []llm.ChatMessage, sr *summaryRequest) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelFn = cancel
+	return summarizeCmd(ctx, m.cfg, sr.provider, msgs, sr.indices)
+}
+
+// skipSummary streams the response without the summary, which wasn't sent,
+// leaving older messages out instead.
+func (m *Model) skipSummary() tea.Cmd {
+	m.summarizing = false
+	return m.truncated(m.chatMessages(m.requestIndices()), m.contextBudget())
+}
+
+// truncated streams msgs with the oldest messages dropped to fit budget.
+func (m *Model) truncated(msgs []llm.ChatMessage, budget int) tea.Cmd {
+	msgs, dropped := llm.Fit(msgs, budget)
+	if dropped == 0 {
+		return m.stream(msgs)
+	}
+	return tea.Batch(
+		m.stream(msgs),
+		flashCmd(fmt.Sprintf("Left out %d older messages to fit the context window", dropped)),
+	)
+}
+
+// applySummary replaces the summarized messages with a pinned summary and
+// streams the response. The summary goes right after the messages it
+// replaces, so that /retry and regenerating, which cut the conversation
+// after a prompt, keep it.
+func (m *Model) applySummary(msg ContextSummarizedMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	if msg.Usage != nil && m.db != nil {
+		u := *msg.Usage
+		if m.session != nil {
+			u.SessionID = m.session.ID
+		}
+		cmds = append(cmds, recordUsageCmd(m.db, m.cfg, u))
+	}
+	if !m.summarizing || !m.streaming {
+		// Cancelled while summarizing
+		return tea.Batch(cmds...)
+	}
+	if msg.Err != nil {
+		return tea.Batch(flashCmd("Summary failed: "+msg.Err.Error()), m.skipSummary())
+	}
+	m.summarizing = false
+
+	var ids []int64
+	at := 0
+	for _, i := range msg.Indices {
+		if i < len(m.messages) {
+			m.messages[i].Summarized = true
+			if m.messages[i].ID != 0 {
+				ids = append(ids, m.messages[i].ID)
+			}
+			at = max(at, i+1)
+		}
+	}
+	summary := DisplayMessage{Role: "system", Content: msg.Summary, Pinned: true}
+	m.messages = append(m.messages[:at:at], append([]DisplayMessage{summary}, m.messages[at:]...)...)
+	if m.session != nil && m.db != nil {
+		if len(ids) > 0 {
+			cmds = append(cmds, markSummarizedCmd(m.db, ids))
+		}
+		cmds = append(cmds, m.saveCmd(at))
+	}
+
+	cmds = append(cmds, m.truncated(m.chatMessages(m.requestIndices()), m.contextBudget()))
+	m.updateViewport()
+	return tea.Batch(cmds...)
+}
+
+// placeSummaries moves each pinned summary of a stored conversation, saved
+// after the prompt it was written for, back behind the messages it replaced.
+func placeSummaries(msgs []DisplayMessage) {
+	for i := range msgs {
+		if !msgs[i].Pinned {
+			continue
+		}
+		at := 0
+		for j := 0; j < i; j++ {
+			if msgs[j].Summarized {
+				at = j + 1
+			}
+		}
+		if at == 0 {
+			continue
+		}
+		summary := msgs[i]
+		copy(msgs[at+1:i+1], msgs[at:i])
+		msgs[at] = summary
+	}
+}
@@ -0,0 +1,197 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/llm"
+)
+
+// fakeProvider answers every request with a fixed response.
+type fakeProvider struct {
+	response string
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Stream(ctx context.Context, messages []llm.ChatMessage) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 2)
+	ch <- llm.StreamChunk{Content: p.response}
+	ch <- llm.StreamChunk{Done: true}
+	close(ch)
+	return ch, nil
+}
+
+// collectMsgs runs cmd and any batched or sequenced commands, returning
+// their messages.
+func collectMsgs(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	// tea.Sequence's message type is unexported, but like tea.BatchMsg it
+	// is a list of commands
+	cmds := reflect.TypeOf([]tea.Cmd(nil))
+	if v := reflect.ValueOf(msg); v.IsValid() && v.Type().ConvertibleTo(cmds) {
+		var msgs []tea.Msg
+		for _, c := range v.Convert(cmds).Interface().([]tea.Cmd) {
+			msgs = append(msgs, collectMsgs(c)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+// contextModel returns a model whose provider has a tiny context window.
+func contextModel(strategy string) Model {
+	m := New(nil, &fakeProvider{response: "short summary"})
+	m.SetConfig(&config.Config{
+		Providers: map[string]config.Provider{
+			"fake": {Model: "test", MaxTokens: 10, ContextWindow: 100},
+		},
+		Context: config.Context{Strategy: strategy},
+	})
+	m.SetSize(80, 20)
+	long := strings.Repeat("x", 100) // 25 tokens
+	m.messages = []DisplayMessage{
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "latest"},
+	}
+	return m
+}
+
+func TestContextIndicator(t *testing.T) {
+	m := contextModel(config.StrategyTruncate)
+	if !strings.Contains(m.View(), "ctx 122%") {
+		t.Errorf("expected context usage in help line, got %q", m.View())
+	}
+
+	if New(nil, nil).contextIndicator() != "" {
+		t.Error("no indicator expected without a configured provider")
+	}
+}
+
+func TestSendTruncatesToContextWindow(t *testing.T) {
+	m := contextModel(config.StrategyTruncate)
+	cmd := m.send()
+
+	var flashed bool
+	for _, msg := range collectMsgs(cmd) {
+		if f, ok := msg.(FlashMsg); ok && strings.Contains(f.Text, "older messages") {
+			flashed = true
+		}
+	}
+	if !flashed {
+		t.Error("expected a notice about dropped messages")
+	}
+	if len(m.messages) != 5 {
+		t.Errorf("truncation should not remove messages from the conversation, got %d", len(m.messages))
+	}
+}
+
+func TestSendSummarizesOlderMessages(t *testing.T) {
+	m := contextModel(config.StrategySummarize)
+	cmd := m.send()
+	if !m.summarizing {
+		t.Fatal("expected summarization to start")
+	}
+
+	var summarized *ContextSummarizedMsg
+	for _, msg := range collectMsgs(cmd) {
+		if s, ok := msg.(ContextSummarizedMsg); ok {
+			summarized = &s
+		}
+	}
+	if summarized == nil {
+		t.Fatal("expected ContextSummarizedMsg")
+	}
+
+	m, _ = m.Update(*summarized)
+	if m.summarizing {
+		t.Error("summarizing should end once the summary arrives")
+	}
+
+	summary := m.messages[len(m.messages)-2]
+	if !summary.Pinned || summary.Role != "system" || summary.Content != "short summary" {
+		t.Errorf("expected pinned summary before the kept messages, got %+v", summary)
+	}
+
+	req := m.chatMessages(m.requestIndices())
+	if req[0].Content != "short summary" {
+		t.Errorf("summary should lead the request, got %+v", req[0])
+	}
+	if req[len(req)-1].Content != "latest" {
+		t.Error("the latest prompt must be sent")
+	}
+	if llm.EstimateMessages(req) > m.contextBudget() {
+		t.Errorf("request still exceeds the budget: %d > %d", llm.EstimateMessages(req), m.contextBudget())
+	}
+	if !strings.Contains(m.viewport.View(), summaryLabel) {
+		t.Error("summary should be shown in the conversation")
+	}
+}
+
+func TestRetryKeepsSummary(t *testing.T) {
+	m := contextModel(config.StrategySummarize)
+	for _, msg := range collectMsgs(m.send()) {
+		if s, ok := msg.(ContextSummarizedMsg); ok {
+			m, _ = m.Update(s)
+		}
+	}
+	m.streaming = false
+	m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: "answer"})
+
+	m = typeText(m, "/retry")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	req := m.chatMessages(m.requestIndices())
+	if len(req) < 2 || req[0].Content != "short summary" || req[len(req)-1].Content != "latest" {
+		t.Errorf("expected the retried request to keep the summary, got %+v", req)
+	}
+
+	// Regenerating the reply is allowed too
+	m.streaming = false
+	m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: "answer"})
+	m.selected = len(m.messages) - 1
+	m.regenerateSelected()
+	if m.err != nil {
+		t.Errorf("regenerate failed: %v", m.err)
+	}
+}
+
+func TestLoadSessionPlacesSummary(t *testing.T) {
+	m := New(nil, nil)
+	m.LoadSession(db.Session{ID: "s1"}, []db.Message{
+		{ID: 1, Role: "user", Content: "old", Summarized: true},
+		{ID: 2, Role: "assistant", Content: "old answer", Summarized: true},
+		{ID: 3, Role: "user", Content: "latest"},
+		{ID: 4, Role: "system", Content: "summary", Pinned: true},
+		{ID: 5, Role: "assistant", Content: "answer"},
+	})
+	var ids []int64
+	for _, dm := range m.messages {
+		ids = append(ids, dm.ID)
+	}
+	if fmt.Sprint(ids) != "[1 2 4 3 5]" {
+		t.Errorf("expected the summary behind the summarized messages, got %v", ids)
+	}
+}
+
+func TestSummaryCancelled(t *testing.T) {
+	m := contextModel(config.StrategySummarize)
+	m.send()
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	m, cmd := m.Update(ContextSummarizedMsg{Summary: "late", Indices: []int{0}})
+	if cmd != nil || m.messages[0].Summarized {
+		t.Error("a summary arriving after cancel should be ignored")
+	}
+}
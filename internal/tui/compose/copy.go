@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/markdown"
+)
+
+// clipboardCmd returns the configured external clipboard command, if any.
+func (m *Model) clipboardCmd() []string {
+	if m.cfg == nil {
+		return nil
+	}
+	return m.cfg.UI.ClipboardCmd
+}
+
+// lastResponse returns the content of the most recent assistant message.
+func (m *Model) lastResponse() (string, bool) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "assistant" {
+			return m.messages[i].Content, true
+		}
+	}
+	return "", false
+}
+
+// copyMessage copies the nth user or assistant message, counting from 1.
+func (m *Model) copyMessage(n int) tea.Cmd {
+	count := 0
+	for _, dm := range m.messages {
+		if dm.Role != "user" && dm.Role != "assistant" {
+			continue
+		}
+		count++
+		if count == n {
+			return copyCmd(dm.Content, m.clipboardCmd(), fmt.Sprintf("Copied message %d", n))
+		}
+	}
+	return m.fail(fmt.Errorf("no message %d (conversation has %d)", n, count))
+}
+
+// copyCode copies the nth code block of content, counting from 1. With n == 0
+// a single block is copied directly and several open the numbered picker.
+func (m *Model) copyCode(content string, n int) tea.Cmd {
+	blocks := markdown.CodeBlocks(content)
+	switch {
+	case len(blocks) == 0:
+		return m.fail(fmt.Errorf("no code blocks in response"))
+	case n > len(blocks):
+		return m.fail(fmt.Errorf("no code block %d (response has %d)", n, len(blocks)))
+	case n == 0 && len(blocks) > 1:
+		m.codeBlocks = blocks
+		return nil
+	case n == 0:
+		n = 1
+	}
+	return copyCmd(blocks[n-1].Content, m.clipboardCmd(), codeBlockFlash(blocks[n-1], n))
+}
+
+// updateCodePicker handles keys while the numbered code block picker is open.
+func (m *Model) updateCodePicker(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type == tea.KeyEsc {
+		m.codeBlocks = nil
+		return nil
+	}
+	n, err := strconv.Atoi(msg.String())
+	if err != nil || n < 1 || n > len(m.codeBlocks) {
+		return nil
+	}
+	block := m.codeBlocks[n-1]
+	m.codeBlocks = nil
+	return copyCmd(block.Content, m.clipboardCmd(), codeBlockFlash(block, n))
+}
+
+// codePickerView lists the code blocks with their numbers and first lines.
+func (m Model) codePickerView() string {
+	lines := []string{commandStyle.Render("Copy code block (1-9, esc to cancel):")}
+	for i, b := range m.codeBlocks {
+		if i == 9 {
+			lines = append(lines, helpStyle.Render("  … use /copy code N for blocks after 9"))
+			break
+		}
+		first, _, _ := strings.Cut(b.Content, "\n")
+		lang := b.Lang
+		if lang == "" {
+			lang = "text"
+		}
+		lines = append(lines, fmt.Sprintf("  [%d] %-8s %s %s", i+1, lang,
+			helpStyle.Render(fmt.Sprintf("(%d lines)", strings.Count(b.Content, "\n")+1)), first))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func codeBlockFlash(b markdown.CodeBlock, n int) string {
+	if b.Lang != "" {
+		return fmt.Sprintf("Copied code block %d (%s)", n, b.Lang)
+	}
+	return fmt.Sprintf("Copied code block %d", n)
+}
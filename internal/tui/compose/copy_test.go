@@ -0,0 +1,131 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+)
+
+// clipboardModel returns a model whose clipboard command writes to a file,
+// along with the path of that file.
+func clipboardModel(t *testing.T) (Model, string) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "clip.txt")
+	m := New(nil, nil)
+	m.SetConfig(&config.Config{UI: config.UI{ClipboardCmd: []string{"sh", "-c", "cat > " + out}}})
+	return m, out
+}
+
+// runCopy executes cmd and returns the resulting CommandDoneMsg.
+func runCopy(t *testing.T, cmd tea.Cmd) CommandDoneMsg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("expected a copy command")
+	}
+	done, ok := cmd().(CommandDoneMsg)
+	if !ok {
+		t.Fatal("expected CommandDoneMsg")
+	}
+	if done.Err != nil {
+		t.Fatalf("copy failed: %v", done.Err)
+	}
+	return done
+}
+
+func readClipboard(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read clipboard file: %v", err)
+	}
+	return string(data)
+}
+
+const twoBlocks = "First:\n```go\nfmt.Println(1)\n```\nSecond:\n```sh\necho 2\n```"
+
+func TestCopyLastResponse(t *testing.T) {
+	m, out := clipboardModel(t)
+	m.messages = []DisplayMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello there"}}
+
+	m = typeText(m, "/copy")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	done := runCopy(t, cmd)
+
+	if got := readClipboard(t, out); got != "hello there" {
+		t.Errorf("clipboard = %q, want last response", got)
+	}
+	if done.Flash == "" {
+		t.Error("expected a flash confirmation")
+	}
+}
+
+func TestCopyNthMessage(t *testing.T) {
+	m, out := clipboardModel(t)
+	m.messages = []DisplayMessage{{Role: "user", Content: "question"}, {Role: "assistant", Content: "answer"}}
+
+	m = typeText(m, "/copy 1")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	runCopy(t, cmd)
+
+	if got := readClipboard(t, out); got != "question" {
+		t.Errorf("clipboard = %q, want first message", got)
+	}
+}
+
+func TestCopyCodeOpensPicker(t *testing.T) {
+	m, out := clipboardModel(t)
+	m.messages = []DisplayMessage{{Role: "assistant", Content: twoBlocks}}
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+	if cmd != nil {
+		t.Error("several blocks should open the picker instead of copying")
+	}
+	if !strings.Contains(m.View(), "[2] sh") {
+		t.Error("picker should list numbered blocks")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'2'}})
+	runCopy(t, cmd)
+	if got := readClipboard(t, out); got != "echo 2" {
+		t.Errorf("clipboard = %q, want second block", got)
+	}
+	if len(m.codeBlocks) != 0 {
+		t.Error("picker should close after choosing")
+	}
+}
+
+func TestCopyCodeByNumber(t *testing.T) {
+	m, out := clipboardModel(t)
+	m.messages = []DisplayMessage{{Role: "assistant", Content: twoBlocks}}
+
+	m = typeText(m, "/copy code 1")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	runCopy(t, cmd)
+
+	if got := readClipboard(t, out); got != "fmt.Println(1)" {
+		t.Errorf("clipboard = %q, want first block", got)
+	}
+}
+
+func TestCopyCodePickerEsc(t *testing.T) {
+	m, _ := clipboardModel(t)
+	m.messages = []DisplayMessage{{Role: "assistant", Content: twoBlocks}}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(m.codeBlocks) != 0 {
+		t.Error("esc should close the picker")
+	}
+}
+
+func TestCopyWithoutResponse(t *testing.T) {
+	m := New(nil, nil)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+	if m.err == nil {
+		t.Error("expected error when there is nothing to copy")
+	}
+}
@@ -0,0 +1,66 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EditorFinishedMsg carries the draft back after $EDITOR exits.
+type EditorFinishedMsg struct {
+	Content string
+	Err     error
+}
+
+// editorCommand returns the user's editor command line from $VISUAL or
+// $EDITOR, falling back to vi. Values like "code --wait" are split on spaces.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// openEditorCmd writes draft to a temporary file, suspends the TUI while the
+// editor runs, and reads the file back when it exits.
+func openEditorCmd(draft string) tea.Cmd {
+	return func() tea.Msg {
+		f, err := os.CreateTemp("", "ai-tui-*.md")
+		if err != nil {
+			return EditorFinishedMsg{Err: fmt.Errorf("failed to create draft file: %w", err)}
+		}
+		path := f.Name()
+		_, err = f.WriteString(draft)
+		f.Close()
+		if err != nil {
+			os.Remove(path)
+			return EditorFinishedMsg{Err: fmt.Errorf("failed to write draft file: %w", err)}
+		}
+
+		// The exec message returned here makes the program run the editor
+		return execEditor(path)()
+	}
+}
+
+// execEditor runs the editor on the draft file at path and removes it
+// afterwards.
+func execEditor(path string) tea.Cmd {
+	args := editorCommand()
+	c := exec.Command(args[0], append(args[1:], path)...)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return EditorFinishedMsg{Err: fmt.Errorf("editor exited with error: %w", err)}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return EditorFinishedMsg{Err: fmt.Errorf("failed to read draft file: %w", err)}
+		}
+		return EditorFinishedMsg{Content: strings.TrimRight(string(data), "\n")}
+	})
+}
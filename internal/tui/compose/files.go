@@ -0,0 +1,350 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mg/ai-tui/internal/diff"
+	"github.com/mg/ai-tui/internal/markdown"
+)
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("114"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("174"))
+)
+
+// diffContext is how many unchanged lines are shown around each change
+const diffContext = 3
+
+// FilesPlannedMsg carries what writing a message's code blocks to a
+// directory would do.
+type FilesPlannedMsg struct {
+	Dir   string
+	Files []plannedFile
+	Err   error
+}
+
+// plannedFile is a code block to be written to a file.
+type plannedFile struct {
+	name    string // as the message names it
+	path    string
+	content string
+	exists  bool
+	diff    []diff.Line // from the existing file, nil for new files
+	skip    string      // why the file won't be written, "" if it will
+	write   bool        // confirmed to be written
+}
+
+// fileWriter writes the named code blocks of a message to files: it asks
+// for a directory, previews the files, then asks before overwriting each
+// one that exists, showing the changes.
+type fileWriter struct {
+	blocks     []markdown.CodeBlock
+	input      textinput.Model // directory, until the files are planned
+	dir        string
+	files      []plannedFile
+	planned    bool
+	confirming int // file whose overwrite is being confirmed, -1 while previewing
+	scroll     int // first diff line shown
+}
+
+// startFileWriter offers to write the code blocks of content that name a
+// file. With dir set, the directory isn't asked for.
+func (m *Model) startFileWriter(content, dir string) tea.Cmd {
+	var blocks []markdown.CodeBlock
+	for _, b := range markdown.CodeBlocks(content) {
+		if b.Name != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) == 0 {
+		return m.fail(fmt.Errorf("no code blocks with file names (use ```go title=main.go or a file name line above the block)"))
+	}
+
+	ti := textinput.New()
+	ti.Prompt = "Write to: "
+	if wd, err := os.Getwd(); err == nil {
+		ti.SetValue(wd)
+	}
+	m.writer = &fileWriter{blocks: blocks, input: ti, confirming: -1}
+	m.textarea.Blur()
+	if dir != "" {
+		return m.planFiles(dir)
+	}
+	return m.writer.input.Focus()
+}
+
+// stopFileWriter closes the file writer.
+func (m *Model) stopFileWriter() {
+	m.writer = nil
+	if !m.selecting {
+		m.textarea.Focus()
+	}
+}
+
+func (m *Model) planFiles(dir string) tea.Cmd {
+	m.writer.input.Blur()
+	m.writer.dir = expandHome(dir)
+	return planFilesCmd(m.writer.dir, m.writer.blocks)
+}
+
+func planFilesCmd(dir string, blocks []markdown.CodeBlock) tea.Cmd {
+	return func() tea.Msg {
+		files, err := planFiles(dir, blocks)
+		return FilesPlannedMsg{Dir: dir, Files: files, Err: err}
+	}
+}
+
+// planFiles works out what writing blocks to dir would do. A later block
+// for the same file replaces an earlier one, as a revised version.
+func planFiles(dir string, blocks []markdown.CodeBlock) ([]plannedFile, error) {
+	var files []plannedFile
+	index := make(map[string]int)
+	for _, b := range blocks {
+		f := plannedFile{name: b.Name, content: b.Content}
+		if f.content != "" && !strings.HasSuffix(f.content, "\n") {
+			f.content += "\n"
+		}
+
+		name := filepath.FromSlash(b.Name)
+		if !filepath.IsLocal(name) {
+			f.skip = "outside the directory"
+		} else {
+			f.path = filepath.Join(dir, name)
+			old, err := os.ReadFile(f.path)
+			switch {
+			case err == nil:
+				f.exists = true
+				if string(old) == f.content {
+					f.skip = "unchanged"
+				} else {
+					f.diff = diff.Lines(string(old), f.content)
+				}
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("failed to read %s: %w", b.Name, err)
+			}
+		}
+
+		if i, ok := index[f.name]; ok {
+			files[i] = f
+		} else {
+			index[f.name] = len(files)
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func writeFilesCmd(dir string, files []plannedFile) tea.Cmd {
+	return func() tea.Msg {
+		for _, f := range files {
+			if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+				return CommandDoneMsg{Err: fmt.Errorf("failed to create directory for %s: %w", f.name, err)}
+			}
+			if err := os.WriteFile(f.path, []byte(f.content), 0o644); err != nil {
+				return CommandDoneMsg{Err: fmt.Errorf("failed to write %s: %w", f.name, err)}
+			}
+		}
+		noun := "files"
+		if len(files) == 1 {
+			noun = "file"
+		}
+		return CommandDoneMsg{Flash: fmt.Sprintf("Wrote %d %s to %s", len(files), noun, dir)}
+	}
+}
+
+// applyFilePlan shows the planned files for confirmation.
+func (m *Model) applyFilePlan(msg FilesPlannedMsg) tea.Cmd {
+	if m.writer == nil || msg.Dir != m.writer.dir {
+		return nil
+	}
+	if msg.Err != nil {
+		m.stopFileWriter()
+		return m.fail(msg.Err)
+	}
+	m.writer.files = msg.Files
+	m.writer.planned = true
+	return nil
+}
+
+// updateFileWriter handles keys while the file writer is open.
+func (m *Model) updateFileWriter(msg tea.KeyMsg) tea.Cmd {
+	w := m.writer
+	switch {
+	case !w.planned && w.dir == "":
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.stopFileWriter()
+			return nil
+		case tea.KeyEnter:
+			dir := strings.TrimSpace(w.input.Value())
+			if dir == "" {
+				return nil
+			}
+			return m.planFiles(dir)
+		}
+		var cmd tea.Cmd
+		w.input, cmd = w.input.Update(msg)
+		return cmd
+
+	case !w.planned:
+		// Waiting for the plan
+		if msg.Type == tea.KeyEsc {
+			m.stopFileWriter()
+		}
+		return nil
+
+	case w.confirming < 0:
+		switch msg.String() {
+		case "enter", "y":
+			for i := range w.files {
+				w.files[i].write = w.files[i].skip == "" && !w.files[i].exists
+			}
+			return m.confirmNext(0)
+		case "esc", "n", "q":
+			m.stopFileWriter()
+			return flashCmd("Nothing written")
+		}
+		return nil
+	}
+
+	switch msg.String() {
+	case "y":
+		w.files[w.confirming].write = true
+		return m.confirmNext(w.confirming + 1)
+	case "n":
+		return m.confirmNext(w.confirming + 1)
+	case "a":
+		for i := w.confirming; i < len(w.files); i++ {
+			if w.files[i].skip == "" {
+				w.files[i].write = true
+			}
+		}
+		return m.confirmNext(len(w.files))
+	case "j", "down":
+		w.scroll++
+		return nil
+	case "k", "up":
+		w.scroll = max(w.scroll-1, 0)
+		return nil
+	case "esc", "q":
+		m.stopFileWriter()
+		return flashCmd("Nothing written")
+	}
+	return nil
+}
+
+// confirmNext asks about the next file from i that would be overwritten,
+// and writes the confirmed files once there are none left.
+func (m *Model) confirmNext(i int) tea.Cmd {
+	w := m.writer
+	for ; i < len(w.files); i++ {
+		if f := w.files[i]; f.exists && f.skip == "" {
+			w.confirming = i
+			w.scroll = 0
+			return nil
+		}
+	}
+
+	var files []plannedFile
+	for _, f := range w.files {
+		if f.write {
+			files = append(files, f)
+		}
+	}
+	dir := w.dir
+	m.stopFileWriter()
+	if len(files) == 0 {
+		return flashCmd("Nothing written")
+	}
+	return writeFilesCmd(dir, files)
+}
+
+// fileWriterView shows the directory prompt, the planned files, or the
+// changes to the file being confirmed.
+func (m Model) fileWriterView() string {
+	w := m.writer
+	switch {
+	case !w.planned && w.dir == "":
+		return commandStyle.Render(fmt.Sprintf("Write %d code blocks to files:", len(w.blocks))) + "\n" + w.input.View()
+	case !w.planned:
+		return helpStyle.Render("Checking files in " + w.dir + "...")
+	case w.confirming < 0:
+		lines := []string{commandStyle.Render("Write to " + w.dir + ":")}
+		width := 0
+		for _, f := range w.files {
+			width = max(width, len(f.name))
+		}
+		for _, f := range w.files {
+			var status string
+			switch {
+			case f.skip != "":
+				status = helpStyle.Render(f.skip + ", skipped")
+			case f.exists:
+				ins, del := diff.Stat(f.diff)
+				status = fmt.Sprintf("overwrite, %s %s", addedStyle.Render(fmt.Sprintf("+%d", ins)), removedStyle.Render(fmt.Sprintf("-%d", del)))
+			default:
+				status = addedStyle.Render(fmt.Sprintf("new, %d lines", strings.Count(f.content, "\n")))
+			}
+			lines = append(lines, fmt.Sprintf("  %-*s  %s", width, f.name, status))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	f := w.files[w.confirming]
+	ins, del := diff.Stat(f.diff)
+	lines := []string{commandStyle.Render(fmt.Sprintf("Overwrite %s (+%d -%d)?", f.name, ins, del))}
+	hunks := diff.Unified(f.diff, diffContext)
+	rows := max(m.viewport.Height/2, 5)
+	scroll := min(w.scroll, max(len(hunks)-rows, 0))
+	for _, line := range hunks[scroll:min(scroll+rows, len(hunks))] {
+		switch line[0] {
+		case '+':
+			line = addedStyle.Render(line)
+		case '-':
+			line = removedStyle.Render(line)
+		case '@':
+			line = helpStyle.Render(line)
+		}
+		if m.width > 0 {
+			line = ansi.Truncate(line, m.width, "…")
+		}
+		lines = append(lines, line)
+	}
+	if rest := len(hunks) - scroll - rows; rest > 0 {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("… %d more lines (j/k to scroll)", rest)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fileWriterHelp is the help line while the file writer is open.
+func (m Model) fileWriterHelp() string {
+	w := m.writer
+	switch {
+	case !w.planned && w.dir == "":
+		return "enter: preview | esc: cancel"
+	case !w.planned:
+		return "esc: cancel"
+	case w.confirming < 0:
+		return "enter: write | esc: cancel"
+	}
+	return "y: overwrite | n: skip | a: overwrite all | j/k: scroll | esc: cancel"
+}
+
+// expandHome replaces a leading ~/ with the home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
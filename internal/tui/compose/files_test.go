@@ -0,0 +1,177 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const filesResponse = "Here's the project:\n\n" +
+	"```go title=main.go\npackage main\n\nfunc main() {}\n```\n\n" +
+	"**go.mod**\n```\nmodule new\n\ngo 1.25\n```\n\n" +
+	"README.md:\n```md\n# Same\n```\n\n" +
+	"```sh file=../outside.sh\nrm -rf /\n```\n\n" +
+	"```sh\ngo run .\n```\n"
+
+// filesModel returns a model whose last response names files, and a
+// directory holding an outdated go.mod and an identical README.md.
+func filesModel(t *testing.T) (Model, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module old\n\ngo 1.25\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Same\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := New(nil, nil)
+	m.SetSize(80, 30)
+	m.messages = []DisplayMessage{{Role: "user", Content: "make a project"}, {Role: "assistant", Content: filesResponse}}
+	m.updateViewport()
+	return m, dir
+}
+
+// planned runs the planning command and hands its result to the model.
+func planned(t *testing.T, m Model, cmd tea.Cmd) Model {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("expected a command to check the files")
+	}
+	msg, ok := cmd().(FilesPlannedMsg)
+	if !ok {
+		t.Fatal("expected FilesPlannedMsg")
+	}
+	m, _ = m.Update(msg)
+	return m
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestWriteFilesFromSelection(t *testing.T) {
+	m, dir := filesModel(t)
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "w")
+	if m.writer == nil {
+		t.Fatal("expected the file writer to open")
+	}
+	if !strings.Contains(m.View(), "Write 4 code blocks to files:") {
+		t.Errorf("expected the directory prompt, got:\n%s", m.View())
+	}
+
+	m.writer.input.SetValue(dir)
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = planned(t, m, cmd)
+
+	view := m.View()
+	for _, want := range []string{"main.go", "new, 3 lines", "go.mod", "overwrite", "README.md", "unchanged, skipped", "../outside.sh", "outside the directory, skipped"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("preview is missing %q:\n%s", want, view)
+		}
+	}
+
+	// Writing asks before overwriting go.mod, showing the changes
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	view = m.View()
+	for _, want := range []string{"Overwrite go.mod (+1 -1)?", "-module old", "+module new"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("confirmation is missing %q:\n%s", want, view)
+		}
+	}
+
+	m, cmd = pressKey(m, "n")
+	if m.writer != nil {
+		t.Error("expected the file writer to close")
+	}
+	if !m.selecting {
+		t.Error("expected to stay in selection mode")
+	}
+	done := runCopy(t, cmd)
+	if done.Flash != "Wrote 1 file to "+dir {
+		t.Errorf("flash = %q", done.Flash)
+	}
+	if got := readFile(t, filepath.Join(dir, "main.go")); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "go.mod")); got != "module old\n\ngo 1.25\n" {
+		t.Errorf("go.mod was overwritten: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside.sh")); err == nil {
+		t.Error("a file outside the directory was written")
+	}
+}
+
+func TestWriteFilesCommandOverwrites(t *testing.T) {
+	m, dir := filesModel(t)
+	m = typeText(m, "/write "+dir)
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = planned(t, m, cmd)
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, cmd = pressKey(m, "y")
+	done := runCopy(t, cmd)
+	if done.Flash != "Wrote 2 files to "+dir {
+		t.Errorf("flash = %q", done.Flash)
+	}
+	if got := readFile(t, filepath.Join(dir, "go.mod")); got != "module new\n\ngo 1.25\n" {
+		t.Errorf("go.mod = %q, want the new version", got)
+	}
+	if !m.textarea.Focused() {
+		t.Error("expected focus back on the draft")
+	}
+}
+
+func TestWriteFilesCancel(t *testing.T) {
+	m, dir := filesModel(t)
+	m = typeText(m, "/write "+dir)
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = planned(t, m, cmd)
+
+	m, cmd = pressKey(m, "esc")
+	if m.writer != nil {
+		t.Error("expected the file writer to close")
+	}
+	if msgs := collectMsgs(cmd); len(msgs) != 1 || msgs[0] != (FlashMsg{Text: "Nothing written"}) {
+		t.Errorf("expected a flash, got %+v", msgs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main.go")); err == nil {
+		t.Error("main.go was written after cancelling")
+	}
+}
+
+func TestWriteFilesWithoutNames(t *testing.T) {
+	m := New(nil, nil)
+	m.messages = []DisplayMessage{{Role: "assistant", Content: "```go\nx := 1\n```"}}
+	m = typeText(m, "/write")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.writer != nil || m.err == nil || !strings.Contains(m.err.Error(), "no code blocks with file names") {
+		t.Errorf("expected an error, got writer=%v err=%v", m.writer != nil, m.err)
+	}
+}
+
+func TestPlanFilesLaterBlockWins(t *testing.T) {
+	blocks := []struct{ name, content string }{{"a.go", "one"}, {"b.go", "two"}, {"a.go", "three"}}
+	m := New(nil, nil)
+	var text strings.Builder
+	for _, b := range blocks {
+		text.WriteString("```go title=" + b.name + "\n" + b.content + "\n```\n")
+	}
+	m.messages = []DisplayMessage{{Role: "assistant", Content: text.String()}}
+	m = typeText(m, "/write "+t.TempDir())
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = planned(t, m, cmd)
+
+	files := m.writer.files
+	if len(files) != 2 || files[0].name != "a.go" || files[0].content != "three\n" || files[1].name != "b.go" {
+		t.Errorf("files = %+v, want a.go with the later version, then b.go", files)
+	}
+}
@@ -0,0 +1,47 @@
+package compose
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap defines key bindings handled by the compose view itself
+type keyMap struct {
+	Newline  key.Binding // insert a newline instead of sending
+	Editor   key.Binding // open the draft in $EDITOR
+	CopyCode key.Binding // copy a code block from the last response
+	Select   key.Binding // enter message selection mode
+	Search   key.Binding // search the conversation
+	Switch   key.Binding // open the quick switcher
+}
+
+// newKeyMap builds the compose key map with the given newline chords
+func newKeyMap(newlineKeys []string) keyMap {
+	return keyMap{
+		Newline: key.NewBinding(
+			key.WithKeys(newlineKeys...),
+			key.WithHelp(strings.Join(newlineKeys, "/"), "newline"),
+		),
+		Editor: key.NewBinding(
+			key.WithKeys("ctrl+o"),
+			key.WithHelp("ctrl+o", "editor"),
+		),
+		CopyCode: key.NewBinding(
+			key.WithKeys("ctrl+y"),
+			key.WithHelp("ctrl+y", "copy code"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("ctrl+k"),
+			key.WithHelp("ctrl+k", "select"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "search"),
+		),
+		Switch: key.NewBinding(
+			key.WithKeys("ctrl+p"),
+			key.WithHelp("ctrl+p", "switch session"),
+		),
+	}
+}
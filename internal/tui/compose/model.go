@@ -2,14 +2,20 @@ package compose
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mg/ai-tui/internal/config"
 	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
 	"github.com/mg/ai-tui/internal/llm"
+	"github.com/mg/ai-tui/internal/markdown"
 )
 
 // Local styles — do NOT import from internal/tui to avoid import cycle
@@ -18,38 +24,69 @@ var (
 	assistantStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
 	errorStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196"))
 	helpStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	commandStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
+	selectedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
 )
 
 // DisplayMessage holds a rendered conversation message.
 type DisplayMessage struct {
-	Role    string
-	Content string
+	ID         int64 // database ID, 0 until saved
+	Role       string
+	Content    string
+	Pinned     bool // always sent, e.g. a summary of older messages
+	Summarized bool // replaced by a summary and no longer sent
+	Tokens     int  // tokens the model generated for a reply
+	key        int  // identifies the message to its save while in flight
 }
 
 // Model is the compose view for chatting with an LLM.
 type Model struct {
-	textarea  textarea.Model
-	viewport  viewport.Model
-	messages  []DisplayMessage
-	streaming bool
-	streamBuf *strings.Builder
-	session   *db.Session
-	db        *db.DB
-	provider  llm.Provider
-	program   *tea.Program
-	cancelFn  context.CancelFunc
-	err       error
-	width     int
-	height    int
+	textarea     textarea.Model
+	viewport     viewport.Model
+	messages     []DisplayMessage
+	streaming    bool
+	summarizing  bool // condensing older messages before streaming
+	streamBuf    *strings.Builder
+	usage        *llm.Usage // usage reported for the reply being streamed
+	promptTokens int        // estimated size of the request being streamed
+	limitPrompt  *limitPrompt
+	decisions    []db.BudgetDecision // made before the session was saved
+	session      *db.Session
+	db           *db.DB
+	provider     llm.Provider
+	summarizer   llm.Provider // writes summaries for the "summarize" context strategy
+	titler       llm.Provider // names new sessions after the first reply, nil to disable
+	program      *tea.Program
+	cancelFn     context.CancelFunc
+	cfg          *config.Config
+	commands     *Registry
+	completed    int // selected entry in the command completion popup
+	keys         keyMap
+	maxInput     int                  // textarea height limit in lines
+	codeBlocks   []markdown.CodeBlock // open numbered code block picker
+	writer       *fileWriter          // writing code blocks to files
+	selecting    bool                 // message selection mode
+	selected     int                  // selected message index
+	offsets      []int                // first viewport line of each message
+	lastKey      int                  // last DisplayMessage.key handed out
+	search       searchState
+	switcher     switcherState
+	err          error
+	width        int
+	height       int
 }
 
+// minInputHeight is the textarea height when the draft is short
+const minInputHeight = 3
+
 // New creates a new compose view model.
 func New(database *db.DB, provider llm.Provider) Model {
 	ta := textarea.New()
 	ta.Placeholder = "Type your message..."
 	ta.Focus()
 	ta.CharLimit = 0
-	ta.SetHeight(3)
+	ta.MaxHeight = 0
+	ta.SetHeight(minInputHeight)
 
 	vp := viewport.New(80, 20)
 
@@ -58,10 +95,81 @@ func New(database *db.DB, provider llm.Provider) Model {
 		viewport:  vp,
 		db:        database,
 		provider:  provider,
+		commands:  defaultCommands(),
+		keys:      newKeyMap(nil),
+		search:    searchState{input: newSearchInput()},
+		switcher:  switcherState{input: newSwitcherInput()},
+		maxInput:  10,
 		streamBuf: &strings.Builder{},
 	}
 }
 
+// SetConfig applies the application config: key bindings, input limits and
+// the settings used by slash commands.
+func (m *Model) SetConfig(cfg *config.Config) {
+	m.cfg = cfg
+	if cfg == nil {
+		return
+	}
+	m.keys = newKeyMap(cfg.UI.NewlineKeys)
+	if cfg.UI.MaxInputHeight > 0 {
+		m.maxInput = cfg.UI.MaxInputHeight
+	}
+}
+
+func (m *Model) notesDir() string {
+	if m.cfg == nil {
+		return ""
+	}
+	return m.cfg.Storage.NotesDir
+}
+
+// exportOptions configures exports with the template from the config and
+// the database for links between forks.
+func (m *Model) exportOptions() export.Options {
+	opts := export.Options{Sessions: m.db}
+	if m.cfg != nil {
+		opts.Template = m.cfg.Export.Template
+	}
+	return opts
+}
+
+// exportAppends reports whether exporting again appends new messages to the
+// earlier export rather than rewriting it.
+func (m *Model) exportAppends() bool {
+	return m.cfg != nil && m.cfg.Export.Update == config.ExportAppend
+}
+
+// SetTitler sets the provider that writes a title for new sessions after the
+// first reply. With no titler, titles are taken from the first message.
+func (m *Model) SetTitler(p llm.Provider) {
+	m.titler = p
+}
+
+// titleCmd asks the titler for a session title once the first reply is in,
+// unless the session has been renamed in the meantime.
+func (m *Model) titleCmd() tea.Cmd {
+	if m.titler == nil || m.session == nil || m.db == nil {
+		return nil
+	}
+
+	var prompt, reply string
+	replies := 0
+	for _, dm := range m.messages {
+		switch {
+		case dm.Role == "user" && prompt == "":
+			prompt = dm.Content
+		case dm.Role == "assistant":
+			replies++
+			reply = dm.Content
+		}
+	}
+	if replies != 1 || prompt == "" || m.session.Title != defaultTitle(prompt) {
+		return nil
+	}
+	return generateTitleCmd(m.db, m.cfg, m.titler, m.session.ID, m.session.Title, prompt, reply)
+}
+
 // SetProgram sets the tea.Program reference for streaming.
 func (m *Model) SetProgram(p *tea.Program) {
 	m.program = p
@@ -71,16 +179,44 @@ func (m *Model) SetProgram(p *tea.Program) {
 func (m *Model) SetSize(w, h int) {
 	m.width = w
 	m.height = h
-	taHeight := 3
+	m.textarea.SetWidth(w)
+	m.layout()
+}
+
+// layout sizes the viewport to the space left over by the textarea.
+func (m *Model) layout() {
 	helpHeight := 1
-	vpHeight := h - taHeight - helpHeight
+	vpHeight := m.height - m.textarea.Height() - helpHeight
 	if vpHeight < 1 {
 		vpHeight = 1
 	}
-	m.viewport.Width = w
+	m.viewport.Width = m.width
 	m.viewport.Height = vpHeight
-	m.textarea.SetWidth(w)
-	m.textarea.SetHeight(taHeight)
+}
+
+// resizeInput grows or shrinks the textarea to fit the draft, between
+// minInputHeight and the configured maximum.
+func (m *Model) resizeInput() {
+	lines := 0
+	width := m.textarea.Width()
+	for _, line := range strings.Split(m.textarea.Value(), "\n") {
+		lines++
+		if w := lipgloss.Width(line); width > 0 && w > width {
+			lines += (w - 1) / width
+		}
+	}
+
+	h := lines
+	if h > m.maxInput {
+		h = m.maxInput
+	}
+	if h < minInputHeight {
+		h = minInputHeight
+	}
+	if h != m.textarea.Height() {
+		m.textarea.SetHeight(h)
+		m.layout()
+	}
 }
 
 // Init returns the initial command.
@@ -94,32 +230,90 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.limitPrompt != nil {
+			return m, m.updateLimitPrompt(msg)
+		}
+		if m.writer != nil {
+			return m, m.updateFileWriter(msg)
+		}
+		if len(m.codeBlocks) > 0 {
+			return m, m.updateCodePicker(msg)
+		}
+		if m.switcher.active {
+			return m, m.updateSwitcher(msg)
+		}
+		if m.search.active {
+			if cmd, handled := m.updateSearch(msg); handled {
+				return m, cmd
+			}
+		}
+		if m.selecting {
+			return m, m.updateSelection(msg)
+		}
+		if key.Matches(msg, m.keys.Select) {
+			return m, m.startSelection()
+		}
+		if key.Matches(msg, m.keys.Search) {
+			return m, m.startSearch()
+		}
+
+		if !m.streaming {
+			switch {
+			case key.Matches(msg, m.keys.Newline):
+				m.textarea.InsertString("\n")
+				m.resizeInput()
+				return m, nil
+			case key.Matches(msg, m.keys.Switch):
+				return m, m.startSwitcher()
+			case key.Matches(msg, m.keys.Editor):
+				return m, openEditorCmd(m.textarea.Value())
+			case key.Matches(msg, m.keys.CopyCode):
+				content, ok := m.lastResponse()
+				if !ok {
+					return m, m.fail(fmt.Errorf("no response to copy from"))
+				}
+				return m, m.copyCode(content, 0)
+			}
+		}
+
+		if matches := m.completions(); len(matches) > 0 {
+			switch msg.Type {
+			case tea.KeyTab:
+				m.textarea.SetValue("/" + matches[m.completed%len(matches)].Name + " ")
+				m.completed = 0
+				return m, nil
+			case tea.KeyUp:
+				m.completed = (m.completed + len(matches) - 1) % len(matches)
+				return m, nil
+			case tea.KeyDown:
+				m.completed = (m.completed + 1) % len(matches)
+				return m, nil
+			}
+		}
+
 		switch msg.Type {
 		case tea.KeyEnter:
 			if !m.streaming && strings.TrimSpace(m.textarea.Value()) != "" {
 				text := strings.TrimSpace(m.textarea.Value())
+				if name, args, ok := parseCommand(text); ok {
+					m.textarea.Reset()
+					m.resizeInput()
+					m.completed = 0
+					return m, m.runCommand(name, args)
+				}
+				if strings.HasPrefix(text, "//") {
+					text = text[1:]
+				}
+
 				m.textarea.Reset()
+				m.resizeInput()
 				m.messages = append(m.messages, DisplayMessage{Role: "user", Content: text})
-				m.streaming = true
 				m.err = nil
 
-				// Build chat messages for LLM
-				var chatMsgs []llm.ChatMessage
-				for _, dm := range m.messages {
-					chatMsgs = append(chatMsgs, llm.ChatMessage{Role: dm.Role, Content: dm.Content})
-				}
-
-				if m.session == nil && m.db != nil {
-					cmds = append(cmds, createSessionCmd(m.db, m.provider))
-				}
 				if m.session != nil && m.db != nil {
-					cmds = append(cmds, saveMessageCmd(m.db, m.session.ID, "user", text))
-				}
-				if m.provider != nil && m.program != nil {
-					cmds = append(cmds, streamCmd(m.provider, chatMsgs, m.program))
+					cmds = append(cmds, m.saveCmd(len(m.messages)-1))
 				}
-
-				m.updateViewport()
+				cmds = append(cmds, m.send())
 				return m, tea.Batch(cmds...)
 			}
 			// If streaming or empty, pass to textarea
@@ -129,22 +323,32 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 		case tea.KeyEsc, tea.KeyCtrlC:
 			if m.streaming {
+				// A stream that started is billed even when it's cut short
+				var cmd tea.Cmd
+				if m.cancelFn != nil && !m.summarizing && m.db != nil {
+					cmd = recordUsageCmd(m.db, m.cfg, m.replyUsage(m.streamBuf.String()))
+				}
 				if m.cancelFn != nil {
 					m.cancelFn()
+					m.cancelFn = nil
 				}
 				m.streaming = false
+				m.summarizing = false
+				m.usage = nil
 				if m.streamBuf.Len() > 0 {
 					m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: m.streamBuf.String()})
 					m.streamBuf.Reset()
 				}
 				m.updateViewport()
-				return m, nil
+				return m, cmd
 			}
 
 		default:
 			if !m.streaming {
 				var cmd tea.Cmd
 				m.textarea, cmd = m.textarea.Update(msg)
+				m.resizeInput()
+				m.completed = 0
 				return m, cmd
 			}
 		}
@@ -155,32 +359,66 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	case SessionCreatedMsg:
 		m.session = msg.Session
-		// Save the first user message that was deferred
-		if m.db != nil && len(m.messages) > 0 {
-			firstMsg := m.messages[0]
-			cmds = append(cmds, saveMessageCmd(m.db, m.session.ID, firstMsg.Role, firstMsg.Content))
-			title := firstMsg.Content
-			if len(title) > 60 {
-				title = title[:60] + "..."
+		// Save the messages that were deferred until the session existed, in order
+		if m.db != nil {
+			for _, d := range m.decisions {
+				d.SessionID = m.session.ID
+				cmds = append(cmds, recordDecisionCmd(m.db, d))
+			}
+			m.decisions = nil
+			title := ""
+			for i, dm := range m.messages {
+				if dm.ID == 0 {
+					cmds = append(cmds, m.saveCmd(i))
+				}
+				if title == "" && dm.Role == "user" {
+					title = dm.Content
+				}
+			}
+			if title != "" {
+				title = defaultTitle(title)
+				m.session.Title = title
+				cmds = append(cmds, updateTitleCmd(m.db, m.session.ID, title))
+			}
+			// The first reply may have arrived before the session existed
+			if cmd := m.titleCmd(); cmd != nil {
+				cmds = append(cmds, cmd)
 			}
-			cmds = append(cmds, updateTitleCmd(m.db, m.session.ID, title))
 		}
-		return m, tea.Batch(cmds...)
+		return m, tea.Sequence(cmds...)
 
 	case StreamChunkMsg:
 		m.streamBuf.WriteString(msg.Content)
+		if msg.Usage != nil {
+			m.usage = msg.Usage
+		}
 		if msg.Done {
 			m.streaming = false
 			content := m.streamBuf.String()
-			m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: content})
+			u := m.replyUsage(content)
+			m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: content, Tokens: u.OutputTokens})
 			m.streamBuf.Reset()
+			m.usage = nil
+			if m.db != nil {
+				cmds = append(cmds, recordUsageCmd(m.db, m.cfg, u))
+			}
 			if m.session != nil && m.db != nil {
-				cmds = append(cmds, saveMessageCmd(m.db, m.session.ID, "assistant", content))
+				cmds = append(cmds, m.saveCmd(len(m.messages)-1))
 			}
+			cmds = append(cmds, m.titleCmd())
 		}
 		m.updateViewport()
 		return m, tea.Batch(cmds...)
 
+	case TitleGeneratedMsg:
+		if msg.Title != "" && m.session != nil && m.session.ID == msg.SessionID {
+			m.session.Title = msg.Title
+		}
+		if msg.Usage != nil && m.db != nil {
+			return m, recordUsageCmd(m.db, m.cfg, *msg.Usage)
+		}
+		return m, nil
+
 	case StreamErrMsg:
 		m.streaming = false
 		m.err = msg.Err
@@ -189,53 +427,332 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		return m, nil
 
 	case MessageSavedMsg:
+		// Record database IDs so later edits can find the stored rows
+		if m.session == nil || msg.SessionID != m.session.ID || msg.Key == 0 {
+			return m, nil
+		}
+		i := m.messageIndex(msg.Key)
+		if i < 0 {
+			// Dropped or deleted while it was being saved, leaving an orphan row
+			if msg.ID != 0 && m.db != nil && !m.hasID(msg.ID) {
+				return m, deleteMessageCmd(m.db, msg.ID)
+			}
+			return m, nil
+		}
+		m.messages[i].ID = msg.ID
+		if m.messages[i].Role == "assistant" && m.cfg != nil && m.cfg.Export.Auto && m.db != nil {
+			return m, autoExportCmd(m.db, m.session.ID, m.notesDir(), m.exportOptions(), m.exportAppends())
+		}
+		return m, nil
+
+	case SessionsListedMsg:
+		if !m.switcher.active {
+			return m, nil
+		}
+		if msg.Err != nil {
+			m.stopSwitcher()
+			return m, m.fail(msg.Err)
+		}
+		m.switcher.sessions = msg.Sessions
+		m.filterSwitcher()
+		return m, nil
+
+	case ContextSummarizedMsg:
+		return m, m.applySummary(msg)
+
+	case LimitsCheckedMsg:
+		return m, m.applyLimits(msg)
+
+	case FilesPlannedMsg:
+		return m, m.applyFilePlan(msg)
+
+	case SessionForkedMsg:
+		m.LoadSession(*msg.Session, msg.Messages)
+		m.textarea.Focus()
+		return m, flashCmd("Forked into a new conversation")
+
+	case EditorFinishedMsg:
+		if msg.Err != nil {
+			return m, m.fail(msg.Err)
+		}
+		m.textarea.SetValue(msg.Content)
+		m.resizeInput()
+		return m, nil
+
+	case CommandDoneMsg:
+		if msg.Err != nil {
+			return m, m.fail(msg.Err)
+		}
+		if msg.Flash != "" {
+			return m, flashCmd(msg.Flash)
+		}
 		return m, nil
 	}
 
 	return m, nil
 }
 
+// send starts streaming a response to the current conversation, creating the
+// session first if this is the opening message.
+func (m *Model) send() tea.Cmd {
+	m.streaming = true
+
+	var cmds []tea.Cmd
+	if m.session == nil && m.db != nil {
+		var persona string
+		if m.cfg != nil {
+			persona = m.cfg.Persona.Name
+		}
+		cmds = append(cmds, createSessionCmd(m.db, m.provider, persona))
+	}
+	cmds = append(cmds, m.fitContext())
+
+	m.updateViewport()
+	return tea.Batch(cmds...)
+}
+
+// stream sends msgs to the provider.
+func (m *Model) stream(msgs []llm.ChatMessage) tea.Cmd {
+	if m.provider == nil {
+		return nil
+	}
+	m.usage = nil
+	m.cancelFn = nil
+	m.promptTokens = llm.EstimateMessages(msgs)
+	if pc, ok := m.providerConfig(); ok {
+		m.promptTokens += llm.EstimateTokens(pc.SystemPrompt)
+	}
+	if m.limited() {
+		return m.checkLimits(msgs)
+	}
+	return m.startStream(msgs)
+}
+
+// startStream sends msgs to the provider once they've passed any limits.
+func (m *Model) startStream(msgs []llm.ChatMessage) tea.Cmd {
+	if m.program == nil {
+		return nil
+	}
+	return streamCmd(m.provider, msgs, m.program)
+}
+
+// runCommand dispatches a slash command by name. A unique prefix is accepted
+// in place of the full name.
+func (m *Model) runCommand(name, args string) tea.Cmd {
+	c, ok := m.commands.Lookup(name)
+	if !ok {
+		matches := m.commands.Complete(name)
+		if len(matches) != 1 {
+			return m.fail(fmt.Errorf("unknown command: /%s", name))
+		}
+		c = matches[0]
+	}
+	m.err = nil
+	return c.Run(m, args)
+}
+
+// fail shows err in the conversation area.
+func (m *Model) fail(err error) tea.Cmd {
+	m.err = err
+	m.updateViewport()
+	return nil
+}
+
+// completions returns the commands matching the draft while the user is
+// still typing a command name.
+func (m Model) completions() []Command {
+	if m.streaming || m.commands == nil {
+		return nil
+	}
+	value := m.textarea.Value()
+	if _, _, ok := parseCommand(value); !ok && value != "/" {
+		return nil
+	}
+	if strings.ContainsAny(value, " \n") {
+		return nil
+	}
+	return m.commands.Complete(value[1:])
+}
+
 // View renders the compose view.
 func (m Model) View() string {
 	var parts []string
 	parts = append(parts, m.viewport.View())
 
-	if m.streaming {
-		parts = append(parts, helpStyle.Render("Generating... (esc: stop | ctrl+d: quit)"))
+	var help string
+	if m.writer != nil {
+		popup := m.fileWriterView()
+		parts[0] = trimLines(parts[0], strings.Count(popup, "\n")+1)
+		parts = append(parts, popup)
+		help = helpStyle.Render(m.fileWriterHelp())
+	} else if m.selecting {
+		if len(m.codeBlocks) > 0 {
+			popup := m.codePickerView()
+			parts[0] = trimLines(parts[0], strings.Count(popup, "\n")+1)
+			parts = append(parts, popup)
+		}
+		help = helpStyle.Render(selectionHelp)
+	} else if m.limitPrompt != nil {
+		popup := m.limitPromptView()
+		parts[0] = trimLines(parts[0], strings.Count(popup, "\n")+1)
+		parts = append(parts, popup)
+		help = helpStyle.Render("y: send anyway | n: don't send")
+	} else if m.streaming {
+		status := "Generating..."
+		if m.summarizing {
+			status = "Summarizing earlier messages..."
+		}
+		help = helpStyle.Render(status + " (esc: stop | ctrl+d: quit)")
+	} else if m.switcher.active {
+		// The switcher takes the place of the input and any lines it needs beyond
+		popup := m.switcherView()
+		if extra := strings.Count(popup, "\n") + 1 - m.textarea.Height(); extra > 0 {
+			parts[0] = trimLines(parts[0], extra)
+		}
+		parts = append(parts, popup)
+		help = helpStyle.Render(switcherHelp)
 	} else {
+		// Popups take their lines from the bottom of the viewport
+		if len(m.codeBlocks) > 0 {
+			popup := m.codePickerView()
+			parts[0] = trimLines(parts[0], strings.Count(popup, "\n")+1)
+			parts = append(parts, popup)
+		} else if matches := m.completions(); len(matches) > 0 {
+			popup := m.completionView(matches)
+			parts[0] = trimLines(parts[0], strings.Count(popup, "\n")+1)
+			parts = append(parts, popup)
+		}
 		parts = append(parts, m.textarea.View())
-		parts = append(parts, helpStyle.Render("enter: send | ctrl+h: history | ctrl+d: quit"))
+		help = fmt.Sprintf("enter: send | %s: newline | ctrl+o: editor | ctrl+k: select | ctrl+s: search | ctrl+p: switch | /: commands | ctrl+h: history | ctrl+d: quit",
+			m.keys.Newline.Help().Key)
+		if ctx := m.contextIndicator(); ctx != "" {
+			help = ctx + " | " + help
+		}
+		help = helpStyle.Render(help)
 	}
 
+	// The search bar takes the place of the help line
+	if m.search.active {
+		help = m.searchView()
+	}
+	parts = append(parts, help)
+
 	return strings.Join(parts, "\n")
 }
 
+func (m Model) completionView(matches []Command) string {
+	lines := make([]string, len(matches))
+	for i, c := range matches {
+		name := "/" + c.Name
+		if c.Usage != "" {
+			name += " " + c.Usage
+		}
+		line := fmt.Sprintf("%-20s %s", name, helpStyle.Render(c.Help))
+		if i == m.completed%len(matches) {
+			line = commandStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimLines drops n lines from the end of s, keeping at least one.
+func trimLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	keep := len(lines) - n
+	if keep < 1 {
+		keep = 1
+	}
+	return strings.Join(lines[:keep], "\n")
+}
+
 func (m *Model) updateViewport() {
 	var sb strings.Builder
-	for _, msg := range m.messages {
-		switch msg.Role {
-		case "user":
-			sb.WriteString(userStyle.Render("You:"))
-			sb.WriteString("\n")
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
-		case "assistant":
-			sb.WriteString(assistantStyle.Render("Assistant:"))
-			sb.WriteString("\n")
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
+	line := 0
+	write := func(text string) {
+		sb.WriteString(text)
+		line += strings.Count(text, "\n")
+	}
+
+	m.offsets = make([]int, len(m.messages))
+	for i, msg := range m.messages {
+		m.offsets[i] = line
+		block := m.renderMessage(msg.Role, msg.Content)
+		if msg.Pinned {
+			block = helpStyle.Render(m.wrap(summaryLabel + msg.Content))
+		}
+		if block == "" {
+			continue
 		}
+		if m.selecting {
+			block = gutter(block, i == m.selected)
+		}
+		write(block + "\n\n")
 	}
 	if m.streaming && m.streamBuf.Len() > 0 {
-		sb.WriteString(assistantStyle.Render("Assistant:"))
-		sb.WriteString("\n")
-		sb.WriteString(m.streamBuf.String())
-		sb.WriteString("\n")
+		write(m.renderMessage("assistant", m.streamBuf.String()) + "\n")
 	}
 	if m.err != nil {
-		sb.WriteString(errorStyle.Render("Error: " + m.err.Error()))
-		sb.WriteString("\n")
+		write(m.wrap(errorStyle.Render("Error: "+m.err.Error())) + "\n")
+	}
+	m.viewport.SetContent(m.highlightMatches(sb.String()))
+
+	switch {
+	case len(m.search.matches) > 0:
+		m.scrollTo(m.search.matches[m.search.current].line)
+	case m.search.active:
+		// Keep the position while the query has no matches
+	case m.selecting && m.selected < len(m.offsets):
+		m.scrollTo(m.offsets[m.selected])
+	default:
+		m.viewport.GotoBottom()
+	}
+}
+
+// renderMessage renders a labelled message wrapped to the viewport width.
+// Roles that aren't shown render as "".
+func (m *Model) renderMessage(role, content string) string {
+	switch role {
+	case "user":
+		return userStyle.Render("You:") + "\n" + m.wrap(content)
+	case "assistant":
+		return assistantStyle.Render("Assistant:") + "\n" + m.wrap(content)
+	case "system":
+		return helpStyle.Render(m.wrap("System: " + content))
+	}
+	return ""
+}
+
+// wrap wraps text to the viewport width, leaving room for the selection gutter.
+func (m *Model) wrap(text string) string {
+	width := m.viewport.Width
+	if m.selecting {
+		width -= 2
+	}
+	if width <= 0 {
+		return text
+	}
+	return ansi.Wrap(text, width, "")
+}
+
+// gutter prefixes each line of block with a selection marker or padding.
+func gutter(block string, selected bool) string {
+	prefix := "  "
+	if selected {
+		prefix = selectedStyle.Render("┃ ")
+	}
+	lines := strings.Split(block, "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scrollTo scrolls the viewport so that line is visible.
+func (m *Model) scrollTo(line int) {
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line)
 	}
-	m.viewport.SetContent(sb.String())
-	m.viewport.GotoBottom()
 }
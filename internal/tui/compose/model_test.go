@@ -2,10 +2,16 @@ package compose
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
 )
 
 func TestNewModel(t *testing.T) {
@@ -98,3 +104,276 @@ func TestHelpBarContent(t *testing.T) {
 		t.Error("streaming view should show 'esc: stop'")
 	}
 }
+
+func TestNewlineKeyInsertsNewline(t *testing.T) {
+	m := New(nil, nil)
+	m.SetConfig(&config.Config{UI: config.UI{NewlineKeys: []string{"alt+enter", "ctrl+j"}}})
+	m.SetSize(80, 30)
+	m = typeText(m, "first")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	m = typeText(m, "second")
+
+	if m.textarea.Value() != "first\nsecond" {
+		t.Errorf("expected two-line draft, got %q", m.textarea.Value())
+	}
+	if len(m.messages) != 0 {
+		t.Error("newline key should not send the message")
+	}
+}
+
+func TestConfiguredNewlineKey(t *testing.T) {
+	m := New(nil, nil)
+	m.SetConfig(&config.Config{UI: config.UI{NewlineKeys: []string{"ctrl+j"}}})
+	m = typeText(m, "a")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	if len(m.messages) != 1 {
+		t.Error("alt+enter should send when it isn't a configured newline key")
+	}
+}
+
+func TestInputGrowsWithContent(t *testing.T) {
+	m := New(nil, nil)
+	m.SetConfig(&config.Config{UI: config.UI{MaxInputHeight: 5, NewlineKeys: []string{"ctrl+j"}}})
+	m.SetSize(80, 30)
+	vpHeight := m.viewport.Height
+
+	for i := 0; i < 8; i++ {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlJ})
+	}
+	if m.textarea.Height() != 5 {
+		t.Errorf("expected textarea capped at 5 lines, got %d", m.textarea.Height())
+	}
+	if m.viewport.Height != vpHeight-2 {
+		t.Errorf("expected viewport to shrink by 2 lines, got %d (was %d)", m.viewport.Height, vpHeight)
+	}
+
+	m = typeText(m, "x")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.textarea.Height() != minInputHeight {
+		t.Errorf("expected textarea to shrink after sending, got %d", m.textarea.Height())
+	}
+}
+
+func TestEditorFinishedMsg(t *testing.T) {
+	m := New(nil, nil)
+	m, _ = m.Update(EditorFinishedMsg{Content: "line one\n\nline three"})
+	if m.textarea.Value() != "line one\n\nline three" {
+		t.Errorf("expected draft from editor, got %q", m.textarea.Value())
+	}
+
+	m, _ = m.Update(EditorFinishedMsg{Err: fmt.Errorf("boom")})
+	if m.err == nil {
+		t.Error("editor error should be shown")
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "code --wait")
+	args := editorCommand()
+	if len(args) != 2 || args[0] != "code" || args[1] != "--wait" {
+		t.Errorf("editorCommand() = %v, want [code --wait]", args)
+	}
+
+	t.Setenv("EDITOR", "")
+	if args := editorCommand(); args[0] != "vi" {
+		t.Errorf("editorCommand() = %v, want vi fallback", args)
+	}
+}
+
+func TestDefaultTitleIsRuneSafe(t *testing.T) {
+	long := strings.Repeat("é", 70)
+	title := defaultTitle(long)
+	if !utf8.ValidString(title) {
+		t.Errorf("title is not valid UTF-8: %q", title)
+	}
+	if title != strings.Repeat("é", 60)+"..." {
+		t.Errorf("expected 60 runes and an ellipsis, got %q", title)
+	}
+	if got := defaultTitle("line one\nline  two"); got != "line one line two" {
+		t.Errorf("expected a single-line title, got %q", got)
+	}
+}
+
+func TestGeneratedTitleAfterFirstReply(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Title: "hello there", Provider: "fake", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := New(database, &fakeProvider{})
+	m.SetTitler(&fakeProvider{response: "Greeting Exchange"})
+	m.session = session
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello there"}}
+	m.streaming = true
+
+	m, cmd := m.Update(StreamChunkMsg{Content: "hi!", Done: true})
+	var generated *TitleGeneratedMsg
+	for _, msg := range collectMsgs(cmd) {
+		if g, ok := msg.(TitleGeneratedMsg); ok {
+			generated = &g
+		}
+	}
+	if generated == nil {
+		t.Fatal("expected a title to be generated after the first reply")
+	}
+
+	m, _ = m.Update(*generated)
+	if m.session.Title != "Greeting Exchange" {
+		t.Errorf("expected generated title, got %q", m.session.Title)
+	}
+	stored, err := database.GetSession("s1")
+	if err != nil || stored.Title != "Greeting Exchange" {
+		t.Errorf("expected title stored, got %+v (err %v)", stored, err)
+	}
+
+	// Later replies and renamed sessions keep their title
+	m.streaming = true
+	_, cmd = m.Update(StreamChunkMsg{Content: "again", Done: true})
+	for _, msg := range collectMsgs(cmd) {
+		if _, ok := msg.(TitleGeneratedMsg); ok {
+			t.Error("title should only be generated after the first reply")
+		}
+	}
+}
+
+func TestGeneratedTitleKeepsRename(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Title: "hello there", Provider: "fake", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := New(database, &fakeProvider{})
+	m.SetTitler(&fakeProvider{response: "Greeting Exchange"})
+	m.session = session
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello there"}, {Role: "assistant", Content: "hi!"}}
+	generate := m.titleCmd()
+
+	// Renamed while the title is being generated
+	m = typeText(m, "/title My Title")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	collectMsgs(cmd)
+	if msg := generate(); msg != nil {
+		m, _ = m.Update(msg)
+	}
+	stored, err := database.GetSession("s1")
+	if err != nil || stored.Title != "My Title" || m.session.Title != "My Title" {
+		t.Errorf("expected the rename kept, got %q stored and %q shown (err %v)", stored.Title, m.session.Title, err)
+	}
+}
+
+func TestGeneratedTitleForReplyBeforeSession(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := New(database, &fakeProvider{})
+	m.SetTitler(&fakeProvider{response: "Greeting Exchange"})
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello there"}}
+	m.streaming = true
+	m, _ = m.Update(StreamChunkMsg{Content: "hi!", Done: true})
+
+	// The session is created only after the reply came in
+	created := createSessionCmd(database, m.provider, "")()
+	m, cmd := m.Update(created)
+	var generated bool
+	for _, msg := range collectMsgs(cmd) {
+		if _, ok := msg.(TitleGeneratedMsg); ok {
+			generated = true
+		}
+	}
+	if !generated {
+		t.Error("expected a title to be generated once the session exists")
+	}
+}
+
+func TestNewSessionRecordsPersona(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := New(database, &fakeProvider{})
+	m.SetConfig(&config.Config{Persona: config.Persona{Name: "go-reviewer"}})
+	msg := createSessionCmd(database, m.provider, m.cfg.Persona.Name)()
+	created, ok := msg.(SessionCreatedMsg)
+	if !ok {
+		t.Fatalf("expected SessionCreatedMsg, got %T", msg)
+	}
+	stored, err := database.GetSession(created.Session.ID)
+	if err != nil || stored.Persona != "go-reviewer" {
+		t.Errorf("expected the persona stored, got %+v (err %v)", stored, err)
+	}
+}
+
+func TestAutoExportAfterReply(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Title: "Auto", Provider: "fake", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	dir := t.TempDir()
+	m := New(database, &fakeProvider{})
+	m.SetConfig(&config.Config{
+		Storage: config.Storage{NotesDir: dir},
+		Export:  config.Export{Update: config.ExportOverwrite, Auto: true},
+	})
+	m.session = session
+
+	reply := func(content string) {
+		t.Helper()
+		m.messages = append(m.messages, DisplayMessage{Role: "user", Content: "question"})
+		m.streaming = true
+		var cmd tea.Cmd
+		m, cmd = m.Update(StreamChunkMsg{Content: content, Done: true})
+		for _, msg := range collectMsgs(cmd) {
+			saved, ok := msg.(MessageSavedMsg)
+			if !ok {
+				continue
+			}
+			var exportCmd tea.Cmd
+			m, exportCmd = m.Update(saved)
+			for _, msg := range collectMsgs(exportCmd) {
+				if done, ok := msg.(CommandDoneMsg); ok && done.Err != nil {
+					t.Fatalf("auto-export failed: %v", done.Err)
+				}
+			}
+		}
+	}
+
+	reply("first answer")
+	reply("second answer")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one exported file, got %v (err %v)", entries, err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if !strings.Contains(string(content), "second answer") {
+		t.Errorf("expected the export to be updated after each reply:\n%s", content)
+	}
+}
@@ -0,0 +1,225 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	matchStyle        = lipgloss.NewStyle().Background(lipgloss.Color("58")).Foreground(lipgloss.Color("230"))
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("214")).Foreground(lipgloss.Color("16"))
+)
+
+// searchHelp is shown while browsing search results
+const searchHelp = "n: next | N: previous | /: new search | esc: clear"
+
+// searchMatch is the position of a match in the rendered viewport content.
+type searchMatch struct {
+	line int // viewport line
+	col  int // rune offset in the line with styling removed
+}
+
+// searchState holds the incremental search over the rendered conversation.
+type searchState struct {
+	input   textinput.Model
+	typing  bool // the query input has focus
+	active  bool // results are highlighted and n/N navigate them
+	query   string
+	matches []searchMatch
+	current int
+}
+
+func newSearchInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search conversation"
+	return ti
+}
+
+// startSearch opens the search input.
+func (m *Model) startSearch() tea.Cmd {
+	m.search.typing = true
+	m.search.active = true
+	m.search.input.SetValue(m.search.query)
+	m.search.input.CursorEnd()
+	m.textarea.Blur()
+	return m.search.input.Focus()
+}
+
+// stopSearch closes the search and removes the highlights.
+func (m *Model) stopSearch() {
+	m.search.typing = false
+	m.search.active = false
+	m.search.query = ""
+	m.search.matches = nil
+	m.search.input.Blur()
+	if !m.selecting {
+		m.textarea.Focus()
+	}
+	m.updateViewport()
+}
+
+// updateSearch handles keys while the search input or results are active.
+// It reports false when the key should be handled by the rest of the view.
+func (m *Model) updateSearch(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if m.search.typing {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.stopSearch()
+			return nil, true
+		case tea.KeyEnter:
+			m.search.typing = false
+			m.search.input.Blur()
+			if len(m.search.matches) == 0 {
+				m.stopSearch()
+			}
+			return nil, true
+		}
+		var cmd tea.Cmd
+		m.search.input, cmd = m.search.input.Update(msg)
+		if q := m.search.input.Value(); q != m.search.query {
+			top := m.viewport.YOffset
+			m.search.query = q
+			m.search.current = 0
+			m.updateViewport()
+			m.jumpToMatchFrom(top)
+		}
+		return cmd, true
+	}
+
+	switch msg.String() {
+	case "n":
+		m.nextMatch(1)
+		return nil, true
+	case "N":
+		m.nextMatch(-1)
+		return nil, true
+	case "/", "ctrl+s":
+		return m.startSearch(), true
+	case "esc":
+		m.stopSearch()
+		return nil, true
+	}
+
+	// Any other key ends the search and is handled as usual
+	m.stopSearch()
+	return nil, false
+}
+
+// nextMatch moves to the next (delta 1) or previous (delta -1) match, wrapping around.
+func (m *Model) nextMatch(delta int) {
+	if len(m.search.matches) == 0 {
+		return
+	}
+	n := len(m.search.matches)
+	m.search.current = ((m.search.current+delta)%n + n) % n
+	m.updateViewport()
+}
+
+// jumpToMatchFrom selects the first match at or below line top, so refining
+// the query doesn't scroll away from what the user was reading.
+func (m *Model) jumpToMatchFrom(top int) {
+	for i, match := range m.search.matches {
+		if match.line >= top {
+			if i != m.search.current {
+				m.search.current = i
+				m.updateViewport()
+			}
+			return
+		}
+	}
+}
+
+// searchStatus summarises the match position for the help line.
+func (m Model) searchStatus() string {
+	if m.search.query == "" {
+		return ""
+	}
+	if len(m.search.matches) == 0 {
+		return "no matches"
+	}
+	return fmt.Sprintf("match %d/%d", m.search.current+1, len(m.search.matches))
+}
+
+// searchView renders the search input or, once the query is entered, the
+// result navigation help.
+func (m Model) searchView() string {
+	status := m.searchStatus()
+	if m.search.typing {
+		if status != "" {
+			return m.search.input.View() + "  " + helpStyle.Render(status)
+		}
+		return m.search.input.View()
+	}
+	return helpStyle.Render(status + " | " + searchHelp)
+}
+
+// highlightMatches finds the search query in the rendered content, records
+// the matches and returns the content with them highlighted. Matching runs
+// on the text with styling removed, so it works for any rendering.
+func (m *Model) highlightMatches(content string) string {
+	m.search.matches = nil
+	query := m.search.query
+	if query == "" {
+		return content
+	}
+
+	// Smart case: an uppercase letter in the query makes it case-sensitive
+	fold := strings.IndexFunc(query, unicode.IsUpper) < 0
+	needle := []rune(query)
+	if fold {
+		needle = []rune(strings.ToLower(query))
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		plain := []rune(ansi.Strip(line))
+		hay := plain
+		if fold {
+			hay = []rune(strings.ToLower(string(plain)))
+		}
+
+		var cols []int
+		for col := 0; col+len(needle) <= len(hay); {
+			if string(hay[col:col+len(needle)]) == string(needle) {
+				cols = append(cols, col)
+				col += len(needle)
+				continue
+			}
+			col++
+		}
+		if len(cols) == 0 {
+			continue
+		}
+
+		// Cut the styled line around the matches. Each cut keeps the escape
+		// sequences before it, so the styling resumes after a highlight.
+		var sb strings.Builder
+		prev := 0
+		for _, col := range cols {
+			style := matchStyle
+			if len(m.search.matches) == m.search.current {
+				style = currentMatchStyle
+			}
+			m.search.matches = append(m.search.matches, searchMatch{line: i, col: col})
+			start := ansi.StringWidth(string(plain[:col]))
+			end := start + ansi.StringWidth(string(plain[col:col+len(needle)]))
+			sb.WriteString(ansi.Cut(line, prev, start))
+			sb.WriteString(style.Render(string(plain[col : col+len(needle)])))
+			prev = end
+		}
+		sb.WriteString(ansi.Cut(line, prev, ansi.StringWidth(line)))
+		lines[i] = sb.String()
+	}
+
+	if m.search.current >= len(m.search.matches) {
+		m.search.current = 0
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,143 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func searchFor(m Model, query string) Model {
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	return typeText(m, query)
+}
+
+func TestSearchFindsMatches(t *testing.T) {
+	m := conversationModel()
+	m = searchFor(m, "answer")
+
+	if len(m.search.matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(m.search.matches))
+	}
+	if m.textarea.Value() != "" {
+		t.Errorf("search query should not be typed into the draft, got %q", m.textarea.Value())
+	}
+	if !strings.Contains(m.View(), "match 1/2") {
+		t.Error("view should show the match position")
+	}
+}
+
+func TestSearchSmartCase(t *testing.T) {
+	m := conversationModel()
+	m = searchFor(m, "first")
+	if len(m.search.matches) != 2 {
+		t.Errorf("lowercase query should ignore case, got %d matches", len(m.search.matches))
+	}
+
+	m = conversationModel()
+	m.messages[0].Content = "First question"
+	m.updateViewport()
+	m = searchFor(m, "First")
+	if len(m.search.matches) != 1 {
+		t.Errorf("query with uppercase should match case, got %d matches", len(m.search.matches))
+	}
+}
+
+func TestSearchIgnoresStyling(t *testing.T) {
+	m := conversationModel()
+	// Labels are rendered with ANSI styling
+	m = searchFor(m, "assistant:")
+	if len(m.search.matches) != 2 {
+		t.Errorf("expected matches in styled labels, got %d", len(m.search.matches))
+	}
+}
+
+func TestSearchKeepsStyling(t *testing.T) {
+	m := New(nil, nil)
+	m.search.query = "bar"
+	bold := "\x1b[1m"
+	line := bold + "foo bar baz\x1b[0m"
+	got := m.highlightMatches(line)
+
+	if ansi.Strip(got) != "foo bar baz" {
+		t.Errorf("highlighting changed the text: %q", ansi.Strip(got))
+	}
+	before, after, ok := strings.Cut(got, "bar")
+	if !ok || !strings.HasPrefix(before, bold+"foo ") {
+		t.Errorf("expected the styling kept before the match, got %q", got)
+	}
+	if !strings.Contains(after, bold) || !strings.HasSuffix(after, " baz\x1b[0m") {
+		t.Errorf("expected the styling resumed after the match, got %q", got)
+	}
+}
+
+func TestSearchNextPrevious(t *testing.T) {
+	m := conversationModel()
+	m = searchFor(m, "question")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.search.active || m.search.typing {
+		t.Fatal("enter should keep the results and close the input")
+	}
+
+	m, _ = pressKey(m, "n")
+	if m.search.current != 1 {
+		t.Errorf("n should move to the second match, got %d", m.search.current)
+	}
+	m, _ = pressKey(m, "n")
+	if m.search.current != 0 {
+		t.Errorf("n should wrap to the first match, got %d", m.search.current)
+	}
+	m, _ = pressKey(m, "N")
+	if m.search.current != 1 {
+		t.Errorf("N should wrap to the last match, got %d", m.search.current)
+	}
+}
+
+func TestSearchScrollsToMatch(t *testing.T) {
+	m := New(nil, nil)
+	m.SetSize(80, 8)
+	m.messages = append(m.messages, DisplayMessage{Role: "user", Content: "needle"})
+	for i := 0; i < 10; i++ {
+		m.messages = append(m.messages, DisplayMessage{Role: "user", Content: "hay"})
+	}
+	m.updateViewport()
+
+	m = searchFor(m, "needle")
+	if len(m.search.matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(m.search.matches))
+	}
+	line := m.search.matches[0].line
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		t.Errorf("match on line %d not visible at offset %d", line, m.viewport.YOffset)
+	}
+}
+
+func TestSearchEscClears(t *testing.T) {
+	m := conversationModel()
+	m = searchFor(m, "answer")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = pressKey(m, "esc")
+
+	if m.search.active || len(m.search.matches) != 0 {
+		t.Error("esc should clear the search")
+	}
+	m = typeText(m, "n")
+	if m.textarea.Value() != "n" {
+		t.Errorf("typing should reach the draft after the search, got %q", m.textarea.Value())
+	}
+}
+
+func TestSearchFromSelection(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "/")
+	m = typeText(m, "second")
+	if len(m.search.matches) != 2 {
+		t.Errorf("expected 2 matches, got %d", len(m.search.matches))
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.selecting {
+		t.Error("clearing the search should return to selection mode")
+	}
+}
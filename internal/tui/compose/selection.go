@@ -0,0 +1,256 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// selectionHelp is shown in place of the textarea help while selecting
+const selectionHelp = "j/k: move | /: search | y: copy | c: copy code | w: write files | e: edit | r: regenerate | d: delete | f: fork | >: quote | esc: done"
+
+// SessionForkedMsg is sent when a conversation has been forked into a new session.
+type SessionForkedMsg struct {
+	Session  *db.Session
+	Messages []db.Message
+}
+
+// startSelection enters selection mode with the last message selected.
+func (m *Model) startSelection() tea.Cmd {
+	if len(m.messages) == 0 {
+		return m.fail(fmt.Errorf("no messages to select"))
+	}
+	m.selecting = true
+	m.selected = len(m.messages) - 1
+	m.textarea.Blur()
+	m.updateViewport()
+	return nil
+}
+
+// stopSelection leaves selection mode and returns focus to the draft.
+func (m *Model) stopSelection() {
+	m.selecting = false
+	m.textarea.Focus()
+	m.updateViewport()
+}
+
+// updateSelection handles keys while a message is selected.
+func (m *Model) updateSelection(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+k":
+		m.stopSelection()
+		return nil
+	case "j", "down":
+		m.moveSelection(1)
+		return nil
+	case "k", "up":
+		m.moveSelection(-1)
+		return nil
+	case "g", "home":
+		m.moveSelection(-len(m.messages))
+		return nil
+	case "G", "end":
+		m.moveSelection(len(m.messages))
+		return nil
+	case "/":
+		return m.startSearch()
+	}
+
+	selected := m.messages[m.selected]
+	switch msg.String() {
+	case "y":
+		return copyCmd(selected.Content, m.clipboardCmd(), "Copied message")
+	case "c":
+		return m.copyCode(selected.Content, 0)
+	case "w":
+		return m.startFileWriter(selected.Content, "")
+	case ">":
+		m.quote(selected.Content)
+		m.stopSelection()
+		return nil
+	}
+
+	if m.streaming {
+		switch msg.String() {
+		case "e", "r", "d", "f":
+			return m.fail(fmt.Errorf("wait for the response to finish"))
+		}
+		return nil
+	}
+
+	switch msg.String() {
+	case "e":
+		return m.editSelected()
+	case "r":
+		return m.regenerateSelected()
+	case "d":
+		return m.deleteSelected()
+	case "f":
+		return m.forkSelected()
+	}
+	return nil
+}
+
+// moveSelection moves the selection by delta messages, clamped to the conversation.
+func (m *Model) moveSelection(delta int) {
+	m.selected += delta
+	if m.selected < 0 {
+		m.selected = 0
+	}
+	if m.selected >= len(m.messages) {
+		m.selected = len(m.messages) - 1
+	}
+	m.updateViewport()
+}
+
+// truncate drops messages from index i onward, deleting any that were saved.
+// Those still being saved are deleted when their save comes back.
+func (m *Model) truncate(i int) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, dm := range m.messages[i:] {
+		if dm.ID != 0 && m.db != nil {
+			cmds = append(cmds, deleteMessageCmd(m.db, dm.ID))
+		}
+	}
+	m.messages = m.messages[:i]
+	return tea.Batch(cmds...)
+}
+
+// saveCmd stores the message at index i in the session. The message gets a
+// key, so the save finds it again even if messages before it are removed
+// in the meantime.
+func (m *Model) saveCmd(i int) tea.Cmd {
+	if m.messages[i].key == 0 {
+		m.lastKey++
+		m.messages[i].key = m.lastKey
+	}
+	return saveMessageCmd(m.db, m.session.ID, m.messages[i])
+}
+
+// messageIndex returns the index of the message with key, or -1 if it is
+// no longer in the conversation.
+func (m *Model) messageIndex(key int) int {
+	for i, dm := range m.messages {
+		if dm.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasID reports whether a message in the conversation is stored as id.
+func (m *Model) hasID(id int64) bool {
+	for _, dm := range m.messages {
+		if dm.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// editSelected moves a user message back into the draft, dropping it and
+// everything after it so the conversation continues from the edit.
+func (m *Model) editSelected() tea.Cmd {
+	selected := m.messages[m.selected]
+	if selected.Role != "user" {
+		return m.fail(fmt.Errorf("only your own messages can be edited"))
+	}
+	cmd := m.truncate(m.selected)
+	m.textarea.SetValue(selected.Content)
+	m.resizeInput()
+	m.stopSelection()
+	return cmd
+}
+
+// regenerateSelected discards the selected response (or everything after the
+// selected prompt) and streams a new response.
+func (m *Model) regenerateSelected() tea.Cmd {
+	cut := m.selected
+	if m.messages[cut].Role == "user" {
+		cut++
+	}
+	if cut == 0 || m.messages[cut-1].Role != "user" {
+		return m.fail(fmt.Errorf("select a response or one of your messages to regenerate"))
+	}
+	cmd := m.truncate(cut)
+	m.selecting = false
+	m.textarea.Focus()
+	m.err = nil
+	return tea.Batch(cmd, m.send())
+}
+
+// deleteSelected removes the selected message from the conversation.
+func (m *Model) deleteSelected() tea.Cmd {
+	selected := m.messages[m.selected]
+	m.messages = append(m.messages[:m.selected:m.selected], m.messages[m.selected+1:]...)
+
+	var cmd tea.Cmd
+	if selected.ID != 0 && m.db != nil {
+		cmd = deleteMessageCmd(m.db, selected.ID)
+	}
+	if len(m.messages) == 0 {
+		m.stopSelection()
+		return cmd
+	}
+	m.moveSelection(0)
+	return cmd
+}
+
+// forkSelected copies the conversation up to and including the selected
+// message into a new session and continues there.
+func (m *Model) forkSelected() tea.Cmd {
+	if m.db == nil {
+		return m.fail(fmt.Errorf("forking needs a database"))
+	}
+	return forkSessionCmd(m.db, m.session, m.provider, m.messages[:m.selected+1])
+}
+
+// quote inserts content into the draft as a Markdown blockquote.
+func (m *Model) quote(content string) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	m.textarea.InsertString(strings.Join(lines, "\n") + "\n\n")
+	m.resizeInput()
+}
+
+// resetConversation drops the conversation and all state that belongs to
+// it, such as the selection, the search and unsaved budget decisions.
+func (m *Model) resetConversation() {
+	m.messages = nil
+	m.session = nil
+	m.streamBuf.Reset()
+	m.usage = nil
+	m.promptTokens = 0
+	m.limitPrompt = nil
+	m.decisions = nil
+	m.codeBlocks = nil
+	m.writer = nil
+	m.selecting = false
+	m.selected = 0
+	m.offsets = nil
+	m.search = searchState{input: newSearchInput()}
+	m.err = nil
+}
+
+// LoadSession replaces the conversation with a stored session and its messages.
+func (m *Model) LoadSession(session db.Session, messages []db.Message) {
+	m.resetConversation()
+	m.session = &session
+	m.messages = make([]DisplayMessage, 0, len(messages))
+	for _, msg := range messages {
+		m.messages = append(m.messages, DisplayMessage{
+			ID:         msg.ID,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Tokens:     msg.Tokens,
+			Pinned:     msg.Pinned,
+			Summarized: msg.Summarized,
+		})
+	}
+	placeSummaries(m.messages)
+	m.updateViewport()
+}
@@ -0,0 +1,330 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func conversationModel() Model {
+	m := New(nil, nil)
+	m.SetSize(80, 20)
+	m.messages = []DisplayMessage{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+		{Role: "user", Content: "second question"},
+		{Role: "assistant", Content: "second answer"},
+	}
+	m.updateViewport()
+	return m
+}
+
+func pressKey(m Model, k string) (Model, tea.Cmd) {
+	switch k {
+	case "ctrl+k":
+		return m.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	case "esc":
+		return m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	}
+	return m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+}
+
+func TestSelectionModeNavigation(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	if !m.selecting || m.selected != 3 {
+		t.Fatalf("expected selection on last message, got selecting=%v selected=%d", m.selecting, m.selected)
+	}
+
+	m, _ = pressKey(m, "k")
+	m, _ = pressKey(m, "k")
+	if m.selected != 1 {
+		t.Errorf("expected selected=1 after moving up twice, got %d", m.selected)
+	}
+
+	m, _ = pressKey(m, "g")
+	if m.selected != 0 {
+		t.Errorf("expected selected=0 after g, got %d", m.selected)
+	}
+	m, _ = pressKey(m, "k")
+	if m.selected != 0 {
+		t.Errorf("selection should stop at the first message, got %d", m.selected)
+	}
+
+	m, _ = pressKey(m, "esc")
+	if m.selecting {
+		t.Error("esc should leave selection mode")
+	}
+}
+
+func TestSelectionTypingDoesNotReachDraft(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "j")
+	if m.textarea.Value() != "" {
+		t.Errorf("keys in selection mode should not be typed, got %q", m.textarea.Value())
+	}
+}
+
+func TestLineOffsets(t *testing.T) {
+	m := conversationModel()
+	if len(m.offsets) != 4 {
+		t.Fatalf("expected 4 offsets, got %d", len(m.offsets))
+	}
+	for i := 1; i < len(m.offsets); i++ {
+		if m.offsets[i] <= m.offsets[i-1] {
+			t.Errorf("offsets should increase, got %v", m.offsets)
+		}
+	}
+
+	// Each message is a label line, its content line and a blank separator
+	if m.offsets[1] != 3 {
+		t.Errorf("expected second message at line 3, got %d", m.offsets[1])
+	}
+}
+
+func TestSelectionScrollsToMessage(t *testing.T) {
+	m := New(nil, nil)
+	m.SetSize(80, 8)
+	for i := 0; i < 10; i++ {
+		m.messages = append(m.messages, DisplayMessage{Role: "user", Content: "message"})
+	}
+	m.updateViewport()
+
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "g")
+	if m.viewport.YOffset != 0 {
+		t.Errorf("expected viewport scrolled to top, got offset %d", m.viewport.YOffset)
+	}
+}
+
+func TestLongLinesWrap(t *testing.T) {
+	m := New(nil, nil)
+	m.SetSize(20, 20)
+	m.messages = []DisplayMessage{{Role: "user", Content: strings.Repeat("word ", 10)}}
+	m.updateViewport()
+	for _, line := range strings.Split(m.viewport.View(), "\n") {
+		if w := len(strings.TrimRight(line, " ")); w > 20 {
+			t.Errorf("line wider than viewport: %q", line)
+		}
+	}
+}
+
+func TestSelectionHighlight(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	if !strings.Contains(m.viewport.View(), "┃") {
+		t.Error("selected message should be marked in the viewport")
+	}
+}
+
+func TestSelectionEdit(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "k")
+	m, _ = pressKey(m, "e")
+
+	if m.textarea.Value() != "second question" {
+		t.Errorf("expected message in draft, got %q", m.textarea.Value())
+	}
+	if len(m.messages) != 2 {
+		t.Errorf("expected conversation truncated to 2 messages, got %d", len(m.messages))
+	}
+	if m.selecting {
+		t.Error("edit should leave selection mode")
+	}
+}
+
+func TestSelectionEditAssistantFails(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "e")
+	if m.err == nil || len(m.messages) != 4 {
+		t.Error("editing a response should fail and keep the conversation")
+	}
+}
+
+func TestSelectionRegenerate(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "k")
+	m, _ = pressKey(m, "k")
+	m, _ = pressKey(m, "r")
+
+	if len(m.messages) != 1 || m.messages[0].Content != "first question" {
+		t.Errorf("expected conversation cut back to the first prompt, got %+v", m.messages)
+	}
+	if !m.streaming {
+		t.Error("regenerate should start streaming")
+	}
+}
+
+func TestSelectionDelete(t *testing.T) {
+	m := conversationModel()
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "d")
+	if len(m.messages) != 3 {
+		t.Fatalf("expected 3 messages after delete, got %d", len(m.messages))
+	}
+	if m.selected != 2 {
+		t.Errorf("selection should move to the new last message, got %d", m.selected)
+	}
+}
+
+func TestSelectionQuote(t *testing.T) {
+	m := conversationModel()
+	m.messages[3].Content = "line one\nline two"
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, ">")
+	if m.textarea.Value() != "> line one\n> line two\n\n" {
+		t.Errorf("expected quoted draft, got %q", m.textarea.Value())
+	}
+	if m.selecting {
+		t.Error("quote should leave selection mode")
+	}
+}
+
+func TestSelectionFork(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	parent := &db.Session{ID: "parent", Title: "Original", Provider: "test", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(parent); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := conversationModel()
+	m.db = database
+	m.session = parent
+	m, _ = pressKey(m, "ctrl+k")
+	m, _ = pressKey(m, "k")
+	m, _ = pressKey(m, "k")
+	m, cmd := pressKey(m, "f")
+	if cmd == nil {
+		t.Fatal("expected fork command")
+	}
+
+	forked, ok := cmd().(SessionForkedMsg)
+	if !ok {
+		t.Fatal("expected SessionForkedMsg")
+	}
+	m, _ = m.Update(forked)
+
+	if m.session.ParentID != "parent" || m.session.Title != "Original (fork)" {
+		t.Errorf("unexpected fork session: %+v", m.session)
+	}
+	if len(m.messages) != 2 || m.messages[1].Content != "first answer" || m.messages[1].ID == 0 {
+		t.Errorf("expected fork to contain the first exchange with IDs, got %+v", m.messages)
+	}
+
+	stored, err := database.GetSessionMessages(m.session.ID)
+	if err != nil || len(stored) != 2 {
+		t.Errorf("expected 2 stored messages in fork, got %d (err %v)", len(stored), err)
+	}
+}
+
+func TestTruncateDropsMessagesBeingSaved(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Provider: "test", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := conversationModel()
+	m.db = database
+	m.session = session
+	last := len(m.messages) - 1
+	save := m.saveCmd(last)
+
+	// The last message is dropped before its save comes back
+	m.truncate(last)
+	m.messages = append(m.messages, DisplayMessage{Role: "assistant", Content: "new answer"})
+	m, cmd := m.Update(save())
+	if m.messages[last].ID != 0 {
+		t.Errorf("late save stamped ID %d onto the message now at its index", m.messages[last].ID)
+	}
+	for _, msg := range collectMsgs(cmd) {
+		m, _ = m.Update(msg)
+	}
+	stored, err := database.GetSessionMessages("s1")
+	if err != nil || len(stored) != 0 {
+		t.Errorf("expected the orphan row deleted, got %d rows (err %v)", len(stored), err)
+	}
+
+	// Saves of messages before the cut still record their IDs
+	save = m.saveCmd(0)
+	m.truncate(last)
+	m, _ = m.Update(save())
+	if m.messages[0].ID == 0 {
+		t.Error("expected the kept message to get its ID")
+	}
+}
+
+func TestDeleteKeepsSavesInFlight(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Provider: "test", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := conversationModel()
+	m.db = database
+	m.session = session
+	last := len(m.messages) - 1
+	saveLast := m.saveCmd(last)
+	saveFirst := m.saveCmd(0)
+
+	// Deleting a message before the one being saved shifts it down
+	m.selecting = true
+	m.selected = 1
+	m.deleteSelected()
+	m, _ = m.Update(saveLast())
+	if m.messages[last-1].ID == 0 {
+		t.Error("expected the shifted message to get its ID")
+	}
+
+	// A deleted message's save removes its row again
+	m.selected = 0
+	m.deleteSelected()
+	m, cmd := m.Update(saveFirst())
+	for _, msg := range collectMsgs(cmd) {
+		m, _ = m.Update(msg)
+	}
+	stored, err := database.GetSessionMessages("s1")
+	if err != nil || len(stored) != 1 || stored[0].ID != m.messages[len(m.messages)-1].ID {
+		t.Errorf("expected only the kept message stored, got %+v (err %v)", stored, err)
+	}
+}
+
+func TestLoadSession(t *testing.T) {
+	m := New(nil, nil)
+	m.LoadSession(db.Session{ID: "s1"}, []db.Message{
+		{ID: 1, Role: "user", Content: "hi"},
+		{ID: 2, Role: "assistant", Content: "hello"},
+	})
+	if m.session == nil || m.session.ID != "s1" {
+		t.Fatal("expected session to be loaded")
+	}
+	if len(m.messages) != 2 || m.messages[1].ID != 2 {
+		t.Errorf("expected messages with IDs, got %+v", m.messages)
+	}
+}
@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Command is a slash command that can be typed into the compose textarea
+// as "/name args" instead of being sent to the LLM.
+type Command struct {
+	Name  string // name without the leading slash
+	Usage string // argument hint shown in the completion popup, e.g. "<text>"
+	Help  string // one-line description
+	Run   func(m *Model, args string) tea.Cmd
+}
+
+// Registry holds the slash commands available in the compose view.
+type Registry struct {
+	commands map[string]Command
+}
+
+// NewRegistry creates an empty command registry.
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]Command)}
+}
+
+// Register adds a command, replacing any existing command with the same name.
+func (r *Registry) Register(c Command) {
+	r.commands[c.Name] = c
+}
+
+// Lookup returns the command with the given name.
+func (r *Registry) Lookup(name string) (Command, bool) {
+	c, ok := r.commands[name]
+	return c, ok
+}
+
+// Complete returns the commands whose names start with prefix, sorted by name.
+func (r *Registry) Complete(prefix string) []Command {
+	var matches []Command
+	for name, c := range r.commands {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	return matches
+}
+
+// parseCommand splits "/name args" into its name and arguments. Input that
+// doesn't start with a single slash (including "//" escapes) is not a command.
+func parseCommand(input string) (name, args string, ok bool) {
+	if !strings.HasPrefix(input, "/") || strings.HasPrefix(input, "//") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(input[1:], " ")
+	return name, strings.TrimSpace(args), name != ""
+}
+
+// defaultCommands returns the registry of built-in slash commands.
+func defaultCommands() *Registry {
+	r := NewRegistry()
+	r.Register(Command{Name: "model", Usage: "[provider]", Help: "switch provider/model", Run: cmdModel})
+	r.Register(Command{Name: "new", Help: "start a new conversation", Run: cmdNew})
+	r.Register(Command{Name: "title", Usage: "<text>", Help: "rename this conversation", Run: cmdTitle})
+	r.Register(Command{Name: "export", Usage: "[md|json|jsonl|html]", Help: "export this conversation (Markdown by default)", Run: cmdExport})
+	r.Register(Command{Name: "system", Usage: "<prompt>", Help: "add a system instruction", Run: cmdSystem})
+	r.Register(Command{Name: "clear", Help: "clear the conversation and its context", Run: cmdClear})
+	r.Register(Command{Name: "retry", Help: "regenerate the last response", Run: cmdRetry})
+	r.Register(Command{Name: "copy", Usage: "[N | code [N]]", Help: "copy the last response, message N or a code block", Run: cmdCopy})
+	r.Register(Command{Name: "write", Usage: "[dir]", Help: "write the code blocks of the last response to files", Run: cmdWrite})
+	r.Register(Command{Name: "edit", Help: "open the draft in $EDITOR", Run: cmdEdit})
+	return r
+}
+
+func cmdModel(m *Model, args string) tea.Cmd {
+	return func() tea.Msg {
+		return SelectModelMsg{ProviderName: args}
+	}
+}
+
+func cmdNew(m *Model, args string) tea.Cmd {
+	return func() tea.Msg {
+		return NewChatMsg{}
+	}
+}
+
+func cmdTitle(m *Model, args string) tea.Cmd {
+	if args == "" {
+		return m.fail(fmt.Errorf("usage: /title <text>"))
+	}
+	if m.session == nil || m.db == nil {
+		return m.fail(fmt.Errorf("no conversation to rename yet"))
+	}
+	m.session.Title = args
+	return setTitleCmd(m.db, m.session.ID, args)
+}
+
+func cmdExport(m *Model, args string) tea.Cmd {
+	if m.session == nil || m.db == nil {
+		return m.fail(fmt.Errorf("no conversation to export yet"))
+	}
+	if args == "" {
+		args = "md"
+	}
+	return exportCmd(m.db, m.session.ID, m.notesDir(), args, m.exportOptions(), m.exportAppends())
+}
+
+func cmdSystem(m *Model, args string) tea.Cmd {
+	if args == "" {
+		return m.fail(fmt.Errorf("usage: /system <prompt>"))
+	}
+	m.messages = append(m.messages, DisplayMessage{Role: "system", Content: args})
+	m.updateViewport()
+
+	var cmds []tea.Cmd
+	if m.session != nil && m.db != nil {
+		cmds = append(cmds, m.saveCmd(len(m.messages)-1))
+	}
+	cmds = append(cmds, flashCmd("System instruction added"))
+	return tea.Batch(cmds...)
+}
+
+func cmdClear(m *Model, args string) tea.Cmd {
+	m.resetConversation()
+	m.updateViewport()
+	return flashCmd("Conversation cleared")
+}
+
+func cmdRetry(m *Model, args string) tea.Cmd {
+	if m.streaming {
+		return m.fail(fmt.Errorf("cannot retry while a response is streaming"))
+	}
+
+	// Drop everything after the last user message and send it again
+	last := -1
+	for i, dm := range m.messages {
+		if dm.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return m.fail(fmt.Errorf("nothing to retry"))
+	}
+
+	cmd := m.truncate(last + 1)
+	m.err = nil
+	return tea.Batch(cmd, m.send())
+}
+
+func cmdWrite(m *Model, args string) tea.Cmd {
+	content, ok := m.lastResponse()
+	if !ok {
+		return m.fail(fmt.Errorf("no response to write files from"))
+	}
+	return m.startFileWriter(content, args)
+}
+
+func cmdCopy(m *Model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	// /copy code [N] copies a code block from the last response
+	if len(fields) > 0 && fields[0] == "code" {
+		content, ok := m.lastResponse()
+		if !ok {
+			return m.fail(fmt.Errorf("no response to copy from"))
+		}
+		n := 0
+		if len(fields) > 1 {
+			var err error
+			if n, err = strconv.Atoi(fields[1]); err != nil || n < 1 {
+				return m.fail(fmt.Errorf("usage: /copy code [N]"))
+			}
+		}
+		return m.copyCode(content, n)
+	}
+
+	// /copy N copies the Nth message
+	if len(fields) > 0 {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n < 1 {
+			return m.fail(fmt.Errorf("usage: /copy [N | code [N]]"))
+		}
+		return m.copyMessage(n)
+	}
+
+	content, ok := m.lastResponse()
+	if !ok {
+		return m.fail(fmt.Errorf("no response to copy"))
+	}
+	return copyCmd(content, m.clipboardCmd(), "Copied last response")
+}
+
+func cmdEdit(m *Model, args string) tea.Cmd {
+	return openEditorCmd(args)
+}
@@ -0,0 +1,192 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// typeText sends s to the model one rune at a time, as a user would type it.
+func typeText(m Model, s string) Model {
+	for _, r := range s {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return m
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantName string
+		wantArgs string
+		wantOK   bool
+	}{
+		{"/model", "model", "", true},
+		{"/title  My chat ", "title", "My chat", true},
+		{"//not a command", "", "", false},
+		{"hello /model", "", "", false},
+		{"/", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, args, ok := parseCommand(tt.input)
+		if name != tt.wantName || args != tt.wantArgs || ok != tt.wantOK {
+			t.Errorf("parseCommand(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.input, name, args, ok, tt.wantName, tt.wantArgs, tt.wantOK)
+		}
+	}
+}
+
+func TestRegistryComplete(t *testing.T) {
+	r := defaultCommands()
+	matches := r.Complete("c")
+	if len(matches) != 2 || matches[0].Name != "clear" || matches[1].Name != "copy" {
+		t.Errorf("Complete(\"c\") = %+v, want clear and copy", matches)
+	}
+	if len(r.Complete("")) != 10 {
+		t.Errorf("Complete(\"\") returned %d commands, want 10", len(r.Complete("")))
+	}
+}
+
+func TestSlashCommandNotSentToLLM(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/new")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if len(m.messages) != 0 {
+		t.Errorf("slash command should not be added to the conversation, got %d messages", len(m.messages))
+	}
+	if m.streaming {
+		t.Error("slash command should not start streaming")
+	}
+	if m.textarea.Value() != "" {
+		t.Errorf("textarea should be cleared, got %q", m.textarea.Value())
+	}
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	if _, ok := cmd().(NewChatMsg); !ok {
+		t.Error("/new should produce NewChatMsg")
+	}
+}
+
+func TestSlashModelWithProvider(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/model openai")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	msg, ok := cmd().(SelectModelMsg)
+	if !ok {
+		t.Fatal("/model should produce SelectModelMsg")
+	}
+	if msg.ProviderName != "openai" {
+		t.Errorf("expected provider 'openai', got %q", msg.ProviderName)
+	}
+}
+
+func TestSlashUniquePrefix(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/ne")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected unique prefix to run the command")
+	}
+	if _, ok := cmd().(NewChatMsg); !ok {
+		t.Error("/ne should run /new")
+	}
+}
+
+func TestSlashUnknownCommand(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/bogus")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.err == nil || !strings.Contains(m.err.Error(), "unknown command") {
+		t.Errorf("expected unknown command error, got %v", m.err)
+	}
+}
+
+func TestSlashEscapeSendsLiteral(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "//etc/hosts")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.messages) != 1 || m.messages[0].Content != "/etc/hosts" {
+		t.Errorf("expected literal message '/etc/hosts', got %+v", m.messages)
+	}
+}
+
+func TestSlashSystemAddsMessage(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/system Answer in French.")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.messages) != 1 || m.messages[0].Role != "system" || m.messages[0].Content != "Answer in French." {
+		t.Errorf("expected a system message, got %+v", m.messages)
+	}
+}
+
+func TestSlashClear(t *testing.T) {
+	m := New(nil, nil)
+	m.messages = []DisplayMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	m.decisions = []db.BudgetDecision{{Reason: "daily budget", Decision: db.DecisionConfirmed}}
+	m.search.query = "hi"
+	m = typeText(m, "/clear")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.messages) != 0 {
+		t.Errorf("expected conversation cleared, got %d messages", len(m.messages))
+	}
+	if len(m.decisions) != 0 {
+		t.Errorf("expected the conversation's budget decisions dropped, got %+v", m.decisions)
+	}
+	if m.search.query != "" {
+		t.Errorf("expected the search cleared, got %q", m.search.query)
+	}
+}
+
+func TestSlashRetryDropsLastResponse(t *testing.T) {
+	m := New(nil, nil)
+	m.messages = []DisplayMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	m = typeText(m, "/retry")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.messages) != 1 || m.messages[0].Role != "user" {
+		t.Errorf("expected only the user message to remain, got %+v", m.messages)
+	}
+	if !m.streaming {
+		t.Error("retry should start streaming")
+	}
+}
+
+func TestSlashTitleWithoutSession(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/title Something")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.err == nil {
+		t.Error("expected error when renaming without a session")
+	}
+}
+
+func TestCompletionPopup(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/ex")
+	if !strings.Contains(m.View(), "/export") {
+		t.Error("completion popup should list /export")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if m.textarea.Value() != "/export " {
+		t.Errorf("tab should complete the command, got %q", m.textarea.Value())
+	}
+	if len(m.completions()) != 0 {
+		t.Error("popup should close once arguments are being typed")
+	}
+}
+
+func TestCompletionPopupNavigation(t *testing.T) {
+	m := New(nil, nil)
+	m = typeText(m, "/c")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if m.textarea.Value() != "/copy " {
+		t.Errorf("expected second match to be completed, got %q", m.textarea.Value())
+	}
+}
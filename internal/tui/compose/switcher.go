@@ -0,0 +1,162 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/sahilm/fuzzy"
+)
+
+// switcherHelp is shown while the quick switcher is open
+const switcherHelp = "type to filter | up/down: choose | enter: open | esc: close"
+
+// switcherRows is how many sessions the quick switcher lists
+const switcherRows = 8
+
+// switcherSessions is how many pinned and recent sessions the switcher searches
+const switcherSessions = 500
+
+// SessionsListedMsg carries the sessions offered by the quick switcher.
+type SessionsListedMsg struct {
+	Sessions []db.Session
+	Err      error
+}
+
+// OpenSessionMsg asks the app to open a saved session in the compose view.
+type OpenSessionMsg struct {
+	Session  db.Session
+	Messages []db.Message
+}
+
+// switcherState holds the quick switcher over pinned and recent sessions.
+type switcherState struct {
+	active   bool
+	input    textinput.Model
+	sessions []db.Session // pinned first, then newest
+	matches  []db.Session
+	cursor   int
+}
+
+func newSwitcherInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Open: "
+	ti.Placeholder = "session title"
+	return ti
+}
+
+func listSessionsCmd(database *db.DB) tea.Cmd {
+	return func() tea.Msg {
+		page, err := database.ListSessionsPage("", switcherSessions, db.SessionQuery{Sort: db.SortUpdated})
+		return SessionsListedMsg{Sessions: page.Sessions, Err: err}
+	}
+}
+
+func openSessionCmd(database *db.DB, session db.Session) tea.Cmd {
+	return func() tea.Msg {
+		messages, err := database.GetSessionMessages(session.ID)
+		if err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return OpenSessionMsg{Session: session, Messages: messages}
+	}
+}
+
+// startSwitcher opens the quick switcher and loads the sessions it offers.
+func (m *Model) startSwitcher() tea.Cmd {
+	if m.db == nil {
+		return nil
+	}
+	m.switcher = switcherState{active: true, input: newSwitcherInput()}
+	m.textarea.Blur()
+	return tea.Batch(m.switcher.input.Focus(), listSessionsCmd(m.db))
+}
+
+// stopSwitcher closes the quick switcher.
+func (m *Model) stopSwitcher() {
+	m.switcher = switcherState{input: newSwitcherInput()}
+	m.textarea.Focus()
+}
+
+// sessionTitles adapts sessions for fuzzy matching by title.
+type sessionTitles []db.Session
+
+func (s sessionTitles) String(i int) string { return s[i].Title }
+func (s sessionTitles) Len() int            { return len(s) }
+
+// filterSwitcher matches the sessions against the query. An empty query keeps
+// pinned sessions first and then the most recent.
+func (m *Model) filterSwitcher() {
+	query := m.switcher.input.Value()
+	m.switcher.cursor = 0
+	if query == "" {
+		m.switcher.matches = m.switcher.sessions
+	} else {
+		m.switcher.matches = nil
+		for _, match := range fuzzy.FindFrom(query, sessionTitles(m.switcher.sessions)) {
+			m.switcher.matches = append(m.switcher.matches, m.switcher.sessions[match.Index])
+		}
+	}
+	if len(m.switcher.matches) > switcherRows {
+		m.switcher.matches = m.switcher.matches[:switcherRows]
+	}
+}
+
+// updateSwitcher handles keys while the quick switcher is open.
+func (m *Model) updateSwitcher(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.stopSwitcher()
+		return nil
+	case tea.KeyUp:
+		if m.switcher.cursor > 0 {
+			m.switcher.cursor--
+		}
+		return nil
+	case tea.KeyDown:
+		if m.switcher.cursor < len(m.switcher.matches)-1 {
+			m.switcher.cursor++
+		}
+		return nil
+	case tea.KeyEnter:
+		if len(m.switcher.matches) == 0 {
+			return nil
+		}
+		session := m.switcher.matches[m.switcher.cursor]
+		m.stopSwitcher()
+		return openSessionCmd(m.db, session)
+	}
+
+	var cmd tea.Cmd
+	m.switcher.input, cmd = m.switcher.input.Update(msg)
+	m.filterSwitcher()
+	return cmd
+}
+
+// switcherView lists the matching sessions above the switcher input.
+func (m Model) switcherView() string {
+	lines := []string{commandStyle.Render("Switch to session:")}
+	if len(m.switcher.matches) == 0 {
+		lines = append(lines, helpStyle.Render("  no matching sessions"))
+	}
+	for i, s := range m.switcher.matches {
+		title := s.Title
+		if title == "" {
+			title = "Untitled"
+		}
+		if s.Pinned {
+			title = "★ " + title
+		}
+		line := fmt.Sprintf("%s %s", title, helpStyle.Render(s.UpdatedAt.Format("Jan 2 15:04")))
+		if i == m.switcher.cursor {
+			line = commandStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, m.switcher.input.View())
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,105 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// switcherDB returns a database with an old pinned session and newer ones,
+// the older of which was continued most recently.
+func switcherDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	base := time.Now().Round(time.Second)
+	sessions := []db.Session{
+		{ID: "ref", Title: "Kubernetes reference", Provider: "fake", Pinned: true, CreatedAt: base.Add(-48 * time.Hour)},
+		{ID: "rust", Title: "Rust lifetimes", Provider: "fake", CreatedAt: base.Add(-time.Hour), UpdatedAt: base.Add(time.Minute)},
+		{ID: "soup", Title: "Soup recipes", Provider: "fake", CreatedAt: base},
+	}
+	for i := range sessions {
+		if sessions[i].UpdatedAt.IsZero() {
+			sessions[i].UpdatedAt = sessions[i].CreatedAt
+		}
+		if err := database.CreateSession(&sessions[i]); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	if err := database.AddMessage(&db.Message{SessionID: "rust", Role: "user", Content: "borrowing", CreatedAt: base}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	return database
+}
+
+func openSwitcher(t *testing.T, database *db.DB) Model {
+	t.Helper()
+	m := New(database, &fakeProvider{})
+	m.SetSize(80, 24)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlP})
+	if !m.switcher.active {
+		t.Fatal("ctrl+p should open the switcher")
+	}
+	m, _ = m.Update(listSessionsCmd(database)())
+	return m
+}
+
+func TestSwitcherListsPinnedFirst(t *testing.T) {
+	m := openSwitcher(t, switcherDB(t))
+
+	var ids []string
+	for _, s := range m.switcher.matches {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, ",") != "ref,rust,soup" {
+		t.Errorf("expected pinned then recently updated sessions, got %v", ids)
+	}
+	view := m.View()
+	if !strings.Contains(view, "★ Kubernetes reference") || !strings.Contains(view, switcherHelp) {
+		t.Errorf("expected the switcher popup, got:\n%s", view)
+	}
+}
+
+func TestSwitcherFuzzyOpensSession(t *testing.T) {
+	database := switcherDB(t)
+	m := openSwitcher(t, database)
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("rslf")})
+	if len(m.switcher.matches) != 1 || m.switcher.matches[0].ID != "rust" {
+		t.Fatalf("expected a fuzzy match on Rust lifetimes, got %v", m.switcher.matches)
+	}
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.switcher.active {
+		t.Error("enter should close the switcher")
+	}
+	msg, ok := cmd().(OpenSessionMsg)
+	if !ok {
+		t.Fatal("expected an OpenSessionMsg")
+	}
+	if msg.Session.ID != "rust" || len(msg.Messages) != 1 {
+		t.Errorf("expected the rust session with its message, got %s with %d messages", msg.Session.ID, len(msg.Messages))
+	}
+}
+
+func TestSwitcherEscCloses(t *testing.T) {
+	m := openSwitcher(t, switcherDB(t))
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m.switcher.cursor != 1 {
+		t.Errorf("down should move the cursor, got %d", m.switcher.cursor)
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.switcher.active {
+		t.Error("esc should close the switcher")
+	}
+	if strings.Contains(m.View(), "Switch to session") {
+		t.Error("the popup should be gone")
+	}
+}
@@ -0,0 +1,239 @@
+package compose
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/llm"
+	"github.com/mg/ai-tui/internal/usage"
+)
+
+// recordUsageCmd stores the usage of a reply. When a monthly budget is set,
+// the outcome carries a warning once spending approaches it.
+func recordUsageCmd(database *db.DB, cfg *config.Config, u db.Usage) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.RecordUsage(&u); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		if cfg == nil || cfg.Budget.Monthly <= 0 {
+			return CommandDoneMsg{}
+		}
+		spent, err := usage.MonthCost(database, cfg, time.Now())
+		if err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{Flash: usage.BudgetWarning(spent, cfg.Budget)}
+	}
+}
+
+// replyUsage returns the usage of the reply that just finished, or of the
+// part of it that arrived before it was cancelled.
+func (m *Model) replyUsage(content string) db.Usage {
+	u := usageOf(m.cfg, m.provider, m.usage, m.promptTokens, content)
+	if m.session != nil {
+		u.SessionID = m.session.ID
+	}
+	return u
+}
+
+// usageOf returns the usage of a request to p that was answered with reply.
+// Providers that report none are charged with local estimates: input, the
+// estimated size of the request, and the length of reply.
+func usageOf(cfg *config.Config, p llm.Provider, reported *llm.Usage, input int, reply string) db.Usage {
+	u := db.Usage{CreatedAt: time.Now()}
+	if p != nil {
+		u.Provider = p.Name()
+		if cfg != nil {
+			u.Model = cfg.Providers[p.Name()].Model
+		}
+	}
+
+	if reported != nil {
+		u.InputTokens = reported.InputTokens
+		u.OutputTokens = reported.OutputTokens
+		u.CacheReadTokens = reported.CacheReadTokens
+		u.CacheWriteTokens = reported.CacheWriteTokens
+	} else {
+		u.InputTokens = input
+		u.OutputTokens = llm.EstimateTokens(reply)
+		u.Estimated = true
+	}
+	return u
+}
+
+// requestTo returns the request of msgs to p, with its system prompt, and
+// whether requests to p are checked against limits before they're sent.
+func requestTo(cfg *config.Config, p llm.Provider, msgs []llm.ChatMessage) (usage.Request, bool) {
+	req := usage.Request{InputTokens: llm.EstimateMessages(msgs)}
+	if p == nil {
+		return req, false
+	}
+	req.Provider = p.Name()
+	if cfg == nil {
+		return req, false
+	}
+	pc, ok := cfg.Providers[p.Name()]
+	req.Model = pc.Model
+	req.InputTokens += llm.EstimateTokens(pc.SystemPrompt)
+	req.MaxOutputTokens = pc.MaxTokens
+	return req, ok && pc.Limits.Set()
+}
+
+// LimitsCheckedMsg carries the check of a request against the provider's
+// limits, made before the request is sent.
+type LimitsCheckedMsg struct {
+	Msgs  []llm.ChatMessage
+	Check usage.Check
+	Err   error
+
+	summary *summaryRequest // set when the request is a summary of Msgs
+}
+
+// limitPrompt asks whether to send a request that would exceed a limit.
+type limitPrompt struct {
+	msgs    []llm.ChatMessage
+	check   usage.Check
+	summary *summaryRequest
+}
+
+// checkLimitsCmd checks the request of msgs, the reply or, with sr, the
+// summary made before it.
+func checkLimitsCmd(database *db.DB, cfg *config.Config, req usage.Request, msgs []llm.ChatMessage, sr *summaryRequest) tea.Cmd {
+	return func() tea.Msg {
+		check, err := usage.CheckRequest(database, cfg, req, time.Now())
+		return LimitsCheckedMsg{Msgs: msgs, Check: check, Err: err, summary: sr}
+	}
+}
+
+func recordDecisionCmd(database *db.DB, decision db.BudgetDecision) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.RecordBudgetDecision(&decision); err != nil {
+			return CommandDoneMsg{Err: err}
+		}
+		return CommandDoneMsg{}
+	}
+}
+
+// limited reports whether requests to the active provider are checked
+// against limits before they're sent.
+func (m *Model) limited() bool {
+	pc, ok := m.providerConfig()
+	return ok && m.db != nil && pc.Limits.Set()
+}
+
+// checkLimits checks the request made of msgs against the provider's limits
+// before it's streamed.
+func (m *Model) checkLimits(msgs []llm.ChatMessage) tea.Cmd {
+	pc, _ := m.providerConfig()
+	req := usage.Request{
+		Provider:        m.provider.Name(),
+		Model:           pc.Model,
+		InputTokens:     m.promptTokens,
+		MaxOutputTokens: pc.MaxTokens,
+	}
+	return checkLimitsCmd(m.db, m.cfg, req, msgs, nil)
+}
+
+// applyLimits sends the checked request, or blocks it or asks first when it
+// would exceed a limit. A summary that isn't sent leaves older messages out
+// of the response instead.
+func (m *Model) applyLimits(msg LimitsCheckedMsg) tea.Cmd {
+	if !m.streaming || (msg.summary != nil && !m.summarizing) {
+		// Cancelled while checking
+		return nil
+	}
+	if msg.Err != nil {
+		// Without the spending so far the limits can't be enforced
+		m.streaming = false
+		m.summarizing = false
+		return m.fail(fmt.Errorf("can't check spending limits: %w", msg.Err))
+	}
+	if len(msg.Check.Exceeded) == 0 {
+		return m.sendChecked(msg.Msgs, msg.summary)
+	}
+	if msg.Check.Action == config.LimitBlock && msg.summary != nil {
+		return tea.Batch(
+			m.recordDecision(msg.Check, db.DecisionBlocked),
+			flashCmd("Not summarized: "+msg.Check.Reason()),
+			m.skipSummary(),
+		)
+	}
+	if msg.Check.Action == config.LimitBlock {
+		m.streaming = false
+		m.err = fmt.Errorf("not sent: %s", msg.Check.Reason())
+		m.updateViewport()
+		return m.recordDecision(msg.Check, db.DecisionBlocked)
+	}
+	m.limitPrompt = &limitPrompt{msgs: msg.Msgs, check: msg.Check, summary: msg.summary}
+	return nil
+}
+
+// sendChecked sends a request that may go: the reply, or with sr the
+// summary made before it.
+func (m *Model) sendChecked(msgs []llm.ChatMessage, sr *summaryRequest) tea.Cmd {
+	if sr != nil {
+		return m.startSummary(msgs, sr)
+	}
+	return m.startStream(msgs)
+}
+
+// updateLimitPrompt handles keys while asking whether to send a request over
+// a limit.
+func (m *Model) updateLimitPrompt(msg tea.KeyMsg) tea.Cmd {
+	prompt := m.limitPrompt
+	switch msg.String() {
+	case "y", "Y":
+		m.limitPrompt = nil
+		return tea.Batch(m.recordDecision(prompt.check, db.DecisionConfirmed), m.sendChecked(prompt.msgs, prompt.summary))
+	case "n", "N":
+		if prompt.summary != nil {
+			m.limitPrompt = nil
+			return tea.Batch(m.recordDecision(prompt.check, db.DecisionDeclined), m.skipSummary())
+		}
+		fallthrough
+	case "esc", "ctrl+c":
+		m.limitPrompt = nil
+		m.streaming = false
+		m.summarizing = false
+		m.updateViewport()
+		return tea.Batch(m.recordDecision(prompt.check, db.DecisionDeclined), flashCmd("Not sent"))
+	}
+	return nil
+}
+
+// recordDecision stores what was done about a request over a limit. In a
+// conversation that isn't saved yet it's kept until the session exists.
+func (m *Model) recordDecision(check usage.Check, decision string) tea.Cmd {
+	d := db.BudgetDecision{
+		Provider:      check.Request.Provider,
+		Model:         check.Request.Model,
+		CreatedAt:     time.Now(),
+		InputTokens:   check.Request.InputTokens,
+		EstimatedCost: check.Cost,
+		Reason:        check.Reason(),
+		Decision:      decision,
+	}
+	if m.db == nil {
+		return nil
+	}
+	if m.session == nil {
+		m.decisions = append(m.decisions, d)
+		return nil
+	}
+	d.SessionID = m.session.ID
+	return recordDecisionCmd(m.db, d)
+}
+
+// limitPromptView asks whether to send a request over a limit.
+func (m Model) limitPromptView() string {
+	c := m.limitPrompt.check
+	question := "Send anyway (up to $%.2f)? (y/n)"
+	if m.limitPrompt.summary != nil {
+		question = "Summarize older messages anyway (up to $%.2f)? (y/n, n leaves them out)"
+	}
+	return errorStyle.Render("Over limit: ") + c.Reason() + "\n" +
+		commandStyle.Render(fmt.Sprintf(question, c.Cost))
+}
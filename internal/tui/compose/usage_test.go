@@ -0,0 +1,293 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/llm"
+)
+
+func TestRecordsReplyUsage(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := New(database, &fakeProvider{})
+	m.SetConfig(&config.Config{
+		Providers: map[string]config.Provider{"fake": {Model: "fake-1"}},
+		Pricing:   map[string]config.Price{"fake-1": {Input: 1000, Output: 1000}},
+		Budget:    config.Budget{Monthly: 1, WarnAt: 0.5},
+	})
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello"}}
+	m.streaming = true
+
+	m, _ = m.Update(StreamChunkMsg{Content: "hi ", Usage: &llm.Usage{InputTokens: 500, OutputTokens: 3}})
+	m, cmd := m.Update(StreamChunkMsg{Content: "there", Done: true})
+
+	var flash string
+	for _, msg := range collectMsgs(cmd) {
+		if done, ok := msg.(CommandDoneMsg); ok {
+			if done.Err != nil {
+				t.Fatalf("recording usage failed: %v", done.Err)
+			}
+			flash += done.Flash
+		}
+	}
+	if !strings.HasPrefix(flash, "Budget: $0.50 of $1.00") {
+		t.Errorf("expected a budget warning, got %q", flash)
+	}
+	if got := m.messages[1].Tokens; got != 3 {
+		t.Errorf("expected the reply to count 3 tokens, got %d", got)
+	}
+
+	totals, err := database.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Model != "fake-1" || totals[0].InputTokens != 500 || totals[0].Estimated != 0 {
+		t.Errorf("expected reported usage for fake-1, got %+v", totals)
+	}
+
+	// Without reported usage the reply is estimated
+	m.streaming = true
+	m.promptTokens = 40
+	_, cmd = m.Update(StreamChunkMsg{Content: "12345678", Done: true})
+	collectMsgs(cmd)
+	totals, err = database.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if totals[0].Requests != 2 || totals[0].InputTokens != 540 || totals[0].OutputTokens != 5 || totals[0].Estimated != 1 {
+		t.Errorf("expected an estimated second request, got %+v", totals[0])
+	}
+}
+
+// sendOverLimit sends a message that exceeds a max_input_tokens limit with
+// the given action and returns the model once the check is in.
+func sendOverLimit(t *testing.T, database *db.DB, action string) Model {
+	t.Helper()
+	m := New(database, &fakeProvider{})
+	m.SetConfig(&config.Config{
+		Providers: map[string]config.Provider{"fake": {Model: "fake-1", MaxTokens: 100, Limits: config.Limits{MaxInputTokens: 5, Action: action}}},
+	})
+	m.textarea.SetValue("a message well over five tokens long")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	var checked bool
+	for _, msg := range collectMsgs(cmd) {
+		switch msg := msg.(type) {
+		case SessionCreatedMsg, LimitsCheckedMsg:
+			if _, ok := msg.(LimitsCheckedMsg); ok {
+				checked = true
+			}
+			m, cmd = m.Update(msg)
+			collectMsgs(cmd)
+		}
+	}
+	if !checked {
+		t.Fatal("expected the request to be checked against the limits")
+	}
+	return m
+}
+
+func TestBlocksRequestOverLimit(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := sendOverLimit(t, database, config.LimitBlock)
+	if m.streaming || m.err == nil || !strings.Contains(m.err.Error(), "token limit") {
+		t.Errorf("expected the request to be blocked, got streaming=%v err=%v", m.streaming, m.err)
+	}
+
+	decisions, err := database.ListBudgetDecisions(m.session.ID)
+	if err != nil {
+		t.Fatalf("ListBudgetDecisions failed: %v", err)
+	}
+	if len(decisions) != 1 || decisions[0].Decision != db.DecisionBlocked || decisions[0].Model != "fake-1" {
+		t.Errorf("expected a blocked decision recorded, got %+v", decisions)
+	}
+}
+
+func TestConfirmsRequestOverLimit(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := sendOverLimit(t, database, config.LimitConfirm)
+	if m.limitPrompt == nil {
+		t.Fatal("expected to be asked before sending")
+	}
+	if view := m.View(); !strings.Contains(view, "Send anyway") {
+		t.Errorf("expected the prompt in the view:\n%s", view)
+	}
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	collectMsgs(cmd)
+	if m.limitPrompt != nil || !m.streaming {
+		t.Errorf("expected the request to go ahead, got prompt=%v streaming=%v", m.limitPrompt, m.streaming)
+	}
+
+	// Declining the next one leaves it unsent
+	m.streaming = false
+	m.textarea.SetValue("another message over the limit")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	for _, msg := range collectMsgs(cmd) {
+		if checked, ok := msg.(LimitsCheckedMsg); ok {
+			m, _ = m.Update(checked)
+		}
+	}
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	collectMsgs(cmd)
+	if m.limitPrompt != nil || m.streaming {
+		t.Errorf("expected the request to be dropped, got prompt=%v streaming=%v", m.limitPrompt, m.streaming)
+	}
+
+	decisions, err := database.ListBudgetDecisions(m.session.ID)
+	if err != nil {
+		t.Fatalf("ListBudgetDecisions failed: %v", err)
+	}
+	if len(decisions) != 2 || decisions[0].Decision != db.DecisionConfirmed || decisions[1].Decision != db.DecisionDeclined {
+		t.Errorf("expected confirmed then declined, got %+v", decisions)
+	}
+}
+
+func TestChecksSummaryAgainstLimits(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := contextModel(config.StrategySummarize)
+	m.db = database
+	m.cfg.Providers["fake"] = config.Provider{
+		Model: "test", MaxTokens: 10, ContextWindow: 100,
+		Limits: config.Limits{MaxInputTokens: 60, Action: config.LimitBlock},
+	}
+
+	var checked *LimitsCheckedMsg
+	for _, msg := range collectMsgs(m.send()) {
+		switch msg := msg.(type) {
+		case ContextSummarizedMsg:
+			t.Fatal("the summary was sent without checking its limits")
+		case LimitsCheckedMsg:
+			checked = &msg
+		}
+	}
+	if checked == nil || checked.summary == nil {
+		t.Fatal("expected the summary to be checked against the limits")
+	}
+
+	// Blocked, the older messages are left out instead
+	m, cmd := m.Update(*checked)
+	for _, msg := range collectMsgs(cmd) {
+		if _, ok := msg.(ContextSummarizedMsg); ok {
+			t.Error("a blocked summary should not be sent")
+		}
+	}
+	if m.summarizing || !m.streaming {
+		t.Errorf("expected the response to go on without a summary, got summarizing=%v streaming=%v", m.summarizing, m.streaming)
+	}
+	if len(m.decisions) != 1 || m.decisions[0].Decision != db.DecisionBlocked {
+		t.Errorf("expected a blocked decision kept, got %+v", m.decisions)
+	}
+}
+
+func TestRecordsSummaryUsage(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := contextModel(config.StrategySummarize)
+	m.db = database
+	for _, msg := range collectMsgs(m.send()) {
+		if s, ok := msg.(ContextSummarizedMsg); ok {
+			var cmd tea.Cmd
+			m, cmd = m.Update(s)
+			collectMsgs(cmd)
+		}
+	}
+
+	totals, err := database.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Requests != 1 || totals[0].InputTokens == 0 || totals[0].Estimated != 1 {
+		t.Errorf("expected the summary request recorded, got %+v", totals)
+	}
+}
+
+func TestGeneratedTitleUsageAndLimits(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := &db.Session{ID: "s1", Title: "hello there", Provider: "fake", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := New(database, &fakeProvider{})
+	m.SetConfig(&config.Config{Providers: map[string]config.Provider{"fake": {Model: "fake-1"}}})
+	m.SetTitler(&fakeProvider{response: "Greeting Exchange"})
+	m.session = session
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello there"}, {Role: "assistant", Content: "hi!"}}
+
+	m, cmd := m.Update(m.titleCmd()())
+	collectMsgs(cmd)
+	totals, err := database.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Model != "fake-1" || totals[0].Requests != 1 {
+		t.Errorf("expected the title request recorded, got %+v", totals)
+	}
+
+	// Over a limit, no title is generated
+	m.session.Title = "hello there"
+	m.cfg.Providers["fake"] = config.Provider{Model: "fake-1", Limits: config.Limits{MaxInputTokens: 1, Action: config.LimitConfirm}}
+	if msg := m.titleCmd()(); msg != nil {
+		t.Errorf("expected no title over the limit, got %+v", msg)
+	}
+}
+
+func TestRecordsCancelledReplyUsage(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := New(database, &fakeProvider{})
+	m.messages = []DisplayMessage{{Role: "user", Content: "hello"}}
+	m.streaming = true
+	m.promptTokens = 40
+	m, _ = m.Update(StreamStartedMsg{Cancel: func() {}})
+	m, _ = m.Update(StreamChunkMsg{Content: "12345678"})
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	collectMsgs(cmd)
+	totals, err := database.AggregateUsage(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("AggregateUsage failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].InputTokens != 40 || totals[0].OutputTokens != 2 || totals[0].Estimated != 1 {
+		t.Errorf("expected the cancelled reply recorded, got %+v", totals)
+	}
+}
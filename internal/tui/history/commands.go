@@ -1,39 +1,147 @@
 package history
 
 import (
+	"fmt"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
 	"github.com/mg/ai-tui/internal/db"
 	"github.com/mg/ai-tui/internal/export"
 )
 
-func loadSessionsCmd(database *db.DB, includeArchived bool) tea.Cmd {
+// loadSessionsCmd loads the page of sessions after cursor, or the first page
+// when cursor is empty.
+func loadSessionsCmd(database *db.DB, query db.SessionQuery, cursor string) tea.Cmd {
 	return func() tea.Msg {
-		sessions, err := database.ListSessions(includeArchived)
+		page, err := database.ListSessionsPage(cursor, pageSize, query)
 		if err != nil {
-			return SessionsLoadedMsg{Sessions: nil}
+			return ActionFailedMsg{Action: "load sessions", Err: err}
 		}
-		return SessionsLoadedMsg{Sessions: sessions}
+		return SessionsLoadedMsg{Sessions: page.Sessions, After: cursor, Next: page.Next, Total: page.Total}
 	}
 }
 
 func archiveSessionCmd(database *db.DB, sessionID string) tea.Cmd {
 	return func() tea.Msg {
-		database.ArchiveSession(sessionID)
-		return SessionArchivedMsg{SessionID: sessionID}
+		if err := database.ArchiveSession(sessionID); err != nil {
+			return ActionFailedMsg{Action: "archive session", Err: err}
+		}
+		return SessionArchivedMsg{SessionID: sessionID, Archived: true}
+	}
+}
+
+// archiveSessionsCmd archives several sessions, stopping at the first failure.
+func archiveSessionsCmd(database *db.DB, ids []string) tea.Cmd {
+	return func() tea.Msg {
+		for i, id := range ids {
+			if err := database.ArchiveSession(id); err != nil {
+				if i > 0 {
+					err = fmt.Errorf("%w (%d of %d archived)", err, i, len(ids))
+				}
+				return ActionFailedMsg{Action: "archive sessions", Err: err}
+			}
+		}
+		return SessionsArchivedMsg{IDs: ids}
+	}
+}
+
+func unarchiveSessionCmd(database *db.DB, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.UnarchiveSession(sessionID); err != nil {
+			return ActionFailedMsg{Action: "unarchive session", Err: err}
+		}
+		return SessionArchivedMsg{SessionID: sessionID, Archived: false}
+	}
+}
+
+// deleteSessionCmd deletes a session permanently, keeping a copy of it in the
+// result so the deletion can be undone.
+func deleteSessionCmd(database *db.DB, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		session, err := database.GetSession(sessionID)
+		if err != nil {
+			return ActionFailedMsg{Action: "delete session", Err: err}
+		}
+		messages, err := database.GetSessionMessages(sessionID)
+		if err != nil {
+			return ActionFailedMsg{Action: "delete session", Err: err}
+		}
+		if err := database.DeleteSession(sessionID); err != nil {
+			return ActionFailedMsg{Action: "delete session", Err: err}
+		}
+		return SessionDeletedMsg{Session: *session, Messages: messages}
+	}
+}
+
+func renameSessionCmd(database *db.DB, sessionID, title, oldTitle string) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.UpdateSessionTitle(sessionID, title); err != nil {
+			return ActionFailedMsg{Action: "rename session", Err: err}
+		}
+		return SessionRenamedMsg{SessionID: sessionID, Title: title, OldTitle: oldTitle}
+	}
+}
+
+func undoCmd(database *db.DB, undo *undoEntry) tea.Cmd {
+	return func() tea.Msg {
+		if err := undo.run(database); err != nil {
+			return ActionFailedMsg{Action: "undo " + undo.action, Err: err}
+		}
+		return UndoneMsg{Action: undo.action}
 	}
 }
 
 func resumeSessionCmd(database *db.DB, session db.Session) tea.Cmd {
 	return func() tea.Msg {
-		messages, _ := database.GetSessionMessages(session.ID)
+		messages, err := database.GetSessionMessages(session.ID)
+		if err != nil {
+			return ActionFailedMsg{Action: "open session", Err: err}
+		}
 		return ResumeSessionMsg{Session: session, Messages: messages}
 	}
 }
 
-func exportSessionCmd(database *db.DB, session db.Session, notesDir string) tea.Cmd {
+func exportSessionCmd(database *db.DB, session db.Session, notesDir, format string, cfg config.Export) tea.Cmd {
 	return func() tea.Msg {
-		messages, _ := database.GetSessionMessages(session.ID)
-		path, _ := export.ToMarkdown(session, messages, notesDir)
-		return SessionExportedMsg{Path: path}
+		e, err := export.ForFormat(format, export.Options{Template: cfg.Template, Sessions: database})
+		if err != nil {
+			return ActionFailedMsg{Action: "export session", Err: err}
+		}
+		messages, err := database.GetSessionMessages(session.ID)
+		if err != nil {
+			return ActionFailedMsg{Action: "export session", Err: err}
+		}
+		result, err := export.Save(database, e, session, messages, notesDir, cfg.Update == config.ExportAppend)
+		if err != nil {
+			return ActionFailedMsg{Action: "export session", Err: err}
+		}
+		return SessionExportedMsg{Result: result}
+	}
+}
+
+// exportSessionsCmd exports several sessions in format, stopping at the
+// first failure.
+func exportSessionsCmd(database *db.DB, sessions []db.Session, notesDir, format string, cfg config.Export) tea.Cmd {
+	return func() tea.Msg {
+		e, err := export.ForFormat(format, export.Options{Template: cfg.Template, Sessions: database})
+		if err != nil {
+			return ActionFailedMsg{Action: "export sessions", Err: err}
+		}
+		done := SessionsExportedMsg{Dir: notesDir}
+		for _, session := range sessions {
+			messages, err := database.GetSessionMessages(session.ID)
+			if err == nil {
+				var result export.Result
+				result, err = export.Save(database, e, session, messages, notesDir, cfg.Update == config.ExportAppend)
+				if result.Edited != "" {
+					done.Edited++
+				}
+			}
+			if err != nil {
+				return ActionFailedMsg{Action: "export sessions", Err: fmt.Errorf("%w (%d of %d exported)", err, done.Count, len(sessions))}
+			}
+			done.Count++
+		}
+		return done
 	}
 }
@@ -0,0 +1,4 @@
+package history
+
+// filterHelp summarizes the filter syntax shown under the filter bar
+const filterHelp = "provider: model: persona: project: tag: since: until: min: sort:created|updated|messages|tokens order:asc | enter: apply | esc: cancel"
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func TestFilterBar(t *testing.T) {
+	database, session := historyDB(t)
+	other := db.Session{ID: "2", Title: "Other", Provider: "openai", Model: "gpt-4o", CreatedAt: session.CreatedAt, UpdatedAt: session.UpdatedAt}
+	if err := database.CreateSession(&other); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	m := New(database, "/tmp/notes")
+	m, _ = run(t, m, m.Init())
+	if len(m.sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(m.sessions))
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	if !m.filtering {
+		t.Fatal("/ should open the filter bar")
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("provider:openai")})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.filtering {
+		t.Error("enter should close the filter bar")
+	}
+	m, _ = run(t, m, cmd)
+	if len(m.sessions) != 1 || m.sessions[0].ID != "2" {
+		t.Fatalf("expected only the openai session, got %v", m.sessions)
+	}
+	if !strings.Contains(m.View(), "Filter: provider:openai") {
+		t.Error("the applied filter should be shown")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m, _ = run(t, m, cmd)
+	if m.filter != "" || len(m.sessions) != 2 {
+		t.Errorf("esc should clear the filter, got %q with %d sessions", m.filter, len(m.sessions))
+	}
+}
+
+func TestFilterBarRejectsInvalidQuery(t *testing.T) {
+	m := New(nil, "/tmp/notes")
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("since:soon")})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil {
+		t.Error("an invalid filter should not reload")
+	}
+	if !m.filtering || !strings.Contains(m.View(), "invalid date") {
+		t.Error("the filter bar should stay open with the error")
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mg/ai-tui/internal/config"
 	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
 )
 
 var helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
@@ -15,25 +18,94 @@ var helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 // sessionItem implements list.Item
 type sessionItem struct {
 	session db.Session
+	marked  bool // selected for a bulk action
 }
 
 func (i sessionItem) Title() string {
-	if i.session.Title == "" {
-		return "Untitled"
+	title := i.session.Title
+	if title == "" {
+		title = "Untitled"
 	}
-	return i.session.Title
+	if i.session.Pinned {
+		title = "★ " + title
+	}
+	if i.marked {
+		title = "✓ " + title
+	}
+	return title
 }
 
 func (i sessionItem) Description() string {
-	return fmt.Sprintf("%s | %s | %s", i.session.Provider, i.session.Model, i.session.CreatedAt.Format("Jan 2 15:04"))
+	desc := fmt.Sprintf("%s | %s | %s | %d msgs", i.session.Provider, i.session.Model,
+		i.session.CreatedAt.Format("Jan 2 15:04"), i.session.MessageCount)
+	if i.session.Archived {
+		desc += " | archived"
+	}
+	for _, tag := range i.session.Tags {
+		desc += " #" + tag
+	}
+	return desc
 }
 
 func (i sessionItem) FilterValue() string { return i.Title() }
 
 // Message types
-type SessionsLoadedMsg struct{ Sessions []db.Session }
-type SessionArchivedMsg struct{ SessionID string }
-type SessionExportedMsg struct{ Path string }
+
+// SessionsLoadedMsg carries a page of sessions. The first page (After == "")
+// replaces the list and later pages extend it.
+type SessionsLoadedMsg struct {
+	Sessions []db.Session
+	After    string // cursor the page was loaded after
+	Next     string // cursor for the following page, "" on the last page
+	Total    int    // sessions matching the filter
+}
+
+type SessionExportedMsg struct{ Result export.Result }
+
+// SessionsExportedMsg reports a bulk export.
+type SessionsExportedMsg struct {
+	Count  int
+	Dir    string
+	Edited int // earlier exports left alone because they were edited
+}
+
+// SessionsArchivedMsg reports that several sessions were archived.
+type SessionsArchivedMsg struct{ IDs []string }
+
+// SessionArchivedMsg reports that a session was archived, or unarchived
+// when Archived is false.
+type SessionArchivedMsg struct {
+	SessionID string
+	Archived  bool
+}
+
+// SessionDeletedMsg carries a deleted session and its messages so the
+// deletion can be undone.
+type SessionDeletedMsg struct {
+	Session  db.Session
+	Messages []db.Message
+}
+
+type SessionRenamedMsg struct {
+	SessionID string
+	Title     string
+	OldTitle  string
+}
+
+// UndoneMsg reports that the last destructive action was reversed.
+type UndoneMsg struct{ Action string }
+
+// ActionFailedMsg reports a failed history action.
+type ActionFailedMsg struct {
+	Action string
+	Err    error
+}
+
+// undoEntry reverses the last destructive action.
+type undoEntry struct {
+	action string // what is undone, e.g. "archive"
+	run    func(database *db.DB) error
+}
 type ResumeSessionMsg struct {
 	Session  db.Session
 	Messages []db.Message
@@ -41,14 +113,35 @@ type ResumeSessionMsg struct {
 
 // Model is the history view for browsing past sessions.
 type Model struct {
-	list         list.Model
-	sessions     []db.Session
-	db           *db.DB
-	notesDir     string
-	showArchived bool
-	width        int
-	height       int
-	statusMsg    string
+	list           list.Model
+	sessions       []db.Session
+	db             *db.DB
+	notesDir       string
+	exportConfig   config.Export
+	showArchived   bool
+	width          int
+	height         int
+	statusMsg      string
+	renaming       string // ID of the session being renamed, "" when not renaming
+	input          textinput.Model
+	confirming     string          // ID of the session awaiting delete confirmation
+	exporting      []string        // IDs of the sessions awaiting an export format
+	marked         map[string]bool // IDs of the sessions selected for a bulk action
+	undo           *undoEntry
+	previews       map[string]*previewEntry // keyed by session ID
+	loadingPreview string
+	previewID      string // session the preview was last synced to
+	filtering      bool   // the filter bar is open
+	filterInput    textinput.Model
+	filter         string          // the applied filter as typed
+	query          db.SessionQuery // the applied filter
+	grouped        bool            // group sessions by project
+	organizing     organizeKind    // what the organize input edits, "" when closed
+	organizeID     string          // ID of the session being organized
+	organizeInput  textinput.Model
+	next           string // cursor for the next page, "" when all are loaded
+	total          int    // sessions matching the filter, loaded or not
+	loadingPage    bool
 }
 
 // New creates a new history view model.
@@ -58,55 +151,236 @@ func New(database *db.DB, notesDir string) Model {
 	l.Title = "Chat History"
 	l.SetShowStatusBar(false)
 	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+
+	ti := textinput.New()
+	ti.Prompt = "Title: "
+	ti.CharLimit = 200
+
+	fi := textinput.New()
+	fi.Prompt = "Filter: "
+	fi.CharLimit = 200
 
 	return Model{
-		list:     l,
-		db:       database,
-		notesDir: notesDir,
+		list:          l,
+		db:            database,
+		notesDir:      notesDir,
+		input:         ti,
+		filterInput:   fi,
+		organizeInput: newOrganizeInput(),
+		previews:      make(map[string]*previewEntry),
+		marked:        make(map[string]bool),
 	}
 }
 
-// SetSize updates the dimensions.
+// pageSize is how many sessions are loaded at a time
+const pageSize = 50
+
+// prefetchRows is how close to the end of the loaded sessions the cursor gets
+// before the next page is loaded
+const prefetchRows = 10
+
+// footerHeight is the status line and the help line
+const footerHeight = 2
+
+// SetExportConfig sets the Markdown template and update mode of exports.
+func (m *Model) SetExportConfig(cfg config.Export) {
+	m.exportConfig = cfg
+}
+
+// SetSize updates the dimensions. The preview pane sits beside the list
+// when the view is wide and below it otherwise.
 func (m *Model) SetSize(w, h int) {
 	m.width = w
 	m.height = h
-	m.list.SetSize(w, h-1)
+	body := h - footerHeight
+	if m.wide() {
+		m.list.SetSize(w*2/5, body)
+	} else {
+		m.list.SetSize(w, body/2)
+	}
+	m.invalidatePreviews()
 }
 
 // Init returns the initial command to load sessions.
 func (m Model) Init() tea.Cmd {
-	if m.db != nil {
-		return loadSessionsCmd(m.db, m.showArchived)
-	}
-	return nil
+	return m.reload()
 }
 
 // Update handles messages for the history view.
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	return m, tea.Batch(cmd, m.syncPreview(), m.loadMore())
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case PreviewLoadedMsg:
+		if m.loadingPreview == msg.SessionID {
+			m.loadingPreview = ""
+		}
+		if msg.Err != nil {
+			m.statusMsg = fmt.Sprintf("Failed to load preview: %v", msg.Err)
+		}
+		m.previews[msg.SessionID] = &previewEntry{messages: msg.Messages, err: msg.Err}
+		return m, nil
+
 	case SessionsLoadedMsg:
-		m.sessions = msg.Sessions
-		items := make([]list.Item, len(msg.Sessions))
-		for i, s := range msg.Sessions {
-			items[i] = sessionItem{session: s}
+		if msg.After != "" {
+			if msg.After != m.next {
+				// A page of a listing that has since been reloaded
+				return m, nil
+			}
+			m.sessions = append(m.sessions, msg.Sessions...)
+		} else {
+			// Sessions may have been continued since their preview loaded
+			m.previews = make(map[string]*previewEntry)
+			m.sessions = msg.Sessions
 		}
-		m.list.SetItems(items)
+		m.loadingPage = false
+		m.next = msg.Next
+		m.total = msg.Total
+		m.list.Title = fmt.Sprintf("Chat History (%d)", m.total)
+		m.setItems()
 		return m, nil
 
-	case SessionArchivedMsg:
-		m.statusMsg = "Session archived"
-		if m.db != nil {
-			return m, loadSessionsCmd(m.db, m.showArchived)
+	case SuggestionsLoadedMsg:
+		if m.organizing == msg.Kind {
+			m.organizeInput.SetSuggestions(msg.Suggestions)
 		}
 		return m, nil
 
+	case SessionTaggedMsg:
+		id, tag := msg.SessionID, msg.Tag
+		if msg.Removed {
+			m.statusMsg = fmt.Sprintf("Removed tag %s (z: undo)", tag)
+			m.undo = &undoEntry{action: "untag", run: func(database *db.DB) error {
+				return database.TagSession(id, tag)
+			}}
+		} else {
+			m.statusMsg = fmt.Sprintf("Tagged %s (z: undo)", tag)
+			m.undo = &undoEntry{action: "tag", run: func(database *db.DB) error {
+				return database.UntagSession(id, tag)
+			}}
+		}
+		return m, m.reload()
+
+	case SessionPinnedMsg:
+		if msg.Pinned {
+			m.statusMsg = "Session pinned"
+		} else {
+			m.statusMsg = "Session unpinned"
+		}
+		return m, m.reload()
+
+	case SessionProjectSetMsg:
+		id, old := msg.SessionID, msg.OldProject
+		if msg.Project == "" {
+			m.statusMsg = "Removed from project (z: undo)"
+		} else {
+			m.statusMsg = fmt.Sprintf("Moved to %s (z: undo)", msg.Project)
+		}
+		m.undo = &undoEntry{action: "project change", run: func(database *db.DB) error {
+			return database.SetSessionProject(id, old)
+		}}
+		return m, m.reload()
+
+	case SessionArchivedMsg:
+		id := msg.SessionID
+		if msg.Archived {
+			m.statusMsg = "Session archived (z: undo)"
+			m.undo = &undoEntry{action: "archive", run: func(database *db.DB) error {
+				return database.UnarchiveSession(id)
+			}}
+		} else {
+			m.statusMsg = "Session unarchived (z: undo)"
+			m.undo = &undoEntry{action: "unarchive", run: func(database *db.DB) error {
+				return database.ArchiveSession(id)
+			}}
+		}
+		return m, m.reload()
+
+	case SessionDeletedMsg:
+		delete(m.previews, msg.Session.ID)
+		delete(m.marked, msg.Session.ID)
+		session, messages := msg.Session, msg.Messages
+		m.statusMsg = "Session deleted (z: undo)"
+		m.undo = &undoEntry{action: "delete", run: func(database *db.DB) error {
+			restored := append([]db.Message(nil), messages...)
+			return database.CreateSessionWithMessages(&session, restored)
+		}}
+		return m, m.reload()
+
 	case SessionExportedMsg:
-		m.statusMsg = fmt.Sprintf("Exported to %s", msg.Path)
+		m.statusMsg = msg.Result.String()
+		return m, nil
+
+	case SessionsExportedMsg:
+		m.statusMsg = fmt.Sprintf("Exported %d sessions to %s", msg.Count, msg.Dir)
+		if msg.Count == 1 {
+			m.statusMsg = "Exported 1 session to " + msg.Dir
+		}
+		if msg.Edited > 0 {
+			m.statusMsg += fmt.Sprintf(" (%d edited exports left as is)", msg.Edited)
+		}
+		return m, nil
+
+	case SessionsArchivedMsg:
+		ids := msg.IDs
+		m.statusMsg = fmt.Sprintf("%d sessions archived (z: undo)", len(ids))
+		if len(ids) == 1 {
+			m.statusMsg = "Session archived (z: undo)"
+		}
+		m.undo = &undoEntry{action: "archive", run: func(database *db.DB) error {
+			for _, id := range ids {
+				if err := database.UnarchiveSession(id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}}
+		return m, m.reload()
+
+	case SessionRenamedMsg:
+		id, old := msg.SessionID, msg.OldTitle
+		m.statusMsg = "Session renamed (z: undo)"
+		m.undo = &undoEntry{action: "rename", run: func(database *db.DB) error {
+			return database.UpdateSessionTitle(id, old)
+		}}
+		return m, m.reload()
+
+	case UndoneMsg:
+		m.statusMsg = fmt.Sprintf("Undid %s", msg.Action)
+		return m, m.reload()
+
+	case ActionFailedMsg:
+		m.loadingPage = false
+		m.statusMsg = fmt.Sprintf("Failed to %s: %v", msg.Action, msg.Err)
 		return m, nil
 
 	case tea.KeyMsg:
 		m.statusMsg = ""
 
+		if m.renaming != "" {
+			return m.updateRename(msg)
+		}
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		if m.organizing != "" {
+			return m.updateOrganize(msg)
+		}
+		if m.confirming != "" {
+			id := m.confirming
+			m.confirming = ""
+			if msg.String() == "y" && m.db != nil {
+				return m, deleteSessionCmd(m.db, id)
+			}
+			return m, nil
+		}
+		if len(m.exporting) > 0 {
+			return m.updateExport(msg)
+		}
 		switch msg.String() {
 		case "enter", "l":
 			if item, ok := m.list.SelectedItem().(sessionItem); ok {
@@ -116,26 +390,128 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case " ":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok {
+				id := item.session.ID
+				if m.marked[id] {
+					delete(m.marked, id)
+				} else {
+					m.marked[id] = true
+				}
+				m.setItems()
+				m.list.CursorDown()
+			}
+			return m, nil
+
 		case "s":
+			if len(m.marked) > 0 {
+				return m, m.exportMarked("md")
+			}
 			if item, ok := m.list.SelectedItem().(sessionItem); ok {
 				if m.db != nil {
-					return m, exportSessionCmd(m.db, item.session, m.notesDir)
+					return m, exportSessionCmd(m.db, item.session, m.notesDir, "md", m.exportConfig)
 				}
 			}
 			return m, nil
 
+		case "e":
+			if len(m.marked) > 0 {
+				m.exporting = m.markedIDs()
+			} else if item, ok := m.list.SelectedItem().(sessionItem); ok {
+				m.exporting = []string{item.session.ID}
+			}
+			return m, nil
+
 		case "d":
-			if item, ok := m.list.SelectedItem().(sessionItem); ok {
+			if len(m.marked) > 0 {
+				return m, m.archiveMarked()
+			}
+			if item, ok := m.list.SelectedItem().(sessionItem); ok && !item.session.Archived {
 				if m.db != nil {
 					return m, archiveSessionCmd(m.db, item.session.ID)
 				}
 			}
 			return m, nil
 
+		case "u":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok && item.session.Archived {
+				if m.db != nil {
+					return m, unarchiveSessionCmd(m.db, item.session.ID)
+				}
+			}
+			return m, nil
+
+		case "D":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok {
+				m.confirming = item.session.ID
+			}
+			return m, nil
+
+		case "z":
+			if m.undo != nil && m.db != nil {
+				undo := m.undo
+				m.undo = nil
+				return m, undoCmd(m.db, undo)
+			}
+			m.statusMsg = "Nothing to undo"
+			return m, nil
+
+		case "r":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok {
+				m.renaming = item.session.ID
+				m.input.SetValue(item.session.Title)
+				m.input.CursorEnd()
+				return m, m.input.Focus()
+			}
+			return m, nil
+
 		case "a":
 			m.showArchived = !m.showArchived
-			if m.db != nil {
-				return m, loadSessionsCmd(m.db, m.showArchived)
+			m.clearMarks()
+			return m, m.reload()
+
+		case "t":
+			return m.startOrganize(organizeTag)
+
+		case "T":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok && len(item.session.Tags) == 0 {
+				m.statusMsg = "Session has no tags"
+				return m, nil
+			}
+			return m.startOrganize(organizeUntag)
+
+		case "p":
+			return m.startOrganize(organizeProject)
+
+		case "*":
+			if item, ok := m.list.SelectedItem().(sessionItem); ok && m.db != nil {
+				return m, pinSessionCmd(m.db, item.session.ID, !item.session.Pinned)
+			}
+			return m, nil
+
+		case "g":
+			m.grouped = !m.grouped
+			if m.grouped && m.sorted() {
+				m.statusMsg = "Grouping is off while the filter sets sort: or order:"
+			}
+			m.setItems()
+			return m, nil
+
+		case "/":
+			m.filtering = true
+			m.filterInput.SetValue(m.filter)
+			m.filterInput.CursorEnd()
+			return m, m.filterInput.Focus()
+
+		case "esc":
+			if len(m.marked) > 0 {
+				m.clearMarks()
+				return m, nil
+			}
+			if m.filter != "" {
+				m.filter = ""
+				m.query = db.SessionQuery{}
+				return m, m.reload()
 			}
 			return m, nil
 		}
@@ -150,18 +526,235 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+// reload refreshes the session list with the applied filter.
+func (m Model) reload() tea.Cmd {
+	if m.db == nil {
+		return nil
+	}
+	return loadSessionsCmd(m.db, m.listQuery(), "")
+}
+
+// listQuery is the applied filter with the archived toggle.
+func (m Model) listQuery() db.SessionQuery {
+	query := m.query
+	query.IncludeArchived = m.showArchived
+	return query
+}
+
+// loadMore loads the next page once the cursor nears the end of the
+// loaded sessions.
+func (m *Model) loadMore() tea.Cmd {
+	if m.db == nil || m.next == "" || m.loadingPage {
+		return nil
+	}
+	// Groups take sessions from every page, so load them all while grouped
+	if !m.grouping() && m.list.Index() < len(m.list.Items())-prefetchRows {
+		return nil
+	}
+	m.loadingPage = true
+	return loadSessionsCmd(m.db, m.listQuery(), m.next)
+}
+
+// findSession returns the loaded session with the given ID.
+func (m Model) findSession(id string) (db.Session, bool) {
+	for _, s := range m.sessions {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return db.Session{}, false
+}
+
+// exportKeys maps the keys of the export prompt to formats
+var exportKeys = map[string]string{
+	"m": "md",
+	"j": "json",
+	"l": "jsonl",
+	"h": "html",
+}
+
+// updateExport handles the key that picks the export format; any other key
+// cancels.
+func (m Model) updateExport(msg tea.KeyMsg) (Model, tea.Cmd) {
+	ids := m.exporting
+	m.exporting = nil
+	format, ok := exportKeys[msg.String()]
+	if !ok || m.db == nil {
+		return m, nil
+	}
+	if len(ids) > 1 {
+		return m, m.exportMarked(format)
+	}
+	session, ok := m.findSession(ids[0])
+	if !ok {
+		return m, nil
+	}
+	return m, exportSessionCmd(m.db, session, m.notesDir, format, m.exportConfig)
+}
+
+// markedIDs returns the IDs of the marked sessions in listing order.
+func (m Model) markedIDs() []string {
+	var ids []string
+	for _, s := range m.sessions {
+		if m.marked[s.ID] {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+// clearMarks unmarks every session.
+func (m *Model) clearMarks() {
+	if len(m.marked) == 0 {
+		return
+	}
+	m.marked = make(map[string]bool)
+	m.setItems()
+}
+
+// exportMarked exports the marked sessions in format and unmarks them.
+func (m *Model) exportMarked(format string) tea.Cmd {
+	var sessions []db.Session
+	for _, s := range m.sessions {
+		if m.marked[s.ID] {
+			sessions = append(sessions, s)
+		}
+	}
+	m.clearMarks()
+	if m.db == nil || len(sessions) == 0 {
+		return nil
+	}
+	return exportSessionsCmd(m.db, sessions, m.notesDir, format, m.exportConfig)
+}
+
+// archiveMarked archives the marked sessions that aren't archived yet and
+// unmarks them.
+func (m *Model) archiveMarked() tea.Cmd {
+	var ids []string
+	for _, s := range m.sessions {
+		if m.marked[s.ID] && !s.Archived {
+			ids = append(ids, s.ID)
+		}
+	}
+	m.clearMarks()
+	if m.db == nil || len(ids) == 0 {
+		return nil
+	}
+	return archiveSessionsCmd(m.db, ids)
+}
+
+// updateRename handles keys while the rename input is open.
+func (m Model) updateRename(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.renaming = ""
+		m.input.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		id := m.renaming
+		title := strings.TrimSpace(m.input.Value())
+		m.renaming = ""
+		m.input.Blur()
+		if title == "" || m.db == nil {
+			return m, nil
+		}
+		old, _ := m.findSession(id)
+		return m, renameSessionCmd(m.db, id, title, old.Title)
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// updateFilter handles keys while the filter bar is open. The filter is
+// applied on enter; an invalid one keeps the bar open with the error shown.
+func (m Model) updateFilter(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.filtering = false
+		m.filterInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		filter := strings.TrimSpace(m.filterInput.Value())
+		query, err := db.ParseQuery(filter)
+		if err != nil {
+			m.statusMsg = err.Error()
+			return m, nil
+		}
+		m.filtering = false
+		m.filterInput.Blur()
+		m.filter = filter
+		m.query = query
+		return m, m.reload()
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
 // View renders the history view.
 func (m Model) View() string {
 	var parts []string
-	parts = append(parts, m.list.View())
+	if m.wide() {
+		parts = append(parts, lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), m.previewView()))
+	} else {
+		parts = append(parts, m.list.View(), m.previewView())
+	}
+
+	if m.renaming != "" {
+		parts = append(parts, m.input.View())
+		parts = append(parts, helpStyle.Render("enter: save | esc: cancel"))
+		return strings.Join(parts, "\n")
+	}
 
-	if m.statusMsg != "" {
-		parts = append(parts, m.statusMsg)
+	if m.confirming != "" {
+		session, _ := m.findSession(m.confirming)
+		parts = append(parts, fmt.Sprintf("Delete %q permanently? (y/n)", sessionItem{session: session}.Title()))
+		parts = append(parts, "")
+		return strings.Join(parts, "\n")
+	}
+
+	if len(m.exporting) > 0 {
+		what := fmt.Sprintf("%d sessions", len(m.exporting))
+		if len(m.exporting) == 1 {
+			session, _ := m.findSession(m.exporting[0])
+			what = fmt.Sprintf("%q", sessionItem{session: session}.Title())
+		}
+		parts = append(parts, fmt.Sprintf("Export %s as: m Markdown | j JSON | l JSONL | h HTML", what))
+		parts = append(parts, helpStyle.Render("esc: cancel"))
+		return strings.Join(parts, "\n")
+	}
+
+	if m.organizing != "" {
+		parts = append(parts, m.organizeInput.View())
+		help := "tab: complete | enter: save | esc: cancel"
+		if m.organizing == organizeProject {
+			help = "tab: complete | enter: save (empty removes) | esc: cancel"
+		}
+		parts = append(parts, helpStyle.Render(help))
+		return strings.Join(parts, "\n")
+	}
+
+	if m.filtering {
+		parts = append(parts, m.filterInput.View()+"  "+m.statusMsg)
+		parts = append(parts, helpStyle.Render(filterHelp))
+		return strings.Join(parts, "\n")
+	}
+
+	status := m.statusMsg
+	if status == "" && len(m.marked) > 0 {
+		status = helpStyle.Render(fmt.Sprintf("%d marked (s/e: export, d: archive, esc: unmark)", len(m.marked)))
+	}
+	if status == "" && m.filter != "" {
+		status = helpStyle.Render(fmt.Sprintf("Filter: %s (%d sessions, esc: clear)", m.filter, m.total))
 	}
+	parts = append(parts, status)
 
-	help := "enter: open | s: save | d: archive | a: show archived | ctrl+n: new | ctrl+d: quit"
+	help := "enter: open | r: rename | s: save | e: export as | space: mark | d: archive | D: delete | t/T: tag/untag | p: project | *: pin | g: group | z: undo | /: filter | a: show archived | ctrl+n: new | ctrl+d: quit"
 	if m.showArchived {
-		help = "enter: open | s: save | d: archive | a: hide archived | ctrl+n: new | ctrl+d: quit"
+		help = "enter: open | r: rename | s: save | e: export as | space: mark | d: archive | u: unarchive | D: delete | t/T: tag/untag | p: project | *: pin | g: group | z: undo | /: filter | a: hide archived | ctrl+n: new | ctrl+d: quit"
 	}
 	parts = append(parts, helpStyle.Render(help))
 
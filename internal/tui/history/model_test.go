@@ -1,11 +1,16 @@
 package history
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/export"
 )
 
 func TestNewModel(t *testing.T) {
@@ -53,7 +58,7 @@ func TestArchiveToggle(t *testing.T) {
 
 func TestSessionExportedMsg(t *testing.T) {
 	m := New(nil, "/tmp/notes")
-	m, _ = m.Update(SessionExportedMsg{Path: "/tmp/notes/test.md"})
+	m, _ = m.Update(SessionExportedMsg{Result: export.Result{Path: "/tmp/notes/test.md"}})
 	if m.statusMsg == "" {
 		t.Error("statusMsg should be set after export")
 	}
@@ -66,3 +71,416 @@ func TestSessionArchivedMsg(t *testing.T) {
 		t.Error("statusMsg should be set after archive")
 	}
 }
+
+func TestRenameSession(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	session := db.Session{ID: "1", Title: "Old", Provider: "claude", Model: "sonnet", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(&session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	m := New(database, "/tmp/notes")
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	if m.renaming != "1" || m.input.Value() != "Old" {
+		t.Fatalf("expected rename input with current title, got renaming=%q value=%q", m.renaming, m.input.Value())
+	}
+
+	// Typed keys go to the input, not the list bindings
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if m.showArchived {
+		t.Error("typing in the rename input should not toggle archived sessions")
+	}
+
+	m.input.SetValue("New title")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected rename command")
+	}
+	msg, ok := cmd().(SessionRenamedMsg)
+	if !ok || msg.OldTitle != "Old" {
+		t.Fatalf("expected SessionRenamedMsg with the old title, got %+v", msg)
+	}
+
+	stored, err := database.GetSession("1")
+	if err != nil || stored.Title != "New title" {
+		t.Errorf("expected stored title 'New title', got %+v (err %v)", stored, err)
+	}
+}
+
+func TestRenameSessionFailure(t *testing.T) {
+	m := New(nil, "/tmp/notes")
+	m, _ = m.Update(ActionFailedMsg{Action: "rename session", Err: errors.New("boom")})
+	if !strings.Contains(m.statusMsg, "boom") {
+		t.Errorf("expected error in status, got %q", m.statusMsg)
+	}
+}
+
+// historyDB returns a database with one session holding one message.
+func historyDB(t *testing.T) (*db.DB, db.Session) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	now := time.Now().Round(time.Second)
+	session := db.Session{ID: "1", Title: "Keep me", Provider: "claude", Model: "sonnet", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreateSession(&session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := database.AddMessage(&db.Message{SessionID: "1", Role: "user", Content: "hi", CreatedAt: now}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	return database, session
+}
+
+// run executes cmd and feeds its message back into the model.
+func run(t *testing.T, m Model, cmd tea.Cmd) (Model, tea.Cmd) {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	return m.Update(cmd())
+}
+
+func TestDeleteRequiresConfirmation(t *testing.T) {
+	database, session := historyDB(t)
+	m := New(database, "/tmp/notes")
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'D'}})
+	if !strings.Contains(m.View(), "Delete \"Keep me\" permanently?") {
+		t.Error("expected a confirmation prompt")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if cmd != nil || m.confirming != "" {
+		t.Error("any key other than y should cancel the deletion")
+	}
+	if _, err := database.GetSession("1"); err != nil {
+		t.Error("session should still exist")
+	}
+}
+
+func TestDeleteAndUndo(t *testing.T) {
+	database, session := historyDB(t)
+	m := New(database, "/tmp/notes")
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'D'}})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m, _ = run(t, m, cmd)
+	if _, err := database.GetSession("1"); err == nil {
+		t.Fatal("session should be deleted")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m, _ = run(t, m, cmd)
+	if m.statusMsg != "Undid delete" {
+		t.Errorf("unexpected status %q", m.statusMsg)
+	}
+
+	restored, err := database.GetSession("1")
+	if err != nil || restored.Title != "Keep me" {
+		t.Fatalf("expected session restored, got %+v (err %v)", restored, err)
+	}
+	messages, err := database.GetSessionMessages("1")
+	if err != nil || len(messages) != 1 || messages[0].Content != "hi" {
+		t.Errorf("expected messages restored, got %+v (err %v)", messages, err)
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	if cmd != nil || m.statusMsg != "Nothing to undo" {
+		t.Error("undo buffer should hold only the last action")
+	}
+}
+
+func TestUnarchiveAndUndo(t *testing.T) {
+	database, session := historyDB(t)
+	if err := database.ArchiveSession("1"); err != nil {
+		t.Fatalf("failed to archive: %v", err)
+	}
+	session.Archived = true
+
+	m := New(database, "/tmp/notes")
+	m.showArchived = true
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m, _ = run(t, m, cmd)
+	if s, _ := database.GetSession("1"); s.Archived {
+		t.Fatal("session should be unarchived")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	run(t, m, cmd)
+	if s, _ := database.GetSession("1"); !s.Archived {
+		t.Error("undo should archive the session again")
+	}
+}
+
+func TestGroupingLoadsEveryPage(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	base := time.Now().Round(time.Second)
+	n := pageSize + 15
+	for i := 0; i < n; i++ {
+		created := base.Add(time.Duration(i) * time.Minute)
+		s := db.Session{ID: fmt.Sprintf("s%03d", i), Provider: "claude", Project: "garden", CreatedAt: created, UpdatedAt: created}
+		if err := database.CreateSession(&s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+
+	m := New(database, "/tmp/notes")
+	m.SetSize(80, 40)
+	m.grouped = true
+	m, cmd := run(t, m, m.Init())
+	m, _ = run(t, m, cmd)
+	if len(m.sessions) != n {
+		t.Fatalf("expected every page loaded while grouped, got %d of %d sessions", len(m.sessions), n)
+	}
+	if header, ok := m.list.Items()[0].(projectHeader); !ok || header.count != n {
+		t.Errorf("expected a header counting all %d sessions, got %v", n, m.list.Items()[0])
+	}
+}
+
+func TestArchiveReportsErrors(t *testing.T) {
+	database, _ := historyDB(t)
+	msg := archiveSessionCmd(database, "missing")()
+	failed, ok := msg.(ActionFailedMsg)
+	if !ok {
+		t.Fatalf("expected ActionFailedMsg, got %T", msg)
+	}
+
+	m := New(database, "/tmp/notes")
+	m, _ = m.Update(failed)
+	if !strings.Contains(m.statusMsg, "Failed to archive session") {
+		t.Errorf("expected error in status, got %q", m.statusMsg)
+	}
+}
+
+func TestLoadsPagesWhileScrolling(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	base := time.Now().Round(time.Second)
+	n := pageSize + 15
+	for i := 0; i < n; i++ {
+		created := base.Add(time.Duration(i) * time.Minute)
+		s := db.Session{ID: fmt.Sprintf("s%03d", i), Provider: "claude", CreatedAt: created, UpdatedAt: created}
+		if err := database.CreateSession(&s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+
+	m := New(database, "/tmp/notes")
+	m.SetSize(80, 40)
+	m, cmd := run(t, m, m.Init())
+	if len(m.sessions) != pageSize || m.total != n {
+		t.Fatalf("expected the first %d of %d sessions, got %d of %d", pageSize, n, len(m.sessions), m.total)
+	}
+	if cmd != nil {
+		if _, ok := cmd().(SessionsLoadedMsg); ok {
+			t.Fatal("the next page should not load before scrolling")
+		}
+	}
+	if !strings.Contains(m.View(), fmt.Sprintf("Chat History (%d)", n)) {
+		t.Error("the title should show the total")
+	}
+
+	m.list.Select(pageSize - prefetchRows)
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	var page SessionsLoadedMsg
+	for _, msg := range collectMsgs(cmd) {
+		if p, ok := msg.(SessionsLoadedMsg); ok {
+			page = p
+		}
+	}
+	if page.After == "" {
+		t.Fatal("expected the next page to load")
+	}
+	m, _ = m.Update(page)
+	if len(m.sessions) != n || m.next != "" {
+		t.Errorf("expected all %d sessions loaded, got %d (next %q)", n, len(m.sessions), m.next)
+	}
+	if m.sessions[n-1].ID != "s000" {
+		t.Errorf("expected the oldest session last, got %s", m.sessions[n-1].ID)
+	}
+
+	// A page from before a reload is dropped
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{{ID: "stale"}}, After: "old"})
+	if len(m.sessions) != n {
+		t.Error("a stale page should be ignored")
+	}
+}
+
+// collectMsgs runs cmd and any batched commands, returning their messages.
+func collectMsgs(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, collectMsgs(c)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+func TestExportAsJSON(t *testing.T) {
+	database, session := historyDB(t)
+	dir := t.TempDir()
+	m := New(database, dir)
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if !strings.Contains(m.View(), "j JSON") {
+		t.Error("expected the export format prompt")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	if len(m.exporting) > 0 {
+		t.Error("the prompt should close once a format is picked")
+	}
+	var exported *SessionExportedMsg
+	for _, msg := range collectMsgs(cmd) {
+		if e, ok := msg.(SessionExportedMsg); ok {
+			exported = &e
+		}
+	}
+	if exported == nil {
+		t.Fatal("expected the session to be exported")
+	}
+	if !strings.HasPrefix(exported.Result.Path, dir) || !strings.HasSuffix(exported.Result.Path, ".json") {
+		t.Errorf("exported to %s, want a .json file in %s", exported.Result.Path, dir)
+	}
+}
+
+func TestExportPromptCancels(t *testing.T) {
+	database, session := historyDB(t)
+	m := New(database, t.TempDir())
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: []db.Session{session}})
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(m.exporting) > 0 {
+		t.Error("esc should close the export prompt")
+	}
+	for _, msg := range collectMsgs(cmd) {
+		if _, ok := msg.(SessionExportedMsg); ok {
+			t.Error("esc should not export")
+		}
+	}
+}
+
+// markedDB holds three sessions, loaded into a history view.
+func markedDB(t *testing.T, dir string) (*db.DB, Model) {
+	t.Helper()
+	database, _ := historyDB(t)
+	now := time.Now().Round(time.Second)
+	for _, id := range []string{"2", "3"} {
+		s := db.Session{ID: id, Title: "Session " + id, Provider: "claude", CreatedAt: now.Add(-time.Hour), UpdatedAt: now}
+		if err := database.CreateSession(&s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	sessions, err := database.ListSessions(false)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	m := New(database, dir)
+	m, _ = m.Update(SessionsLoadedMsg{Sessions: sessions, Total: len(sessions)})
+	return database, m
+}
+
+func pressSpace(m Model) Model {
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	return m
+}
+
+func TestMarkAndExportSeveral(t *testing.T) {
+	dir := t.TempDir()
+	_, m := markedDB(t, dir)
+
+	// Mark the first two; space moves down after marking
+	m = pressSpace(pressSpace(m))
+	if len(m.marked) != 2 {
+		t.Fatalf("expected 2 marked sessions, got %d", len(m.marked))
+	}
+	if !strings.Contains(m.View(), "✓ ") || !strings.Contains(m.View(), "2 marked") {
+		t.Error("expected marked sessions to be shown")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if !strings.Contains(m.View(), "Export 2 sessions as") {
+		t.Error("expected the export prompt for the marked sessions")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}})
+	var exported *SessionsExportedMsg
+	for _, msg := range collectMsgs(cmd) {
+		if e, ok := msg.(SessionsExportedMsg); ok {
+			exported = &e
+		}
+	}
+	if exported == nil || exported.Count != 2 {
+		t.Fatalf("expected 2 sessions exported, got %+v", exported)
+	}
+	if len(m.marked) != 0 {
+		t.Error("sessions should be unmarked after the export")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected 2 files in %s, got %d", dir, len(entries))
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".html") {
+			t.Errorf("expected HTML exports, got %s", entry.Name())
+		}
+	}
+}
+
+func TestArchiveSeveralAndUndo(t *testing.T) {
+	database, m := markedDB(t, t.TempDir())
+
+	m = pressSpace(pressSpace(pressSpace(m)))
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	m, _ = run(t, m, cmd)
+	if !strings.Contains(m.statusMsg, "3 sessions archived") {
+		t.Errorf("unexpected status %q", m.statusMsg)
+	}
+	if active, _ := database.ListSessions(false); len(active) != 0 {
+		t.Errorf("expected every session archived, %d left", len(active))
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	run(t, m, cmd)
+	if active, _ := database.ListSessions(false); len(active) != 3 {
+		t.Errorf("expected undo to unarchive all 3 sessions, got %d", len(active))
+	}
+}
+
+func TestEscUnmarks(t *testing.T) {
+	_, m := markedDB(t, t.TempDir())
+	m = pressSpace(m)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(m.marked) != 0 {
+		t.Error("esc should unmark every session")
+	}
+}
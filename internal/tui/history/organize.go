@@ -0,0 +1,276 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// organizeKind is what the organize input edits.
+type organizeKind string
+
+const (
+	organizeTag     organizeKind = "tag"
+	organizeUntag   organizeKind = "untag"
+	organizeProject organizeKind = "project"
+)
+
+// noProject heads the group of sessions without a project
+const noProject = "No project"
+
+// pinnedGroup heads the pinned sessions, listed before the projects
+const pinnedGroup = "Pinned"
+
+// SessionTaggedMsg reports that a tag was added to a session, or removed
+// when Removed is true.
+type SessionTaggedMsg struct {
+	SessionID string
+	Tag       string
+	Removed   bool
+}
+
+// SessionPinnedMsg reports that a session was pinned, or unpinned when
+// Pinned is false.
+type SessionPinnedMsg struct {
+	SessionID string
+	Pinned    bool
+}
+
+// SessionProjectSetMsg reports that a session was filed under a project.
+type SessionProjectSetMsg struct {
+	SessionID  string
+	Project    string
+	OldProject string
+}
+
+// SuggestionsLoadedMsg carries the completions for the organize input.
+type SuggestionsLoadedMsg struct {
+	Kind        organizeKind
+	Suggestions []string
+}
+
+// projectHeader heads a group of sessions in the list.
+type projectHeader struct {
+	name  string
+	count int
+}
+
+func (h projectHeader) Title() string       { return "▸ " + h.name }
+func (h projectHeader) Description() string { return fmt.Sprintf("%d sessions", h.count) }
+func (h projectHeader) FilterValue() string { return h.name }
+
+func newOrganizeInput() textinput.Model {
+	ti := textinput.New()
+	ti.CharLimit = 100
+	ti.ShowSuggestions = true
+	return ti
+}
+
+func loadSuggestionsCmd(database *db.DB, kind organizeKind) tea.Cmd {
+	return func() tea.Msg {
+		var names []string
+		var err error
+		if kind == organizeProject {
+			names, err = database.ListProjects()
+		} else {
+			names, err = database.ListTags()
+		}
+		if err != nil {
+			return ActionFailedMsg{Action: "load suggestions", Err: err}
+		}
+		return SuggestionsLoadedMsg{Kind: kind, Suggestions: names}
+	}
+}
+
+func tagSessionCmd(database *db.DB, sessionID, tag string, remove bool) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if remove {
+			err = database.UntagSession(sessionID, tag)
+		} else {
+			err = database.TagSession(sessionID, tag)
+		}
+		if err != nil {
+			return ActionFailedMsg{Action: "tag session", Err: err}
+		}
+		return SessionTaggedMsg{SessionID: sessionID, Tag: tag, Removed: remove}
+	}
+}
+
+func pinSessionCmd(database *db.DB, sessionID string, pinned bool) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.SetSessionPinned(sessionID, pinned); err != nil {
+			return ActionFailedMsg{Action: "pin session", Err: err}
+		}
+		return SessionPinnedMsg{SessionID: sessionID, Pinned: pinned}
+	}
+}
+
+func setProjectCmd(database *db.DB, sessionID, project, oldProject string) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.SetSessionProject(sessionID, project); err != nil {
+			return ActionFailedMsg{Action: "set project", Err: err}
+		}
+		return SessionProjectSetMsg{SessionID: sessionID, Project: project, OldProject: oldProject}
+	}
+}
+
+// startOrganize opens the organize input for the highlighted session.
+func (m Model) startOrganize(kind organizeKind) (Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(sessionItem)
+	if !ok {
+		return m, nil
+	}
+	m.organizing = kind
+	m.organizeID = item.session.ID
+	m.organizeInput.Reset()
+	m.organizeInput.SetSuggestions(nil)
+	m.organizeInput.Prompt = strings.ToUpper(string(kind[:1])) + string(kind[1:]) + ": "
+
+	cmds := []tea.Cmd{m.organizeInput.Focus()}
+	switch kind {
+	case organizeUntag:
+		// Only the session's own tags can be removed
+		m.organizeInput.SetSuggestions(item.session.Tags)
+	case organizeProject:
+		m.organizeInput.SetValue(item.session.Project)
+		m.organizeInput.CursorEnd()
+		fallthrough
+	default:
+		if m.db != nil {
+			cmds = append(cmds, loadSuggestionsCmd(m.db, kind))
+		}
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// updateOrganize handles keys while the organize input is open.
+func (m Model) updateOrganize(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.organizing = ""
+		m.organizeInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		kind, id := m.organizing, m.organizeID
+		value := strings.TrimSpace(m.organizeInput.Value())
+		m.organizing = ""
+		m.organizeInput.Blur()
+		if m.db == nil {
+			return m, nil
+		}
+		if kind == organizeProject {
+			old, _ := m.findSession(id)
+			if value == old.Project {
+				return m, nil
+			}
+			return m, setProjectCmd(m.db, id, value, old.Project)
+		}
+		if value == "" {
+			return m, nil
+		}
+		tag, err := db.NormalizeTag(value)
+		if err != nil {
+			m.statusMsg = err.Error()
+			return m, nil
+		}
+		return m, tagSessionCmd(m.db, id, tag, kind == organizeUntag)
+	}
+
+	var cmd tea.Cmd
+	m.organizeInput, cmd = m.organizeInput.Update(msg)
+	return m, cmd
+}
+
+// groupItems orders sessions into per-project groups, each under a header.
+// Pinned sessions come first in a group of their own, then the projects
+// sorted by name with sessions without a project last; sessions keep the
+// query's order within a group.
+func groupItems(sessions []db.Session) []list.Item {
+	groups := make(map[string][]db.Session)
+	var names []string
+	var pinned []db.Session
+	for _, s := range sessions {
+		if s.Pinned {
+			pinned = append(pinned, s)
+			continue
+		}
+		if _, seen := groups[s.Project]; !seen {
+			names = append(names, s.Project)
+		}
+		groups[s.Project] = append(groups[s.Project], s)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "" || names[j] == "" {
+			return names[j] == ""
+		}
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+
+	items := make([]list.Item, 0, len(sessions)+len(names)+1)
+	if len(pinned) > 0 {
+		items = append(items, projectHeader{name: pinnedGroup, count: len(pinned)})
+		for _, s := range pinned {
+			items = append(items, sessionItem{session: s})
+		}
+	}
+	for _, name := range names {
+		header := name
+		if header == "" {
+			header = noProject
+		}
+		items = append(items, projectHeader{name: header, count: len(groups[name])})
+		for _, s := range groups[name] {
+			items = append(items, sessionItem{session: s})
+		}
+	}
+	return items
+}
+
+// hasProjects reports whether any loaded session is filed under a project.
+func (m Model) hasProjects() bool {
+	for _, s := range m.sessions {
+		if s.Project != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted reports whether the filter asks for an order of its own, which
+// grouping would override.
+func (m Model) sorted() bool {
+	return m.query.Sort != "" || m.query.Ascending
+}
+
+// grouping reports whether the list is grouped by project: when grouping is
+// on, the filter doesn't set the order and any session has a project.
+func (m Model) grouping() bool {
+	return m.grouped && !m.sorted() && m.hasProjects()
+}
+
+// setItems fills the list from the loaded sessions, grouped by project when
+// grouping applies.
+func (m *Model) setItems() {
+	var items []list.Item
+	if m.grouping() {
+		items = groupItems(m.sessions)
+	} else {
+		items = make([]list.Item, len(m.sessions))
+		for i, s := range m.sessions {
+			items[i] = sessionItem{session: s}
+		}
+	}
+	for i, item := range items {
+		if si, ok := item.(sessionItem); ok && m.marked[si.session.ID] {
+			si.marked = true
+			items[i] = si
+		}
+	}
+	m.list.SetItems(items)
+}
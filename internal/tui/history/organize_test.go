@@ -0,0 +1,176 @@
+package history
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func TestTagAndUntag(t *testing.T) {
+	database, _ := historyDB(t)
+	if err := database.TagSession("1", "existing"); err != nil {
+		t.Fatalf("TagSession failed: %v", err)
+	}
+	m := New(database, "/tmp/notes")
+	m, _ = run(t, m, m.Init())
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if m.organizing != organizeTag {
+		t.Fatal("t should open the tag input")
+	}
+	m, _ = m.Update(loadSuggestionsCmd(database, organizeTag)())
+	if got := m.organizeInput.AvailableSuggestions(); !reflect.DeepEqual(got, []string{"existing"}) {
+		t.Errorf("expected existing tags as suggestions, got %v", got)
+	}
+
+	m.organizeInput.SetValue("#Work")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, cmd = run(t, m, cmd)
+	if !strings.Contains(m.statusMsg, "Tagged work") {
+		t.Errorf("expected a tagged notice, got %q", m.statusMsg)
+	}
+	m, _ = run(t, m, cmd)
+	if tags := m.sessions[0].Tags; !reflect.DeepEqual(tags, []string{"existing", "work"}) {
+		t.Errorf("expected the reloaded session to be tagged, got %v", tags)
+	}
+	if !strings.Contains(m.View(), "#work") {
+		t.Error("tags should be shown in the list")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	if m.organizing != organizeUntag {
+		t.Fatal("T should open the untag input")
+	}
+	if got := m.organizeInput.AvailableSuggestions(); !reflect.DeepEqual(got, []string{"existing", "work"}) {
+		t.Errorf("expected the session's tags as suggestions, got %v", got)
+	}
+	m.organizeInput.SetValue("existing")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, cmd = run(t, m, cmd)
+	m, _ = run(t, m, cmd)
+	if tags := m.sessions[0].Tags; !reflect.DeepEqual(tags, []string{"work"}) {
+		t.Errorf("expected the tag to be removed, got %v", tags)
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m, _ = run(t, m, cmd)
+	s, err := database.GetSession("1")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if !reflect.DeepEqual(s.Tags, []string{"existing", "work"}) {
+		t.Errorf("undo should restore the tag, got %v", s.Tags)
+	}
+}
+
+func TestTagRejectsInvalidTag(t *testing.T) {
+	database, _ := historyDB(t)
+	m := New(database, "/tmp/notes")
+	m, _ = run(t, m, m.Init())
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	m.organizeInput.SetValue("two words")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil {
+		t.Error("an invalid tag should not be saved")
+	}
+	if !strings.Contains(m.statusMsg, "can't contain spaces") {
+		t.Errorf("expected the tag error, got %q", m.statusMsg)
+	}
+}
+
+func TestSetProjectGroupsList(t *testing.T) {
+	database, _ := historyDB(t)
+	m := New(database, "/tmp/notes")
+	m, _ = run(t, m, m.Init())
+	if _, ok := m.list.Items()[0].(projectHeader); ok {
+		t.Fatal("the list should not be grouped while no session has a project")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if m.organizing != organizeProject {
+		t.Fatal("p should open the project input")
+	}
+	m.organizeInput.SetValue("garden")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, cmd = run(t, m, cmd)
+	m, _ = run(t, m, cmd)
+
+	if len(m.list.Items()) != 1 {
+		t.Fatal("the list should not be grouped by default")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	items := m.list.Items()
+	if len(items) != 2 {
+		t.Fatalf("expected a header and a session, got %d items", len(items))
+	}
+	if header, ok := items[0].(projectHeader); !ok || header.name != "garden" {
+		t.Errorf("expected a garden header, got %v", items[0])
+	}
+
+	// An explicit order isn't overridden by the groups
+	m.query = db.SessionQuery{Sort: db.SortTokens}
+	m.setItems()
+	if len(m.list.Items()) != 1 {
+		t.Error("grouping should be off while sorting by tokens")
+	}
+	m.query = db.SessionQuery{}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	if len(m.list.Items()) != 1 {
+		t.Error("g should flatten the list")
+	}
+}
+
+func TestGroupItems(t *testing.T) {
+	now := time.Now()
+	sessions := []db.Session{
+		{ID: "1", CreatedAt: now},
+		{ID: "2", Project: "zeta", CreatedAt: now},
+		{ID: "3", Project: "Alpha", CreatedAt: now},
+		{ID: "4", Project: "zeta", CreatedAt: now},
+		{ID: "5", Project: "zeta", Pinned: true, CreatedAt: now},
+	}
+
+	var got []string
+	for _, item := range groupItems(sessions) {
+		switch item := item.(type) {
+		case projectHeader:
+			got = append(got, item.Title())
+		case sessionItem:
+			got = append(got, item.session.ID)
+		}
+	}
+	want := []string{"▸ Pinned", "5", "▸ Alpha", "3", "▸ zeta", "2", "4", "▸ No project", "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPinToggle(t *testing.T) {
+	database, _ := historyDB(t)
+	m := New(database, "/tmp/notes")
+	m, _ = run(t, m, m.Init())
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'*'}})
+	m, cmd = run(t, m, cmd)
+	if m.statusMsg != "Session pinned" {
+		t.Errorf("expected a pinned notice, got %q", m.statusMsg)
+	}
+	m, _ = run(t, m, cmd)
+	if !m.sessions[0].Pinned || !strings.Contains(m.View(), "★ Keep me") {
+		t.Error("the session should be shown pinned")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'*'}})
+	m, cmd = run(t, m, cmd)
+	m, _ = run(t, m, cmd)
+	if m.sessions[0].Pinned {
+		t.Error("a second * should unpin the session")
+	}
+}
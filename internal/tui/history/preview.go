@@ -0,0 +1,183 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/llm"
+	"github.com/mg/ai-tui/internal/markdown"
+)
+
+var (
+	previewStyle      = lipgloss.NewStyle().PaddingLeft(1)
+	previewTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+)
+
+// wideWidth is the width from which the preview sits beside the list
+const wideWidth = 100
+
+// previewMessages is how many messages of the conversation's start are shown
+const previewMessages = 4
+
+// previewRunes caps each previewed message so rendering stays fast
+const previewRunes = 600
+
+// PreviewLoadedMsg carries the messages of a session for the preview pane.
+type PreviewLoadedMsg struct {
+	SessionID string
+	Messages  []db.Message
+	Err       error
+}
+
+// previewEntry caches a session's messages and their rendering.
+type previewEntry struct {
+	messages []db.Message
+	err      error // the load failed, retried once the selection moves
+	rendered string
+	width    int // width rendered at, 0 when stale
+}
+
+func loadPreviewCmd(database *db.DB, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		messages, err := database.GetSessionMessages(sessionID)
+		return PreviewLoadedMsg{SessionID: sessionID, Messages: messages, Err: err}
+	}
+}
+
+// wide reports whether the preview sits beside the list rather than below it.
+func (m Model) wide() bool {
+	return m.width >= wideWidth
+}
+
+// previewSize returns the space available to the preview pane.
+func (m Model) previewSize() (int, int) {
+	if m.wide() {
+		return m.width - m.list.Width() - previewStyle.GetHorizontalFrameSize(), m.list.Height()
+	}
+	return m.width - previewStyle.GetHorizontalFrameSize(), m.height - footerHeight - m.list.Height()
+}
+
+// syncPreview renders the highlighted session's preview, loading its
+// messages first if they aren't cached. A failed load is only retried when
+// the session is highlighted again, or after a reload.
+func (m *Model) syncPreview() tea.Cmd {
+	item, ok := m.list.SelectedItem().(sessionItem)
+	if !ok {
+		return nil
+	}
+	id := item.session.ID
+
+	entry, cached := m.previews[id]
+	if cached && entry.err != nil && id != m.previewID {
+		delete(m.previews, id)
+		cached = false
+	}
+	m.previewID = id
+	if !cached {
+		if m.db == nil || m.loadingPreview == id {
+			return nil
+		}
+		m.loadingPreview = id
+		return loadPreviewCmd(m.db, id)
+	}
+
+	width, _ := m.previewSize()
+	if entry.width != width {
+		if entry.err != nil {
+			entry.rendered = helpStyle.Render(fmt.Sprintf("Failed to load preview: %v", entry.err))
+		} else {
+			entry.rendered = renderPreview(item.session, entry.messages, width)
+		}
+		entry.width = width
+	}
+	return nil
+}
+
+// invalidatePreviews marks rendered previews stale, keeping the messages.
+func (m *Model) invalidatePreviews() {
+	for _, entry := range m.previews {
+		entry.width = 0
+	}
+}
+
+// previewView renders the preview pane for the highlighted session.
+func (m Model) previewView() string {
+	width, height := m.previewSize()
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+
+	content := ""
+	if item, ok := m.list.SelectedItem().(sessionItem); ok {
+		if entry, cached := m.previews[item.session.ID]; cached && entry.width == width {
+			content = entry.rendered
+		} else {
+			content = helpStyle.Render("Loading...")
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return previewStyle.Width(width + previewStyle.GetHorizontalFrameSize()).Render(strings.Join(lines, "\n"))
+}
+
+// renderPreview shows a session's details and the start of its conversation.
+func renderPreview(session db.Session, messages []db.Message, width int) string {
+	tokens := 0
+	estimated := false
+	for _, msg := range messages {
+		if msg.Tokens > 0 {
+			tokens += msg.Tokens
+		} else {
+			tokens += llm.EstimateTokens(msg.Content)
+			estimated = true
+		}
+	}
+	tokenText := fmt.Sprintf("%d tokens", tokens)
+	if estimated {
+		tokenText = "~" + tokenText
+	}
+
+	var sb strings.Builder
+	sb.WriteString(previewTitleStyle.Render(sessionItem{session: session}.Title()) + "\n")
+	sb.WriteString(helpStyle.Render(fmt.Sprintf("%d messages | %s | updated %s",
+		len(messages), tokenText, session.UpdatedAt.Format("Jan 2 15:04"))) + "\n")
+
+	var doc strings.Builder
+	shown := 0
+	for _, msg := range messages {
+		if shown == previewMessages {
+			break
+		}
+		var label string
+		switch msg.Role {
+		case "user":
+			label = "You"
+		case "assistant":
+			label = "Assistant"
+		default:
+			continue
+		}
+		content := msg.Content
+		if runes := []rune(content); len(runes) > previewRunes {
+			content = string(runes[:previewRunes]) + "…"
+		}
+		fmt.Fprintf(&doc, "**%s:**\n\n%s\n\n", label, content)
+		shown++
+	}
+	if doc.Len() == 0 {
+		return sb.String()
+	}
+
+	rendered, err := markdown.Render(doc.String(), width)
+	if err != nil {
+		rendered = doc.String()
+	}
+	sb.WriteString(rendered)
+	return sb.String()
+}
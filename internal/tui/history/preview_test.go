@@ -0,0 +1,177 @@
+package history
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// previewModel returns a history view over two sessions with messages.
+func previewModel(t *testing.T, width int) (Model, tea.Cmd) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	now := time.Now().Round(time.Second)
+	var sessions []db.Session
+	for _, id := range []string{"1", "2"} {
+		s := db.Session{ID: id, Title: "Session " + id, Provider: "claude", Model: "sonnet", CreatedAt: now, UpdatedAt: now}
+		if err := database.CreateSession(&s); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		for _, msg := range []db.Message{
+			{SessionID: id, Role: "user", Content: "question " + id, CreatedAt: now},
+			{SessionID: id, Role: "assistant", Content: "answer " + id, CreatedAt: now, Tokens: 12},
+		} {
+			if err := database.AddMessage(&msg); err != nil {
+				t.Fatalf("failed to add message: %v", err)
+			}
+		}
+		sessions = append(sessions, s)
+	}
+
+	m := New(database, "/tmp/notes")
+	m.SetSize(width, 30)
+	return m.Update(SessionsLoadedMsg{Sessions: sessions})
+}
+
+func TestPreviewLoadsHighlightedSession(t *testing.T) {
+	m, cmd := previewModel(t, 120)
+	if !strings.Contains(ansi.Strip(m.View()), "Loading...") {
+		t.Error("expected loading placeholder before messages arrive")
+	}
+
+	m, _ = run(t, m, cmd)
+	view := ansi.Strip(m.View())
+	for _, want := range []string{"2 messages", "question 1", "answer 1"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("preview should contain %q", want)
+		}
+	}
+	if lines := strings.Count(m.View(), "\n") + 1; lines > 30 {
+		t.Errorf("view is %d lines, taller than the 30 available", lines)
+	}
+}
+
+func TestPreviewIsCached(t *testing.T) {
+	m, cmd := previewModel(t, 120)
+	m, _ = run(t, m, cmd)
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if cmd == nil {
+		t.Fatal("expected the second session's preview to load")
+	}
+	m, _ = run(t, m, cmd)
+	if !strings.Contains(ansi.Strip(m.View()), "question 2") {
+		t.Error("preview should follow the highlighted session")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if cmd != nil {
+		if _, ok := cmd().(PreviewLoadedMsg); ok {
+			t.Error("a cached preview should not be loaded again")
+		}
+	}
+	if !strings.Contains(ansi.Strip(m.View()), "question 1") {
+		t.Error("cached preview should be shown")
+	}
+}
+
+func TestPreviewLayout(t *testing.T) {
+	wide, cmd := previewModel(t, 120)
+	wide, _ = run(t, wide, cmd)
+	narrow, cmd := previewModel(t, 60)
+	narrow, _ = run(t, narrow, cmd)
+
+	// Beside the list, the title and the preview share a line
+	if !lineContainsBoth(ansi.Strip(wide.View()), "Chat History", "Session 1") {
+		t.Error("wide layout should put the preview beside the list")
+	}
+	if lineContainsBoth(ansi.Strip(narrow.View()), "Chat History", "Session 1") {
+		t.Error("narrow layout should put the preview below the list")
+	}
+	if !strings.Contains(ansi.Strip(narrow.View()), "question 1") {
+		t.Error("narrow layout should still show the preview")
+	}
+}
+
+func TestFullPreviewFitsNarrowLayout(t *testing.T) {
+	m, cmd := previewModel(t, 60)
+	m, _ = run(t, m, cmd)
+	long := strings.Repeat("a long line of the conversation ", 20)
+	for _, id := range []string{"1", "2"} {
+		for i := 0; i < 2; i++ {
+			if err := m.db.AddMessage(&db.Message{SessionID: id, Role: "user", Content: long, CreatedAt: time.Now()}); err != nil {
+				t.Fatalf("failed to add message: %v", err)
+			}
+		}
+	}
+	m, cmd = run(t, m, m.reload())
+	m, _ = run(t, m, cmd)
+
+	if lines := strings.Count(m.View(), "\n") + 1; lines > 30 {
+		t.Errorf("view is %d lines, taller than the 30 available", lines)
+	}
+	if !strings.Contains(ansi.Strip(m.View()), "Chat History") {
+		t.Error("the list title should stay in view")
+	}
+}
+
+func TestPreviewErrorIsNotRetriedInPlace(t *testing.T) {
+	m, _ := previewModel(t, 120)
+	m, cmd := m.Update(PreviewLoadedMsg{SessionID: "1", Err: errors.New("disk I/O error")})
+	if cmd != nil {
+		if _, ok := cmd().(PreviewLoadedMsg); ok {
+			t.Error("a failed preview should not be loaded again right away")
+		}
+	}
+	if !strings.Contains(ansi.Strip(m.View()), "Failed to load preview: disk I/O error") {
+		t.Error("expected the error in the preview pane")
+	}
+
+	// Highlighting the session again retries
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = run(t, m, cmd)
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m, _ = run(t, m, cmd)
+	if !strings.Contains(ansi.Strip(m.View()), "question 1") {
+		t.Error("expected the preview loaded after the selection came back")
+	}
+}
+
+func TestPreviewReloadsAfterListing(t *testing.T) {
+	m, cmd := previewModel(t, 120)
+	m, _ = run(t, m, cmd)
+
+	// The sessions are continued elsewhere, then the listing is reloaded
+	now := time.Now()
+	for _, id := range []string{"1", "2"} {
+		if err := m.db.AddMessage(&db.Message{SessionID: id, Role: "user", Content: "follow-up", CreatedAt: now}); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+	m, cmd = run(t, m, m.reload())
+	m, _ = run(t, m, cmd)
+
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "3 messages") {
+		t.Errorf("preview should show the new message count, got:\n%s", view)
+	}
+}
+
+func lineContainsBoth(s, a, b string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.Contains(line, a) && strings.Contains(line, b) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,13 +6,14 @@ import "github.com/charmbracelet/bubbles/key"
 type GlobalKeyMap struct {
 	History     key.Binding // ctrl+h - view conversation history
 	NewChat     key.Binding // ctrl+n - start a new chat
+	Stats       key.Binding // ctrl+g - usage and cost dashboard
 	ModelSelect key.Binding // ctrl+m - select model
 	Quit        key.Binding // ctrl+d - quit the application
 }
 
 // ShortHelp returns the key bindings to show in the help bar
 func (k GlobalKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.ModelSelect, k.History, k.NewChat, k.Quit}
+	return []key.Binding{k.ModelSelect, k.History, k.NewChat, k.Stats, k.Quit}
 }
 
 // GlobalKeys is the global key map instance
@@ -25,6 +26,10 @@ var GlobalKeys = GlobalKeyMap{
 		key.WithKeys("ctrl+n"),
 		key.WithHelp("ctrl+n", "new chat"),
 	),
+	Stats: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "stats"),
+	),
 	ModelSelect: key.NewBinding(
 		key.WithKeys("ctrl+m"),
 		key.WithHelp("ctrl+m", "model"),
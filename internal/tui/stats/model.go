@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/usage"
+)
+
+// Local styles — do NOT import from internal/tui to avoid import cycle
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("230"))
+	sparkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	warnStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("214"))
+	errorStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196"))
+)
+
+// periods are the report lengths in days that d cycles through
+var periods = []int{30, 90, 7}
+
+// ReportLoadedMsg carries a freshly built usage report.
+type ReportLoadedMsg struct {
+	Report usage.Report
+	Err    error
+}
+
+// Model is the usage and cost dashboard.
+type Model struct {
+	db     *db.DB
+	cfg    *config.Config
+	period int // index into periods
+	report usage.Report
+	loaded bool
+	err    error
+	width  int
+	height int
+}
+
+// New creates the stats view.
+func New(database *db.DB, cfg *config.Config) Model {
+	return Model{db: database, cfg: cfg}
+}
+
+// SetSize sets the space available to the view.
+func (m *Model) SetSize(w, h int) {
+	m.width = w
+	m.height = h
+}
+
+func loadReportCmd(database *db.DB, cfg *config.Config, days int) tea.Cmd {
+	return func() tea.Msg {
+		report, err := usage.Load(database, cfg, days, time.Now())
+		return ReportLoadedMsg{Report: report, Err: err}
+	}
+}
+
+// Init returns the command that loads the report.
+func (m Model) Init() tea.Cmd {
+	if m.db == nil {
+		return nil
+	}
+	return loadReportCmd(m.db, m.cfg, periods[m.period])
+}
+
+// Update handles messages for the stats view.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ReportLoadedMsg:
+		m.err = msg.Err
+		if msg.Err == nil {
+			m.report = msg.Report
+			m.loaded = true
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "r":
+			return m, m.Init()
+		case "d":
+			m.period = (m.period + 1) % len(periods)
+			return m, m.Init()
+		}
+	}
+	return m, nil
+}
+
+// View renders the dashboard.
+func (m Model) View() string {
+	var lines []string
+	days := periods[m.period]
+	lines = append(lines, titleStyle.Render(fmt.Sprintf("Usage — last %d days", days)), "")
+
+	switch {
+	case m.err != nil:
+		lines = append(lines, errorStyle.Render("Error: "+m.err.Error()))
+	case !m.loaded:
+		lines = append(lines, helpStyle.Render("Loading..."))
+	default:
+		r := m.report
+		lines = append(lines,
+			sparkStyle.Render(usage.Sparkline(r.DailyTokens())),
+			helpStyle.Render(fmt.Sprintf("%s to %s, %d tokens in %d requests", r.Since, r.Until, r.Tokens, r.Requests)),
+			"")
+
+		var table strings.Builder
+		usage.WriteModels(&table, r)
+		lines = append(lines, strings.Split(strings.TrimRight(table.String(), "\n"), "\n")...)
+		lines = append(lines, "", r.MonthSummary())
+		if r.Warning != "" {
+			lines = append(lines, warnStyle.Render(r.Warning))
+		}
+	}
+
+	// Keep the help on screen when the table is taller than the view
+	help := helpStyle.Render("r: refresh | d: 30/90/7 days")
+	if m.height > 0 && len(lines) > m.height-2 {
+		lines = lines[:max(m.height-2, 0)]
+	}
+	for len(lines) < m.height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, help)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func TestShowsUsageAndBudgetWarning(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	u := &db.Usage{SessionID: "s", Provider: "claude", Model: "claude-sonnet-4-20250514", CreatedAt: time.Now(), InputTokens: 1_000_000, OutputTokens: 100_000}
+	if err := database.RecordUsage(u); err != nil {
+		t.Fatalf("RecordUsage failed: %v", err)
+	}
+
+	cfg := &config.Config{
+		Pricing: map[string]config.Price{"claude-sonnet-4": {Input: 3, Output: 15}},
+		Budget:  config.Budget{Monthly: 5, WarnAt: 0.8},
+	}
+	m := New(database, cfg)
+	m.SetSize(100, 30)
+
+	if view := m.View(); !strings.Contains(view, "Loading") {
+		t.Errorf("expected a loading notice before the report arrives:\n%s", view)
+	}
+
+	m, _ = m.Update(m.Init()())
+	view := m.View()
+	for _, want := range []string{"last 30 days", "claude-sonnet-4-20250514", "$4.5000", "This month: $4.50 of $5.00 (90%)", "Budget: $4.50 of $5.00"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+
+	// d switches to the next period
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if cmd == nil {
+		t.Fatal("expected the report to reload for the new period")
+	}
+	m, _ = m.Update(cmd())
+	if view := m.View(); !strings.Contains(view, "last 90 days") {
+		t.Errorf("expected a 90 day report:\n%s", view)
+	}
+}
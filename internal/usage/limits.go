@@ -0,0 +1,82 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// Request is a request about to be sent to a provider.
+type Request struct {
+	Provider        string
+	Model           string
+	InputTokens     int // estimated from the messages sent
+	MaxOutputTokens int // the longest reply allowed
+}
+
+// Check is the outcome of checking a request against its provider's limits.
+type Check struct {
+	Request  Request
+	Cost     float64  // worst case: the estimated input and the longest reply
+	Exceeded []string // limits the request would exceed, none when it may go
+	Action   string   // config.LimitConfirm or config.LimitBlock
+}
+
+// Reason describes the limits the request would exceed.
+func (c Check) Reason() string {
+	return strings.Join(c.Exceeded, "; ")
+}
+
+// CheckRequest checks req against its provider's limits, counting what the
+// provider has spent today and this month.
+func CheckRequest(database *db.DB, cfg *config.Config, req Request, now time.Time) (Check, error) {
+	limits := cfg.Providers[req.Provider].Limits
+	price, _ := cfg.PriceFor(req.Model)
+	check := Check{
+		Request: req,
+		Cost:    Cost(price, req.InputTokens, req.MaxOutputTokens, 0, 0),
+		Action:  limits.Action,
+	}
+
+	var today, month float64
+	if limits.Daily > 0 || limits.Monthly > 0 {
+		totals, err := database.AggregateUsage(monthStart(now), time.Time{})
+		if err != nil {
+			return Check{}, err
+		}
+		day := now.Format(dayLayout)
+		for _, t := range totals {
+			if t.Provider != req.Provider {
+				continue
+			}
+			price, _ := cfg.PriceFor(t.Model)
+			cost := Cost(price, t.InputTokens, t.OutputTokens, t.CacheReadTokens, t.CacheWriteTokens)
+			month += cost
+			if t.Day == day {
+				today += cost
+			}
+		}
+	}
+
+	check.Exceeded = CheckLimits(limits, req, check.Cost, today, month)
+	return check, nil
+}
+
+// CheckLimits returns the limits a request costing cost would exceed, given
+// what its provider has spent today and this month.
+func CheckLimits(limits config.Limits, req Request, cost, today, month float64) []string {
+	var exceeded []string
+	if limits.MaxInputTokens > 0 && req.InputTokens > limits.MaxInputTokens {
+		exceeded = append(exceeded, fmt.Sprintf("~%d input tokens is over the %d token limit", req.InputTokens, limits.MaxInputTokens))
+	}
+	if limits.Daily > 0 && today+cost > limits.Daily {
+		exceeded = append(exceeded, fmt.Sprintf("$%.2f spent today plus up to $%.2f is over the $%.2f daily limit", today, cost, limits.Daily))
+	}
+	if limits.Monthly > 0 && month+cost > limits.Monthly {
+		exceeded = append(exceeded, fmt.Sprintf("$%.2f spent this month plus up to $%.2f is over the $%.2f monthly limit", month, cost, limits.Monthly))
+	}
+	return exceeded
+}
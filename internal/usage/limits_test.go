@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func TestCheckLimits(t *testing.T) {
+	limits := config.Limits{Daily: 1, Monthly: 10, MaxInputTokens: 1000}
+	req := Request{InputTokens: 500}
+
+	if got := CheckLimits(limits, req, 0.1, 0.5, 5); len(got) != 0 {
+		t.Errorf("expected a request within limits to pass, got %v", got)
+	}
+
+	got := CheckLimits(limits, Request{InputTokens: 2000}, 0.6, 0.5, 9.5)
+	if len(got) != 3 {
+		t.Fatalf("expected all three limits exceeded, got %v", got)
+	}
+	for i, want := range []string{"2000 input tokens", "daily limit", "monthly limit"} {
+		if !strings.Contains(got[i], want) {
+			t.Errorf("exceeded[%d] = %q, want it to mention %q", i, got[i], want)
+		}
+	}
+
+	if got := CheckLimits(config.Limits{}, Request{InputTokens: 1e6}, 100, 100, 100); len(got) != 0 {
+		t.Errorf("expected no limits to pass everything, got %v", got)
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	records := []db.Usage{
+		// $0.90 today and $3 earlier this month on claude
+		{Provider: "claude", Model: "sonnet", CreatedAt: now.Add(-time.Hour), InputTokens: 300_000},
+		{Provider: "claude", Model: "sonnet", CreatedAt: now.AddDate(0, 0, -3), InputTokens: 1_000_000},
+		// Other providers and last month don't count
+		{Provider: "openai", Model: "sonnet", CreatedAt: now, InputTokens: 5_000_000},
+		{Provider: "claude", Model: "sonnet", CreatedAt: now.AddDate(0, -1, 0), InputTokens: 5_000_000},
+	}
+	for i := range records {
+		if err := database.RecordUsage(&records[i]); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		Providers: map[string]config.Provider{
+			"claude": {Model: "sonnet", Limits: config.Limits{Daily: 1, Monthly: 5, Action: config.LimitBlock}},
+		},
+		Pricing: map[string]config.Price{"sonnet": {Input: 3, Output: 15}},
+	}
+	req := Request{Provider: "claude", Model: "sonnet", InputTokens: 10_000, MaxOutputTokens: 4000}
+	check, err := CheckRequest(database, cfg, req, now)
+	if err != nil {
+		t.Fatalf("CheckRequest failed: %v", err)
+	}
+	if math.Abs(check.Cost-0.09) > 1e-9 {
+		t.Errorf("Cost = %v, want 0.09", check.Cost)
+	}
+	if check.Action != config.LimitBlock {
+		t.Errorf("Action = %q, want block", check.Action)
+	}
+	if len(check.Exceeded) != 0 {
+		t.Errorf("expected $0.99 today to pass, got %v", check.Exceeded)
+	}
+
+	req.MaxOutputTokens = 8000
+	check, err = CheckRequest(database, cfg, req, now)
+	if err != nil {
+		t.Fatalf("CheckRequest failed: %v", err)
+	}
+	if len(check.Exceeded) != 1 || !strings.Contains(check.Reason(), "$0.90 spent today") {
+		t.Errorf("expected only the daily limit exceeded, got %v", check.Exceeded)
+	}
+}
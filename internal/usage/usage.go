@@ -0,0 +1,314 @@
+// Package usage turns recorded token usage into costs and reports.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+// dayLayout formats the days usage is grouped by
+const dayLayout = "2006-01-02"
+
+// Cost returns what the tokens cost at price, in dollars.
+func Cost(price config.Price, input, output, cacheRead, cacheWrite int) float64 {
+	return (float64(input)*price.Input +
+		float64(output)*price.Output +
+		float64(cacheRead)*price.CacheRead +
+		float64(cacheWrite)*price.CacheWrite) / 1e6
+}
+
+// Row sums the usage of one provider's model over a report's period.
+type Row struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	InputTokens      int     `json:"input_tokens"`
+	OutputTokens     int     `json:"output_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	Estimated        int     `json:"estimated_requests"` // requests counted locally
+	Cost             float64 `json:"cost"`
+	Priced           bool    `json:"priced"` // false when the model has no price
+}
+
+// Tokens returns all the tokens in the row.
+func (r Row) Tokens() int {
+	return r.InputTokens + r.OutputTokens + r.CacheReadTokens + r.CacheWriteTokens
+}
+
+// Day is the usage of one day.
+type Day struct {
+	Day    string  `json:"day"`
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+// Report summarizes the usage of a number of days up to today, along with
+// the month-to-date spending the budget applies to.
+type Report struct {
+	Since      string   `json:"since"`
+	Until      string   `json:"until"` // last day included
+	Rows       []Row    `json:"models"`
+	Days       []Day    `json:"days"` // every day of the period, oldest first
+	Requests   int      `json:"requests"`
+	Tokens     int      `json:"tokens"`
+	Cost       float64  `json:"cost"`
+	MonthCost  float64  `json:"month_cost"`
+	Budget     float64  `json:"monthly_budget,omitempty"`
+	BudgetUsed float64  `json:"budget_used,omitempty"` // fraction of the budget spent this month
+	Warning    string   `json:"warning,omitempty"`     // set once the budget is nearly spent
+	Unpriced   []string `json:"unpriced_models,omitempty"`
+}
+
+// Load reads the usage of the last days days up to now and builds a report.
+func Load(database *db.DB, cfg *config.Config, days int, now time.Time) (Report, error) {
+	if days <= 0 {
+		return Report{}, fmt.Errorf("days must be positive, got %d", days)
+	}
+	start, monthStart := periodStart(now, days), monthStart(now)
+	since := start
+	if monthStart.Before(since) {
+		since = monthStart
+	}
+	totals, err := database.AggregateUsage(since, time.Time{})
+	if err != nil {
+		return Report{}, err
+	}
+	return Build(totals, cfg, days, now), nil
+}
+
+// Build makes a report of the last days days up to now from daily totals,
+// which must also cover the current month for the budget.
+func Build(totals []db.UsageTotal, cfg *config.Config, days int, now time.Time) Report {
+	start := periodStart(now, days)
+	month := now.Format("2006-01")
+	r := Report{
+		Since:  start.Format(dayLayout),
+		Until:  now.Format(dayLayout),
+		Budget: cfg.Budget.Monthly,
+	}
+
+	daily := make(map[string]*Day, days)
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i).Format(dayLayout)
+		r.Days = append(r.Days, Day{Day: day})
+	}
+	for i := range r.Days {
+		daily[r.Days[i].Day] = &r.Days[i]
+	}
+
+	rows := make(map[[2]string]*Row)
+	unpriced := make(map[string]bool)
+	for _, t := range totals {
+		price, priced := cfg.PriceFor(t.Model)
+		cost := Cost(price, t.InputTokens, t.OutputTokens, t.CacheReadTokens, t.CacheWriteTokens)
+		if strings.HasPrefix(t.Day, month) {
+			r.MonthCost += cost
+		}
+
+		day, ok := daily[t.Day]
+		if !ok {
+			// Earlier in the month than the period
+			continue
+		}
+		tokens := t.InputTokens + t.OutputTokens + t.CacheReadTokens + t.CacheWriteTokens
+		day.Tokens += tokens
+		day.Cost += cost
+
+		key := [2]string{t.Provider, t.Model}
+		row := rows[key]
+		if row == nil {
+			row = &Row{Provider: t.Provider, Model: t.Model, Priced: priced}
+			rows[key] = row
+		}
+		row.Requests += t.Requests
+		row.InputTokens += t.InputTokens
+		row.OutputTokens += t.OutputTokens
+		row.CacheReadTokens += t.CacheReadTokens
+		row.CacheWriteTokens += t.CacheWriteTokens
+		row.Estimated += t.Estimated
+		row.Cost += cost
+		if !priced && !unpriced[t.Model] {
+			unpriced[t.Model] = true
+			r.Unpriced = append(r.Unpriced, t.Model)
+		}
+
+		r.Requests += t.Requests
+		r.Tokens += tokens
+		r.Cost += cost
+	}
+
+	r.Rows = make([]Row, 0, len(rows))
+	for _, row := range rows {
+		r.Rows = append(r.Rows, *row)
+	}
+	// Most expensive first, then the most used
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.Tokens() != b.Tokens() {
+			return a.Tokens() > b.Tokens()
+		}
+		return a.Provider+a.Model < b.Provider+b.Model
+	})
+	sort.Strings(r.Unpriced)
+
+	if r.Budget > 0 {
+		r.BudgetUsed = r.MonthCost / r.Budget
+	}
+	r.Warning = BudgetWarning(r.MonthCost, cfg.Budget)
+	return r
+}
+
+// periodStart returns the midnight starting the period of days days that
+// ends today.
+func periodStart(now time.Time, days int) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+}
+
+// monthStart returns the midnight starting now's month.
+func monthStart(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+}
+
+// MonthCost returns what has been spent so far in now's month.
+func MonthCost(database *db.DB, cfg *config.Config, now time.Time) (float64, error) {
+	totals, err := database.AggregateUsage(monthStart(now), time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	var cost float64
+	for _, t := range totals {
+		price, _ := cfg.PriceFor(t.Model)
+		cost += Cost(price, t.InputTokens, t.OutputTokens, t.CacheReadTokens, t.CacheWriteTokens)
+	}
+	return cost, nil
+}
+
+// BudgetWarning returns a warning once spent reaches the budget's warning
+// threshold, or "" when there's no monthly budget or it's not near yet.
+func BudgetWarning(spent float64, budget config.Budget) string {
+	if budget.Monthly <= 0 || spent < budget.Monthly*budget.WarnAt {
+		return ""
+	}
+	percent := spent / budget.Monthly * 100
+	if spent >= budget.Monthly {
+		return fmt.Sprintf("Over budget: $%.2f of $%.2f this month (%.0f%%)", spent, budget.Monthly, percent)
+	}
+	return fmt.Sprintf("Budget: $%.2f of $%.2f this month (%.0f%%)", spent, budget.Monthly, percent)
+}
+
+// sparks are the bar heights of a sparkline, lowest first
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of bars scaled to the largest. Zero values
+// are blank, so days without usage stand out.
+func Sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if v <= 0 || max == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		i := int(math.Ceil(float64(v)/float64(max)*float64(len(sparks)))) - 1
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+// DailyTokens returns the tokens of each day, for a sparkline.
+func (r Report) DailyTokens() []int {
+	tokens := make([]int, len(r.Days))
+	for i, d := range r.Days {
+		tokens[i] = d.Tokens
+	}
+	return tokens
+}
+
+// WriteTable writes the report as a plain text table.
+func WriteTable(w io.Writer, r Report) error {
+	fmt.Fprintf(w, "Usage %s to %s\n", r.Since, r.Until)
+	fmt.Fprintf(w, "%s\n\n", Sparkline(r.DailyTokens()))
+	if err := WriteModels(w, r); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, r.MonthSummary())
+	if r.Warning != "" {
+		fmt.Fprintln(w, r.Warning)
+	}
+	return nil
+}
+
+// WriteModels writes the per-model table of the report, with notes on
+// estimated counts and models without a price.
+func WriteModels(w io.Writer, r Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tMODEL\tREQUESTS\tINPUT\tOUTPUT\tCACHE READ\tCACHE WRITE\tCOST")
+	estimated := false
+	for _, row := range r.Rows {
+		model := row.Model
+		if row.Estimated > 0 {
+			model += "*"
+			estimated = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			row.Provider, model, row.Requests, row.InputTokens, row.OutputTokens,
+			row.CacheReadTokens, row.CacheWriteTokens, formatCost(row.Cost, row.Priced))
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t\t\t\t\t%s\n", r.Requests, formatCost(r.Cost, true))
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+
+	if estimated {
+		fmt.Fprintln(w, "* includes token counts estimated locally")
+	}
+	if len(r.Unpriced) > 0 {
+		fmt.Fprintf(w, "No price configured for: %s\n", strings.Join(r.Unpriced, ", "))
+	}
+	return nil
+}
+
+// MonthSummary describes the month's spending against the budget.
+func (r Report) MonthSummary() string {
+	if r.Budget > 0 {
+		return fmt.Sprintf("This month: $%.2f of $%.2f (%.0f%%)", r.MonthCost, r.Budget, r.BudgetUsed*100)
+	}
+	return fmt.Sprintf("This month: $%.2f", r.MonthCost)
+}
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+func formatCost(cost float64, priced bool) string {
+	if !priced {
+		return "-"
+	}
+	return fmt.Sprintf("$%.4f", cost)
+}
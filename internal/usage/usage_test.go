@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg/ai-tui/internal/config"
+	"github.com/mg/ai-tui/internal/db"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Pricing: map[string]config.Price{
+			"claude-sonnet-4": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+		},
+		Budget: config.Budget{Monthly: 10, WarnAt: 0.8},
+	}
+}
+
+func TestCost(t *testing.T) {
+	price := config.Price{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	got := Cost(price, 1_000_000, 100_000, 2_000_000, 0)
+	if want := 3 + 1.5 + 0.6; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Cost = %v, want %v", got, want)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 10, 3, 15, 0, 0, 0, time.UTC)
+	totals := []db.UsageTotal{
+		// Last month: outside the period and the budget
+		{Day: "2026-09-20", Provider: "claude", Model: "claude-sonnet-4-20250514", Requests: 9, InputTokens: 9_000_000},
+		// Last month but inside a 7 day period
+		{Day: "2026-09-30", Provider: "claude", Model: "claude-sonnet-4-20250514", Requests: 1, InputTokens: 1_000_000},
+		{Day: "2026-10-01", Provider: "claude", Model: "claude-sonnet-4-20250514", Requests: 2, OutputTokens: 200_000},
+		{Day: "2026-10-03", Provider: "local", Model: "llama3", Requests: 4, InputTokens: 500, OutputTokens: 500, Estimated: 4},
+	}
+
+	r := Build(totals, testConfig(), 7, now)
+
+	if r.Since != "2026-09-27" || r.Until != "2026-10-03" {
+		t.Errorf("period = %s to %s, want 2026-09-27 to 2026-10-03", r.Since, r.Until)
+	}
+	if len(r.Days) != 7 {
+		t.Fatalf("expected 7 days, got %d", len(r.Days))
+	}
+	if r.Days[3].Day != "2026-09-30" || r.Days[3].Tokens != 1_000_000 {
+		t.Errorf("Days[3] = %+v, want 1M tokens on 2026-09-30", r.Days[3])
+	}
+	if r.Days[5].Tokens != 0 {
+		t.Errorf("expected no usage on %s, got %d tokens", r.Days[5].Day, r.Days[5].Tokens)
+	}
+
+	if len(r.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", r.Rows)
+	}
+	claude := r.Rows[0]
+	if claude.Model != "claude-sonnet-4-20250514" || claude.Requests != 3 || math.Abs(claude.Cost-6) > 1e-9 {
+		t.Errorf("claude row = %+v, want 3 requests costing $6", claude)
+	}
+	local := r.Rows[1]
+	if local.Priced || local.Estimated != 4 {
+		t.Errorf("local row = %+v, want unpriced with 4 estimated requests", local)
+	}
+	if len(r.Unpriced) != 1 || r.Unpriced[0] != "llama3" {
+		t.Errorf("Unpriced = %v, want [llama3]", r.Unpriced)
+	}
+	if r.Requests != 7 || math.Abs(r.Cost-6) > 1e-9 {
+		t.Errorf("totals = %d requests, $%v; want 7 requests, $6", r.Requests, r.Cost)
+	}
+
+	// Only October counts towards the budget
+	if math.Abs(r.MonthCost-3) > 1e-9 {
+		t.Errorf("MonthCost = %v, want 3", r.MonthCost)
+	}
+	if r.Warning != "" {
+		t.Errorf("expected no warning at 30%% of the budget, got %q", r.Warning)
+	}
+}
+
+func TestBudgetWarning(t *testing.T) {
+	budget := config.Budget{Monthly: 20, WarnAt: 0.8}
+	tests := []struct {
+		spent float64
+		want  string
+	}{
+		{10, ""},
+		{16, "Budget: $16.00 of $20.00 this month (80%)"},
+		{25, "Over budget: $25.00 of $20.00 this month (125%)"},
+	}
+	for _, tt := range tests {
+		if got := BudgetWarning(tt.spent, budget); got != tt.want {
+			t.Errorf("BudgetWarning(%v) = %q, want %q", tt.spent, got, tt.want)
+		}
+	}
+	if got := BudgetWarning(1000, config.Budget{WarnAt: 0.8}); got != "" {
+		t.Errorf("expected no warning without a budget, got %q", got)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]int{0, 1, 4, 8}); got != " ▁▄█" {
+		t.Errorf("Sparkline = %q, want %q", got, " ▁▄█")
+	}
+	if got := Sparkline([]int{0, 0}); got != "  " {
+		t.Errorf("Sparkline of no usage = %q, want blanks", got)
+	}
+}
+
+func TestWriteTableAndJSON(t *testing.T) {
+	now := time.Date(2026, 10, 3, 15, 0, 0, 0, time.UTC)
+	totals := []db.UsageTotal{
+		{Day: "2026-10-02", Provider: "claude", Model: "claude-sonnet-4", Requests: 2, OutputTokens: 600_000},
+		{Day: "2026-10-03", Provider: "local", Model: "llama3", Requests: 1, InputTokens: 10, Estimated: 1},
+	}
+	r := Build(totals, testConfig(), 3, now)
+
+	var buf bytes.Buffer
+	if err := WriteTable(&buf, r); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Usage 2026-10-01 to 2026-10-03",
+		"claude-sonnet-4",
+		"$9.0000",
+		"llama3*",
+		"No price configured for: llama3",
+		"Budget: $9.00 of $10.00 this month (90%)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Requests != 3 || len(decoded.Rows) != 2 || decoded.Warning != r.Warning {
+		t.Errorf("decoded report = %+v, want %+v", decoded, r)
+	}
+}
@@ -42,6 +42,30 @@ func main() {
 				os.Exit(1)
 			}
 			os.Exit(0)
+		case "export":
+			if err := runExport(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "import":
+			if err := runImport(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "config":
+			if err := runConfig(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "stats":
+			if err := runStats(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
 		}
 	}
 
@@ -72,6 +96,11 @@ func runUninstall() error {
 func runTUI() {
 	configPath := flag.String("config", "", "Path to config file")
 	showVersion := flag.Bool("version", false, "Print version and exit")
+	var sets []string
+	flag.Func("set", "Set a config value as path=value, e.g. providers.local.model=llama3 (repeatable)", func(s string) error {
+		sets = append(sets, s)
+		return nil
+	})
 	flag.Parse()
 
 	if *showVersion {
@@ -79,29 +108,10 @@ func runTUI() {
 		os.Exit(0)
 	}
 
-	// Determine config path
-	cfgPath := *configPath
-	if cfgPath == "" {
-		cfgPath = config.DefaultPath()
-	}
-	if strings.HasPrefix(cfgPath, "~/") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: cannot determine home directory: %v\n", err)
-			os.Exit(1)
-		}
-		cfgPath = filepath.Join(home, cfgPath[2:])
-	}
-
 	// Load config
-	cfg, err := config.Load(cfgPath)
+	cfg, err := loadConfig(*configPath, sets...)
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Config not found at %s\n", cfgPath)
-			fmt.Fprintf(os.Stderr, "Run 'ai-tui install' to set up config and launcher script.\n")
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -123,6 +133,10 @@ func runTUI() {
 		fmt.Fprintf(os.Stderr, "error: default provider %q not found\n", cfg.DefaultProvider)
 		os.Exit(1)
 	}
+	if err := cfg.Providers[cfg.DefaultProvider].KeyError; err != nil {
+		fmt.Fprintf(os.Stderr, "error: providers.%s: %v\n", cfg.DefaultProvider, err)
+		os.Exit(1)
+	}
 
 	// Create and run TUI
 	model := tui.NewAppModel(cfg, database, providers)
@@ -134,3 +148,20 @@ func runTUI() {
 		os.Exit(1)
 	}
 }
+
+// loadConfig loads the config at path, or at the default path when empty,
+// layered with the system and project configs and sets, the --set flags.
+func loadConfig(path string, sets ...string) (*config.Config, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine home directory: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	cfg, err := config.Load(path, sets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mg/ai-tui/internal/db"
+	"github.com/mg/ai-tui/internal/usage"
+)
+
+// runStats prints token usage and costs per provider and model.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to config file")
+	days := fs.Int("days", 30, "Number of days to report, up to today")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	database, err := db.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	report, err := usage.Load(database, cfg, *days, time.Now())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return usage.WriteJSON(os.Stdout, report)
+	}
+	return usage.WriteTable(os.Stdout, report)
+}